@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/auth"
+	"rsvbackend/internal/database"
+
+	"github.com/google/uuid"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+)
+
+// readPassword takes a new account's password from ADMIN_PASSWORD, or else the first
+// line of stdin. It is never a flag, which would leave it in shell history and the
+// process list.
+func readPassword() (string, error) {
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "Password for the new account: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Creates or promotes an account so the admin pages can be reached on a fresh
+// deployment. Existing users keep their password and only have their role changed.
+func main() {
+	email := flag.String("email", "", "email of the account to create or promote")
+	roleName := flag.String("role", string(auth.Admin), "role to grant: passenger, staff, admin or agent")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL is required to connect to the database")
+	}
+	db, err := sql.Open("libsql", dbURL)
+	if err != nil {
+		log.Fatalf("Failed to open database connection: %v", err)
+	}
+	defer db.Close()
+
+	if err := grantRole(context.Background(), database.New(db), *email, role, readPassword); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// grantRole gives the account with email the role, creating it with a password from
+// password if there is none, and records the change in the audit log
+func grantRole(ctx context.Context, queries database.QueriesInterface, email string, role auth.Role, password func() (string, error)) error {
+	detail := "role set to " + string(role)
+	_, err := queries.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		secret, err := password()
+		if err != nil || secret == "" {
+			return errors.New("a password, from ADMIN_PASSWORD or stdin, is required to create a new account")
+		}
+		hashed, err := auth.HashPassword(secret)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		if _, err := queries.CreateUser(ctx, database.CreateUserParams{
+			ID:       uuid.New().String(),
+			Email:    email,
+			Password: hashed,
+		}); err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		log.Printf("Created user %s", email)
+		detail = "created with role " + string(role)
+	} else if err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	if _, err := queries.SetUserRole(ctx, database.SetUserRoleParams{Role: string(role), Email: email}); err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	log.Printf("%s now has the %s role", email, role)
+
+	// Recorded as coming from this command rather than from a node of the cluster
+	_, err = audit.Append(ctx, queries, "bootstrap", 0, audit.Entry{
+		Actor:  "bootstrap",
+		Action: audit.Admin,
+		Target: "user:" + email,
+		Result: audit.Success,
+		Detail: detail,
+	}, time.Now())
+	if err != nil {
+		log.Printf("Failed to record audit event: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"rsvbackend/internal/auth"
+	"rsvbackend/internal/database"
+
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+func newTestQueries(t *testing.T) (*database.Queries, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite&_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "../sql/schema"); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	return database.New(db), db
+}
+
+// password hands out secret and counts how often it was asked for
+func password(secret string, asked *int) func() (string, error) {
+	return func() (string, error) {
+		*asked++
+		return secret, nil
+	}
+}
+
+func TestGrantRoleCreatesAdmin(t *testing.T) {
+	queries, db := newTestQueries(t)
+	ctx := context.Background()
+
+	asked := 0
+	if err := grantRole(ctx, queries, "ops@example.com", auth.Admin, password("correct horse", &asked)); err != nil {
+		t.Fatal(err)
+	}
+	user, err := queries.GetUserByEmail(ctx, "ops@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != string(auth.Admin) {
+		t.Errorf("role = %q, want admin", user.Role)
+	}
+	if err := auth.CheckPassword(user.Password, "correct horse"); err != nil {
+		t.Error("new account doesn't take the password it was given")
+	}
+	if asked != 1 {
+		t.Errorf("password asked for %d times, want once", asked)
+	}
+
+	var detail string
+	err = db.QueryRow("SELECT detail FROM audit_events WHERE actor = 'bootstrap' AND target = 'user:ops@example.com'").Scan(&detail)
+	if err != nil {
+		t.Fatalf("grant not audited: %v", err)
+	}
+	if detail != "created with role admin" {
+		t.Errorf("audit detail = %q", detail)
+	}
+}
+
+func TestGrantRolePromotesExistingUser(t *testing.T) {
+	queries, _ := newTestQueries(t)
+	ctx := context.Background()
+
+	hashed, err := auth.HashPassword("their own password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.CreateUser(ctx, database.CreateUserParams{ID: "user-1", Email: "agent@example.com", Password: hashed}); err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	if err := grantRole(ctx, queries, "agent@example.com", auth.Agent, password("ignored", &asked)); err != nil {
+		t.Fatal(err)
+	}
+	user, err := queries.GetUserByEmail(ctx, "agent@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != string(auth.Agent) {
+		t.Errorf("role = %q, want agent", user.Role)
+	}
+	if asked != 0 || auth.CheckPassword(user.Password, "their own password") != nil {
+		t.Error("promoting an existing user touched their password")
+	}
+}
+
+func TestGrantRoleNeedsPasswordForNewAccount(t *testing.T) {
+	queries, _ := newTestQueries(t)
+	ctx := context.Background()
+
+	for _, password := range []func() (string, error){
+		func() (string, error) { return "", nil },
+		func() (string, error) { return "", errors.New("stdin closed") },
+	} {
+		if err := grantRole(ctx, queries, "ops@example.com", auth.Admin, password); err == nil {
+			t.Error("account created without a password")
+		}
+	}
+	if _, err := queries.GetUserByEmail(ctx, "ops@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("looking up the account got %v, want none created", err)
+	}
+}
@@ -3,7 +3,14 @@ package app
 import (
 	"html/template"
 	"rsvbackend/internal/database"
+	"rsvbackend/internal/eticket"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/ratelimit"
+	"rsvbackend/internal/refund"
+	"rsvbackend/internal/waitingroom"
 	"sync"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
@@ -14,8 +21,42 @@ type AppState struct {
 	Store     *sessions.CookieStore     // Session store for authentication
 	Templates *template.Template        // Loaded templates
 	Node      *Node                     // Distributed system node state
+	HoldTTL   time.Duration             // How long a seat stays held before checkout must complete
+	// WaitlistOfferTTL is how long a seat offered from the waitlist is held for payment
+	WaitlistOfferTTL time.Duration
+	// IdempotencyWindow is how long responses are kept for replay to retried requests
+	IdempotencyWindow time.Duration
+	Fares             *pricing.Rules   // Fare calculation rules
+	Refunds           *refund.Policy   // Refunds given on cancellation
+	ETickets          *eticket.Signer  // Signs e-tickets; nil until configured in main
+	Payments          payment.Provider // Payment gateway; configured in main
+	// StaffTokens maps the API tokens staff devices present to the staff member's name
+	StaffTokens map[string]string
+	// ManageLimiter throttles public booking lookups by client IP so PNRs can't be enumerated
+	ManageLimiter *ratelimit.Limiter
+	// WaitingRoom queues users before they reach booking; nil when it is turned off
+	WaitingRoom *waitingroom.Room
+	// Location is the operator's time zone: service dates, timetables, sale windows,
+	// peak hours and reports follow its wall clock
+	Location *time.Location
 }
 
+// DefaultHoldTTL is used when SEAT_HOLD_TTL is not configured
+const DefaultHoldTTL = 10 * time.Minute
+
+// DefaultWaitlistOfferTTL is used when WAITLIST_OFFER_TTL is not configured
+const DefaultWaitlistOfferTTL = 2 * time.Hour
+
+// DefaultIdempotencyWindow is used when IDEMPOTENCY_WINDOW is not configured
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// Public booking lookups allowed per client IP in each window, counted on each node
+// separately
+const (
+	ManageLookupLimit  = 10
+	ManageLookupWindow = 15 * time.Minute
+)
+
 // Node represents the state of a node in the distributed system
 type Node struct {
 	ID       string
@@ -27,6 +68,15 @@ type Node struct {
 	Mutex    sync.Mutex
 }
 
+// Tick advances the node's Lamport clock for an event on this node and returns the
+// event's time
+func (n *Node) Tick() int64 {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+	n.Clock++
+	return n.Clock
+}
+
 type Request struct {
 	NodeID    string
 	Timestamp int64
@@ -50,5 +100,12 @@ func NewAppState(
 			Clock: 0,
 			Peers: peers,
 		},
+		HoldTTL:           DefaultHoldTTL,
+		WaitlistOfferTTL:  DefaultWaitlistOfferTTL,
+		IdempotencyWindow: DefaultIdempotencyWindow,
+		Fares:             pricing.DefaultRules(),
+		Refunds:           refund.DefaultPolicy(),
+		ManageLimiter:     ratelimit.New(ManageLookupLimit, ManageLookupWindow),
+		Location:          time.UTC,
 	}
 }
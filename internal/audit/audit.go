@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"rsvbackend/internal/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Results an event can have. The values are stored in audit_events.result.
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Actions the log records
+const (
+	Register     = "register"
+	Login        = "login"
+	Booking      = "booking"
+	Cancellation = "cancellation"
+	Modification = "modification" // a ticket changed to another seat, train or date
+	Admin        = "admin"        // any change made through the admin pages or API
+)
+
+// Actions lists every action, for filtering the log
+var Actions = []string{Register, Login, Booking, Cancellation, Modification, Admin}
+
+// GenesisHash stands in for the hash of the event before the first one
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// appendAttempts bounds how often Append retries when another writer, possibly on
+// another node, took the next place in the chain first
+const appendAttempts = 5
+
+// chainBatch is how many events VerifyChain reads at a time
+const chainBatch = 500
+
+// Entry is something that happened, to be recorded
+type Entry struct {
+	Actor  string // user ID, email for failed logins, or the staff member
+	Action string
+	Target string // what was acted on, such as a booking or an admin URL
+	IP     string
+	Result string
+	Detail string
+}
+
+// Break is where a chain stops verifying, and why
+type Break struct {
+	Seq    int64
+	Reason string
+}
+
+func (b *Break) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", b.Seq, b.Reason)
+}
+
+// Hash is the hash of an event's contents together with the hash of the event before
+// it. Each field is length-prefixed so that moving text between fields changes it.
+func Hash(e database.AuditEvent) string {
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.Action,
+		e.Target,
+		e.NodeID,
+		strconv.FormatInt(e.LamportClock, 10),
+		e.Ip,
+		e.Result,
+		e.Detail,
+		e.PrevHash,
+	} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Append records an entry at the end of the chain, stamped with the node writing it
+// and that node's Lamport clock
+func Append(ctx context.Context, db database.QueriesInterface, nodeID string, clock int64, e Entry, now time.Time) (database.AuditEvent, error) {
+	var event database.AuditEvent
+	var err error
+	for attempt := 0; attempt < appendAttempts; attempt++ {
+		err = db.ExecTx(ctx, func(q database.QueriesInterface) error {
+			last, err := q.GetLastAuditEvent(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				last = database.AuditEvent{Hash: GenesisHash}
+			} else if err != nil {
+				return err
+			}
+
+			params := database.AppendAuditEventParams{
+				Seq:          last.Seq + 1,
+				OccurredAt:   now.UTC().Truncate(time.Second),
+				Actor:        e.Actor,
+				Action:       e.Action,
+				Target:       e.Target,
+				NodeID:       nodeID,
+				LamportClock: clock,
+				Ip:           e.IP,
+				Result:       e.Result,
+				Detail:       e.Detail,
+				PrevHash:     last.Hash,
+			}
+			params.Hash = Hash(database.AuditEvent(params))
+			event, err = q.AppendAuditEvent(ctx, params)
+			return err
+		})
+		if err == nil || !strings.Contains(err.Error(), "UNIQUE constraint failed: audit_events.seq") {
+			return event, err
+		}
+	}
+	return event, err
+}
+
+// Verify checks that events are the ones following prev in the chain, unchanged. prev
+// is the zero event when they start the chain. It returns a *Break at the first event
+// that doesn't verify.
+func Verify(prev database.AuditEvent, events []database.AuditEvent) error {
+	if prev.Seq == 0 {
+		prev.Hash = GenesisHash
+	}
+	for _, e := range events {
+		switch {
+		case e.Seq != prev.Seq+1:
+			return &Break{Seq: prev.Seq + 1, Reason: "event is missing"}
+		case e.PrevHash != prev.Hash:
+			return &Break{Seq: e.Seq, Reason: "event does not follow the one before it"}
+		case Hash(e) != e.Hash:
+			return &Break{Seq: e.Seq, Reason: "event has been changed"}
+		}
+		prev = e
+	}
+	return nil
+}
+
+// VerifyChain checks the whole log from the first event and returns how many events
+// verified. Events removed from the very end leave no gap, so they can't be detected
+// this way; the database refuses to delete events in the first place.
+func VerifyChain(ctx context.Context, db database.QueriesInterface) (int64, error) {
+	var prev database.AuditEvent
+	var checked int64
+	for {
+		events, err := db.ListAuditChain(ctx, database.ListAuditChainParams{
+			AfterSeq: prev.Seq,
+			Limit:    chainBatch,
+		})
+		if err != nil {
+			return checked, err
+		}
+		if err := Verify(prev, events); err != nil {
+			var b *Break
+			if errors.As(err, &b) {
+				checked = b.Seq - 1
+			}
+			return checked, err
+		}
+		if len(events) == 0 {
+			return checked, nil
+		}
+		prev = events[len(events)-1]
+		checked = prev.Seq
+	}
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"rsvbackend/internal/database"
+)
+
+// memoryLog keeps the audit log in memory; other queries are not used
+type memoryLog struct {
+	database.QueriesInterface
+	events []database.AuditEvent
+}
+
+func (m *memoryLog) ExecTx(ctx context.Context, fn func(database.QueriesInterface) error) error {
+	return fn(m)
+}
+
+func (m *memoryLog) GetLastAuditEvent(ctx context.Context) (database.AuditEvent, error) {
+	if len(m.events) == 0 {
+		return database.AuditEvent{}, sql.ErrNoRows
+	}
+	return m.events[len(m.events)-1], nil
+}
+
+func (m *memoryLog) AppendAuditEvent(ctx context.Context, arg database.AppendAuditEventParams) (database.AuditEvent, error) {
+	m.events = append(m.events, database.AuditEvent(arg))
+	return database.AuditEvent(arg), nil
+}
+
+func (m *memoryLog) ListAuditChain(ctx context.Context, arg database.ListAuditChainParams) ([]database.AuditEvent, error) {
+	var events []database.AuditEvent
+	for _, e := range m.events {
+		if e.Seq > arg.AfterSeq && int64(len(events)) < arg.Limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func newLog(t *testing.T, n int) *memoryLog {
+	t.Helper()
+	log := &memoryLog{}
+	now := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		_, err := Append(context.Background(), log, "node-1", int64(i+1), Entry{
+			Actor:  "user-1",
+			Action: Login,
+			IP:     "192.0.2.1",
+			Result: Success,
+		}, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return log
+}
+
+func TestAppendChainsEvents(t *testing.T) {
+	log := newLog(t, 3)
+	if log.events[0].Seq != 1 || log.events[0].PrevHash != GenesisHash {
+		t.Errorf("first event = %+v, want seq 1 after the genesis hash", log.events[0])
+	}
+	for i := 1; i < len(log.events); i++ {
+		if log.events[i].PrevHash != log.events[i-1].Hash {
+			t.Errorf("event %d does not point at the hash of event %d", i+1, i)
+		}
+	}
+
+	checked, err := VerifyChain(context.Background(), log)
+	if err != nil || checked != 3 {
+		t.Errorf("VerifyChain() = %d, %v, want 3 events verified", checked, err)
+	}
+}
+
+func TestVerifyChainFindsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(events []database.AuditEvent) []database.AuditEvent
+		want   int64
+	}{
+		{"changed field", func(e []database.AuditEvent) []database.AuditEvent {
+			e[1].Result = Failure
+			return e
+		}, 2},
+		{"rehashed after a change", func(e []database.AuditEvent) []database.AuditEvent {
+			e[1].Actor = "user-2"
+			e[1].Hash = Hash(e[1])
+			return e
+		}, 3},
+		{"removed event", func(e []database.AuditEvent) []database.AuditEvent {
+			return append(e[:1], e[2:]...)
+		}, 2},
+		{"renumbered after a removal", func(e []database.AuditEvent) []database.AuditEvent {
+			e = append(e[:1], e[2:]...)
+			e[1].Seq = 2
+			return e
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newLog(t, 4)
+			log.events = tt.tamper(log.events)
+
+			checked, err := VerifyChain(context.Background(), log)
+			var b *Break
+			if !errors.As(err, &b) {
+				t.Fatalf("VerifyChain() error = %v, want a break", err)
+			}
+			if b.Seq != tt.want || checked != tt.want-1 {
+				t.Errorf("VerifyChain() broke at %d after %d events, want %d", b.Seq, checked, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashSeparatesFields(t *testing.T) {
+	a := database.AuditEvent{Actor: "ab", Action: "c"}
+	b := database.AuditEvent{Actor: "a", Action: "bc"}
+	if Hash(a) == Hash(b) {
+		t.Error("Hash() is the same when text moves between fields")
+	}
+}
@@ -28,3 +28,32 @@ func TestHashPasswordAndCheck(t *testing.T) {
 		t.Error("expected error for wrong password, got nil")
 	}
 }
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{Passenger, Book, true},
+		{Passenger, CheckIn, false},
+		{Passenger, Administer, false},
+		{Agent, Book, true},
+		{Agent, Administer, false},
+		{Staff, CheckIn, true},
+		{Staff, Administer, false},
+		{Admin, Administer, true},
+		{Admin, CheckIn, true},
+		{Role("guest"), Book, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.permission); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+
+	if _, err := ParseRole("superuser"); err == nil {
+		t.Error("expected error for unknown role")
+	}
+}
@@ -0,0 +1,56 @@
+package auth
+
+import "fmt"
+
+// Role is what kind of account a user has
+type Role string
+
+const (
+	Passenger Role = "passenger"
+	Staff     Role = "staff"
+	Admin     Role = "admin"
+	// Agent sells tickets to customers
+	Agent Role = "agent"
+)
+
+// Roles lists every role
+var Roles = []Role{Passenger, Staff, Admin, Agent}
+
+// Permission is something a role may be allowed to do
+type Permission string
+
+const (
+	// Book lets a user book, change and cancel tickets
+	Book Permission = "book"
+	// CheckIn lets a user check passengers in and read train manifests
+	CheckIn Permission = "check_in"
+	// Administer lets a user manage trains, timetables, sales and quotas
+	Administer Permission = "administer"
+)
+
+var rolePermissions = map[Role][]Permission{
+	Passenger: {Book},
+	Agent:     {Book},
+	Staff:     {Book, CheckIn},
+	Admin:     {Book, CheckIn, Administer},
+}
+
+// ParseRole reads a role as stored on the users table
+func ParseRole(s string) (Role, error) {
+	for _, r := range Roles {
+		if string(r) == s {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+// Can reports whether the role grants a permission
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package database
+
+import "time"
+
+// OriginDepartsAt is when the train leaves the journey's origin station.
+func (r GetAvailableTicketsRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// DestinationArrivesAt is when the train reaches the journey's destination station.
+func (r GetAvailableTicketsRow) DestinationArrivesAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.ArrivalOffsetMinutes) * time.Minute)
+}
+
+// OriginDepartsAt is when the train leaves the ticket's origin station.
+func (r GetUserTicketsRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// DestinationArrivesAt is when the train reaches the ticket's destination station.
+func (r GetUserTicketsRow) DestinationArrivesAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.ArrivalOffsetMinutes) * time.Minute)
+}
+
+// OriginDepartsAt is when the train leaves the segment's origin station.
+func (r GetSegmentFareInputsRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// OriginDepartsAt is when the train leaves the ticket's origin station.
+func (r GetBookingTicketsRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// DestinationArrivesAt is when the train reaches the ticket's destination station.
+func (r GetBookingTicketsRow) DestinationArrivesAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.ArrivalOffsetMinutes) * time.Minute)
+}
+
+// OriginDepartsAt is when the train leaves the ticket's origin station.
+func (r GetTicketRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// DestinationArrivesAt is when the train reaches the ticket's destination station.
+func (r GetTicketRow) DestinationArrivesAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.ArrivalOffsetMinutes) * time.Minute)
+}
+
+// OriginDepartsAt is when the train leaves the journey's origin station.
+func (r GetJourneyStopsRow) OriginDepartsAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.DepartureOffsetMinutes) * time.Minute)
+}
+
+// DestinationArrivesAt is when the train reaches the journey's destination station.
+func (r GetJourneyStopsRow) DestinationArrivesAt() time.Time {
+	return r.DepartsAt.Add(time.Duration(r.ArrivalOffsetMinutes) * time.Minute)
+}
+
+// salesOpenLayout is how SQLite's datetime() writes sale opening times, which are on
+// the operator's wall clock
+const salesOpenLayout = "2006-01-02 15:04:05"
+
+// ParseSalesOpenAt reads a sale opening time computed by a query, on the wall clock
+// of the operator's time zone loc. Trains without a booking window have none, which
+// is the zero time.
+func ParseSalesOpenAt(s string, loc *time.Location) time.Time {
+	t, err := time.ParseInLocation(salesOpenLayout, s, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// SalesClock is t on the wall clock of the operator's time zone loc, in the form
+// queries compare sale opening times with
+func SalesClock(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(salesOpenLayout)
+}
@@ -6,24 +6,297 @@ package database
 
 import (
 	"database/sql"
+	"time"
 )
 
-type Ticket struct {
+type ActiveQuotaSeat struct {
+	DepartureID string
+	SeatNumber  int64
+	QuotaID     string
+	Category    string
+}
+
+type AuditEvent struct {
+	Seq          int64
+	OccurredAt   time.Time
+	Actor        string
+	Action       string
+	Target       string
+	NodeID       string
+	LamportClock int64
+	Ip           string
+	Result       string
+	Detail       string
+	PrevHash     string
+	Hash         string
+}
+
+type Booking struct {
+	ID        string
+	Pnr       string
+	UserID    string
+	CreatedAt sql.NullTime
+	Channel   string
+}
+
+type Cancellation struct {
+	ID                   string
+	TicketID             string
+	Channel              string
+	HoursBeforeDeparture int64
+	FareAmount           int64
+	RefundPercent        int64
+	FeeAmount            int64
+	RefundAmount         int64
+	Currency             string
+	CancelledAt          sql.NullTime
+}
+
+type Checkin struct {
+	ID          string
+	TicketID    string
+	DepartureID string
+	Method      string
+	Staff       string
+	DeviceID    string
+	ScannedAt   time.Time
+	RecordedAt  sql.NullTime
+}
+
+type Coach struct {
+	ID       string
+	TrainID  string
+	Code     string
+	Class    string
+	Position int64
+}
+
+type Departure struct {
+	ID          string
+	TrainID     string
+	ServiceDate string
+	DepartsAt   time.Time
+	ArrivesAt   time.Time
+	Status      string
+	RuleID      sql.NullString
+	TotalSeats  int64
+}
+
+type IdempotencyKey struct {
+	Owner               string
+	Scope               string
+	IdempotencyKey      string
+	RequestHash         string
+	ResponseStatus      sql.NullInt64
+	ResponseLocation    string
+	ResponseContentType string
+	ResponseBody        string
+	CreatedAt           sql.NullTime
+	ExpiresAt           time.Time
+}
+
+type Payment struct {
+	ID              string
+	UserID          string
+	HoldID          string
+	TicketID        sql.NullString
+	Provider        string
+	ProviderRef     sql.NullString
+	Amount          int64
+	Currency        string
+	Status          string
+	FailureReason   string
+	RefundedAmount  int64
+	CreatedAt       sql.NullTime
+	UpdatedAt       sql.NullTime
+	BookingID       sql.NullString
+	RefundDue       int64
+	RefundError     string
+	ClientIP        string
+	RefundClaimed   int64
+	RefundClaimedAt sql.NullTime
+}
+
+type QueueEntry struct {
+	ID         string
+	UserID     string
+	Position   int64
+	Status     string
+	JoinedAt   sql.NullTime
+	LastSeenAt sql.NullTime
+	AdmittedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+}
+
+type QuotaSeat struct {
+	QuotaID     string
+	DepartureID string
+	SeatNumber  int64
+}
+
+type RouteStop struct {
+	TrainID                string
+	StopSequence           int64
+	StationID              string
+	ArrivalOffsetMinutes   int64
+	DepartureOffsetMinutes int64
+	DistanceKm             int64
+}
+
+type SaleWindow struct {
+	TrainID         string
+	Class           string
+	OpensDaysBefore int64
+	OpensTime       string
+}
+
+type SavedPassenger struct {
 	ID         string
-	TrainID    string
 	UserID     string
-	SeatNumber int64
-	BookedAt   sql.NullTime
+	Name       string
+	Age        int64
+	Gender     string
+	IDDocument sql.NullString
+	CreatedAt  sql.NullTime
+}
+
+type SeatHold struct {
+	ID                  string
+	DepartureID         string
+	UserID              string
+	SeatNumber          int64
+	CreatedAt           sql.NullTime
+	ExpiresAt           time.Time
+	OriginStop          int64
+	DestinationStop     int64
+	FareAmount          int64
+	FareCurrency        string
+	PassengerName       sql.NullString
+	PassengerAge        sql.NullInt64
+	PassengerGender     sql.NullString
+	PassengerIDDocument sql.NullString
+	ItineraryID         sql.NullString
+	Leg                 int64
+	Legs                int64
+	Quota               string
+	ConcessionRef       sql.NullString
+	Passenger           int64
+	Passengers          int64
+	Channel             string
+}
+
+type SeatQuota struct {
+	ID                 string
+	DepartureID        string
+	Category           string
+	Class              string
+	ReleaseHoursBefore int64
+	CreatedAt          sql.NullTime
+}
+
+type Seat struct {
+	TrainID         string
+	SeatNumber      int64
+	CoachID         string
+	Label           string
+	IsWindow        bool
+	IsAisle         bool
+	BerthType       sql.NullString
+	WheelchairSpace bool
+	Retired         bool
+}
+
+type Station struct {
+	ID                 string
+	Code               string
+	Name               string
+	MinTransferMinutes int64
+}
+
+type TicketModification struct {
+	ID             string
+	OldTicketID    string
+	NewTicketID    string
+	OldFareAmount  int64
+	NewFareAmount  int64
+	FareDifference int64
+	Currency       string
+	ModifiedAt     sql.NullTime
+}
+
+type TicketStateTransition struct {
+	ID        string
+	TicketID  string
+	FromState sql.NullString
+	ToState   string
+	Actor     string
+	Reason    string
+	CreatedAt sql.NullTime
+}
+
+type Ticket struct {
+	ID                  string
+	DepartureID         string
+	UserID              string
+	SeatNumber          int64
+	OriginStop          int64
+	DestinationStop     int64
+	BookedAt            sql.NullTime
+	FareAmount          int64
+	FareCurrency        string
+	PassengerName       sql.NullString
+	PassengerAge        sql.NullInt64
+	PassengerGender     sql.NullString
+	PassengerIDDocument sql.NullString
+	BookingID           sql.NullString
+	Status              string
+	Quota               string
+	ConcessionRef       sql.NullString
+}
+
+type TimetableException struct {
+	RuleID      string
+	ServiceDate string
+}
+
+type TimetableRule struct {
+	ID            string
+	TrainID       string
+	Frequency     string
+	DaysOfWeek    string
+	DepartureTime string
+	ValidFrom     string
+	ValidUntil    sql.NullString
+	CreatedAt     sql.NullTime
 }
 
 type Train struct {
 	ID         string
 	Name       string
 	TotalSeats int64
+	Status     string
 }
 
 type User struct {
 	ID       string
 	Email    string
 	Password string
+	Role     string
+}
+
+type WaitlistEntry struct {
+	ID                  string
+	DepartureID         string
+	UserID              string
+	Seq                 int64
+	Status              string
+	TicketID            sql.NullString
+	CreatedAt           sql.NullTime
+	OriginStop          int64
+	DestinationStop     int64
+	PassengerName       sql.NullString
+	PassengerAge        sql.NullInt64
+	PassengerGender     sql.NullString
+	PassengerIDDocument sql.NullString
+	HoldID              sql.NullString
 }
@@ -2,14 +2,168 @@ package database
 
 import (
 	"context"
+	"database/sql"
 )
 
 // QueriesInterface defines the required database methods.
 type QueriesInterface interface {
 	CreateUser(ctx context.Context, params CreateUserParams) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
-	GetAvailableTickets(ctx context.Context) ([]GetAvailableTicketsRow, error)
+	GetUser(ctx context.Context, id string) (User, error)
+	SetUserRole(ctx context.Context, params SetUserRoleParams) (int64, error)
+	GetAvailableTickets(ctx context.Context, params GetAvailableTicketsParams) ([]GetAvailableTicketsRow, error)
 	CreateTicket(ctx context.Context, params CreateTicketParams) (Ticket, error)
-	DeleteTicket(ctx context.Context, params DeleteTicketParams) error // Updated to use DeleteTicketParams
-	GetUserTickets(ctx context.Context, userID string) ([]GetUserTicketsRow, error)
+	GetTicket(ctx context.Context, id string) (GetTicketRow, error)
+	GetUserTickets(ctx context.Context, params GetUserTicketsParams) ([]GetUserTicketsRow, error)
+
+	// Seat holds
+	CreateSeatHold(ctx context.Context, params CreateSeatHoldParams) (SeatHold, error)
+	GetItineraryHolds(ctx context.Context, params GetItineraryHoldsParams) ([]GetItineraryHoldsRow, error)
+	ConfirmSeatHold(ctx context.Context, params ConfirmSeatHoldParams) (Ticket, error)
+	DeleteSeatHold(ctx context.Context, params DeleteSeatHoldParams) error
+	DeleteItineraryHolds(ctx context.Context, params DeleteItineraryHoldsParams) error
+	ReleaseItineraryHolds(ctx context.Context, params ReleaseItineraryHoldsParams) ([]ReleaseItineraryHoldsRow, error)
+	DeleteExpiredSeatHolds(ctx context.Context) ([]DeleteExpiredSeatHoldsRow, error)
+
+	// Waitlist
+	JoinWaitlist(ctx context.Context, params JoinWaitlistParams) (WaitlistEntry, error)
+	LeaveWaitlist(ctx context.Context, params LeaveWaitlistParams) error
+	GetWaitingEntries(ctx context.Context, departureID string) ([]WaitlistEntry, error)
+	MarkWaitlistPromoted(ctx context.Context, params MarkWaitlistPromotedParams) error
+	SetWaitlistTicket(ctx context.Context, params SetWaitlistTicketParams) error
+	GetUserWaitlist(ctx context.Context, userID string) ([]GetUserWaitlistRow, error)
+	GetUserWaitlistOffers(ctx context.Context, userID string) ([]GetUserWaitlistOffersRow, error)
+
+	// Stations and routes
+	ListStations(ctx context.Context) ([]Station, error)
+	GetJourneyStops(ctx context.Context, params GetJourneyStopsParams) (GetJourneyStopsRow, error)
+	ListTransferStations(ctx context.Context, params ListTransferStationsParams) ([]Station, error)
+	GetTrainRunMinutes(ctx context.Context, trainID string) (int64, error)
+	GetSegmentFareInputs(ctx context.Context, params GetSegmentFareInputsParams) (GetSegmentFareInputsRow, error)
+
+	// Seats
+	FindAvailableSeat(ctx context.Context, params FindAvailableSeatParams) (FindAvailableSeatRow, error)
+	GetDepartureSeat(ctx context.Context, params GetDepartureSeatParams) (GetDepartureSeatRow, error)
+	AddCoachSeat(ctx context.Context, params AddCoachSeatParams) error
+	RestoreCoachSeats(ctx context.Context, params RestoreCoachSeatsParams) (int64, error)
+	RetireCoachSeats(ctx context.Context, params RetireCoachSeatsParams) (int64, error)
+
+	// Trains and coaches
+	CreateTrain(ctx context.Context, params CreateTrainParams) (Train, error)
+	GetTrain(ctx context.Context, id string) (Train, error)
+	ListTrains(ctx context.Context) ([]Train, error)
+	RenameTrain(ctx context.Context, params RenameTrainParams) (int64, error)
+	RetireTrain(ctx context.Context, id string) (int64, error)
+	UpdateTrainCapacity(ctx context.Context, id string) error
+	CreateCoach(ctx context.Context, params CreateCoachParams) (Coach, error)
+	GetCoach(ctx context.Context, id string) (GetCoachRow, error)
+	ListCoaches(ctx context.Context, trainID string) ([]ListCoachesRow, error)
+
+	// Saved passengers
+	CreateSavedPassenger(ctx context.Context, params CreateSavedPassengerParams) (SavedPassenger, error)
+	ListSavedPassengers(ctx context.Context, userID string) ([]SavedPassenger, error)
+	GetSavedPassenger(ctx context.Context, params GetSavedPassengerParams) (SavedPassenger, error)
+	DeleteSavedPassenger(ctx context.Context, params DeleteSavedPassengerParams) error
+
+	// Bookings
+	CreateBooking(ctx context.Context, params CreateBookingParams) (Booking, error)
+	DeleteBooking(ctx context.Context, id string) error
+	GetBookingByPNR(ctx context.Context, params GetBookingByPNRParams) (Booking, error)
+	GetBookingTickets(ctx context.Context, bookingID sql.NullString) ([]GetBookingTicketsRow, error)
+
+	// Cancellations
+	CreateCancellation(ctx context.Context, params CreateCancellationParams) (Cancellation, error)
+
+	// Ticket modifications
+	CreateTicketModification(ctx context.Context, params CreateTicketModificationParams) (TicketModification, error)
+
+	// Ticket lifecycle
+	TransitionTicket(ctx context.Context, params TransitionTicketParams) (TransitionTicketRow, error)
+	CreateTicketTransition(ctx context.Context, params CreateTicketTransitionParams) error
+	ListMissedTickets(ctx context.Context, limit int64) ([]string, error)
+
+	// Departures
+	GetDeparture(ctx context.Context, id string) (GetDepartureRow, error)
+	CreateScheduledDeparture(ctx context.Context, params CreateScheduledDepartureParams) (int64, error)
+	GetSalesOpenAt(ctx context.Context, params GetSalesOpenAtParams) (string, error)
+	CreateDeparture(ctx context.Context, params CreateDepartureParams) (Departure, error)
+	ListTrainDepartures(ctx context.Context, params ListTrainDeparturesParams) ([]ListTrainDeparturesRow, error)
+	RescheduleDeparture(ctx context.Context, params RescheduleDepartureParams) (Departure, error)
+	CancelDeparture(ctx context.Context, id string) (int64, error)
+	CountBookedDepartures(ctx context.Context, trainID string) (int64, error)
+	CancelTrainDepartures(ctx context.Context, trainID string) (int64, error)
+	UpdateDepartureCapacity(ctx context.Context, trainID string) error
+
+	// Timetable rules
+	CreateTimetableRule(ctx context.Context, params CreateTimetableRuleParams) (TimetableRule, error)
+	GetTimetableRule(ctx context.Context, id string) (TimetableRule, error)
+	ListTimetableRules(ctx context.Context) ([]TimetableRule, error)
+	AddTimetableException(ctx context.Context, params AddTimetableExceptionParams) error
+	ListTimetableExceptions(ctx context.Context, ruleID string) ([]string, error)
+
+	// Sale windows
+	ListSaleWindows(ctx context.Context) ([]SaleWindow, error)
+	UpsertSaleWindow(ctx context.Context, params UpsertSaleWindowParams) (SaleWindow, error)
+	DeleteSaleWindow(ctx context.Context, params DeleteSaleWindowParams) error
+
+	// Check-in
+	CreateCheckin(ctx context.Context, params CreateCheckinParams) (Checkin, error)
+	GetCheckin(ctx context.Context, ticketID string) (Checkin, error)
+	GetDepartureManifest(ctx context.Context, departureID string) ([]GetDepartureManifestRow, error)
+	GetPNRTickets(ctx context.Context, pnr string) ([]GetPNRTicketsRow, error)
+
+	// Payments
+	CreatePayment(ctx context.Context, params CreatePaymentParams) (Payment, error)
+	GetPayment(ctx context.Context, id string) (Payment, error)
+	GetHoldPayment(ctx context.Context, params GetHoldPaymentParams) (Payment, error)
+	ListTicketPayments(ctx context.Context, id string) ([]Payment, error)
+	SetPaymentRef(ctx context.Context, params SetPaymentRefParams) error
+	SetPaymentClientIP(ctx context.Context, params SetPaymentClientIPParams) error
+	SetPaymentTicket(ctx context.Context, params SetPaymentTicketParams) error
+	UpdatePaymentStatus(ctx context.Context, params UpdatePaymentStatusParams) (Payment, error)
+	FailHoldPayments(ctx context.Context, params FailHoldPaymentsParams) (int64, error)
+	QueueRefund(ctx context.Context, params QueueRefundParams) (int64, error)
+	ListDueRefunds(ctx context.Context, limit int64) ([]Payment, error)
+	ClaimRefund(ctx context.Context, params ClaimRefundParams) (Payment, error)
+	SettleClaimedRefund(ctx context.Context, params SettleClaimedRefundParams) (int64, error)
+	SetRefundError(ctx context.Context, params SetRefundErrorParams) error
+
+	// Idempotency keys
+	ClaimIdempotencyKey(ctx context.Context, params ClaimIdempotencyKeyParams) (IdempotencyKey, error)
+	GetIdempotencyKey(ctx context.Context, params GetIdempotencyKeyParams) (IdempotencyKey, error)
+	SaveIdempotentResponse(ctx context.Context, params SaveIdempotentResponseParams) error
+	ReleaseIdempotencyKey(ctx context.Context, params ReleaseIdempotencyKeyParams) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+
+	// Seat quotas
+	CreateSeatQuota(ctx context.Context, params CreateSeatQuotaParams) (SeatQuota, error)
+	AllocateQuotaSeats(ctx context.Context, params AllocateQuotaSeatsParams) (int64, error)
+	ListSeatQuotas(ctx context.Context, departureID string) ([]ListSeatQuotasRow, error)
+	DeleteQuotaSeats(ctx context.Context, quotaID string) error
+	DeleteSeatQuota(ctx context.Context, id string) (int64, error)
+	DeleteRetiredQuotaSeats(ctx context.Context) error
+
+	// Waiting room
+	JoinQueue(ctx context.Context, params JoinQueueParams) (QueueEntry, error)
+	GetQueueEntry(ctx context.Context, params GetQueueEntryParams) (QueueEntry, error)
+	TouchQueueEntry(ctx context.Context, id string) error
+	GetQueuePosition(ctx context.Context, params GetQueuePositionParams) (int64, error)
+	AdmitFromQueue(ctx context.Context, params AdmitFromQueueParams) (int64, error)
+	AbandonIdleQueueEntries(ctx context.Context, idleSeconds int64) (int64, error)
+	DeleteFinishedQueueEntries(ctx context.Context) (int64, error)
+
+	// Reports
+	ReportDepartureLoad(ctx context.Context, params ReportDepartureLoadParams) ([]ReportDepartureLoadRow, error)
+	ReportCancellations(ctx context.Context, params ReportCancellationsParams) ([]ReportCancellationsRow, error)
+	ReportBookingChannels(ctx context.Context, params ReportBookingChannelsParams) ([]ReportBookingChannelsRow, error)
+	ReportBookingHours(ctx context.Context, params ReportBookingHoursParams) ([]ReportBookingHoursRow, error)
+
+	// Audit log
+	AppendAuditEvent(ctx context.Context, params AppendAuditEventParams) (AuditEvent, error)
+	GetLastAuditEvent(ctx context.Context) (AuditEvent, error)
+	ListAuditChain(ctx context.Context, params ListAuditChainParams) ([]AuditEvent, error)
+	ListAuditEvents(ctx context.Context, params ListAuditEventsParams) ([]AuditEvent, error)
+
+	// ExecTx runs fn inside a single transaction.
+	ExecTx(ctx context.Context, fn func(QueriesInterface) error) error
 }
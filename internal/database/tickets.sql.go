@@ -8,83 +8,204 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 const createTicket = `-- name: CreateTicket :one
-INSERT INTO tickets (id, train_id, user_id, seat_number)
-SELECT ?1, ?2, ?3, ?4
+INSERT INTO tickets (id, departure_id, user_id, seat_number, origin_stop, destination_stop, fare_amount, fare_currency,
+                     passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13
 WHERE EXISTS (
     SELECT 1 
-    FROM trains t
-    WHERE t.id = ?2 
-    AND ?4 BETWEEN 1 AND t.total_seats
+    FROM departures d
+    JOIN trains t ON d.train_id = t.id
+    WHERE d.id = ?2 
+    AND d.status = 'scheduled'
+    AND d.departs_at > CURRENT_TIMESTAMP
+    AND EXISTS (SELECT 1 FROM seats s WHERE s.train_id = t.id AND s.seat_number = ?4 AND s.retired = 0)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?5)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?6)
+    AND ?5 < ?6
+    AND COALESCE((
+        SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+        FROM sale_windows sw
+        JOIN seats s ON s.train_id = t.id AND s.seat_number = ?4
+        JOIN coaches c ON s.coach_id = c.id
+        WHERE sw.train_id = t.id AND sw.class IN (c.class, '')
+        ORDER BY sw.class DESC
+        LIMIT 1
+    ), '') <= ?14
+    -- Tickets issued directly, on modification or from the waitlist, are general
+    AND NOT EXISTS (
+        SELECT 1 FROM active_quota_seats aq
+        WHERE aq.departure_id = d.id AND aq.seat_number = ?4
+    )
     AND NOT EXISTS (
         SELECT 1 
         FROM tickets tk 
-        WHERE tk.train_id = t.id 
+        WHERE tk.departure_id = d.id 
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
         AND tk.seat_number = ?4
+        AND tk.origin_stop < ?6
+        AND ?5 < tk.destination_stop
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM seat_holds sh
+        WHERE sh.departure_id = d.id
+        AND sh.seat_number = ?4
+        AND sh.origin_stop < ?6
+        AND ?5 < sh.destination_stop
+        AND sh.expires_at > CURRENT_TIMESTAMP
     )
 )
-RETURNING id, train_id, user_id, seat_number, booked_at
+RETURNING id, departure_id, user_id, seat_number, origin_stop, destination_stop, booked_at, fare_amount, fare_currency, passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id, status, quota, concession_ref
 `
 
 type CreateTicketParams struct {
-	ID         string
-	TrainID    string
-	UserID     string
-	SeatNumber int64
+	ID                  string
+	DepartureID         string
+	UserID              string
+	SeatNumber          int64
+	OriginStop          int64
+	DestinationStop     int64
+	FareAmount          int64
+	FareCurrency        string
+	PassengerName       sql.NullString
+	PassengerAge        sql.NullInt64
+	PassengerGender     sql.NullString
+	PassengerIDDocument sql.NullString
+	BookingID           sql.NullString
+	SalesClock          string
 }
 
 func (q *Queries) CreateTicket(ctx context.Context, arg CreateTicketParams) (Ticket, error) {
 	row := q.db.QueryRowContext(ctx, createTicket,
 		arg.ID,
-		arg.TrainID,
+		arg.DepartureID,
 		arg.UserID,
 		arg.SeatNumber,
+		arg.OriginStop,
+		arg.DestinationStop,
+		arg.FareAmount,
+		arg.FareCurrency,
+		arg.PassengerName,
+		arg.PassengerAge,
+		arg.PassengerGender,
+		arg.PassengerIDDocument,
+		arg.BookingID,
+		arg.SalesClock,
 	)
 	var i Ticket
 	err := row.Scan(
 		&i.ID,
-		&i.TrainID,
+		&i.DepartureID,
 		&i.UserID,
 		&i.SeatNumber,
+		&i.OriginStop,
+		&i.DestinationStop,
 		&i.BookedAt,
+		&i.FareAmount,
+		&i.FareCurrency,
+		&i.PassengerName,
+		&i.PassengerAge,
+		&i.PassengerGender,
+		&i.PassengerIDDocument,
+		&i.BookingID,
+		&i.Status,
+		&i.Quota,
+		&i.ConcessionRef,
 	)
 	return i, err
 }
 
-const deleteTicket = `-- name: DeleteTicket :exec
-DELETE FROM tickets
-WHERE id = ? AND user_id = ?
+const getAvailableTickets = `-- name: GetAvailableTickets :many
+SELECT d.id, t.name, d.service_date, d.departs_at, d.arrives_at,
+       o.stop_sequence AS origin_stop, o.departure_offset_minutes,
+       ds.stop_sequence AS destination_stop, ds.arrival_offset_minutes,
+       CAST(ds.distance_km - o.distance_km AS INTEGER) AS distance_km,
+       c.class,
+       CAST(COALESCE((
+           SELECT aq.category FROM active_quota_seats aq
+           WHERE aq.departure_id = d.id AND aq.seat_number = s.seat_number
+       ), 'general') AS TEXT) AS quota,
+       CAST(COALESCE((
+           SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+           FROM sale_windows sw
+           WHERE sw.train_id = d.train_id AND sw.class IN (c.class, '')
+           ORDER BY sw.class DESC
+           LIMIT 1
+       ), '') AS TEXT) AS sales_open_at,
+       COUNT(s.seat_number) AS total_seats, 
+       CAST(SUM(
+           NOT EXISTS (
+               SELECT 1 FROM tickets tk
+               WHERE tk.departure_id = d.id
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               AND tk.seat_number = s.seat_number
+               AND tk.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < tk.destination_stop
+           )
+           AND NOT EXISTS (
+               SELECT 1 FROM seat_holds sh
+               WHERE sh.departure_id = d.id
+               AND sh.seat_number = s.seat_number
+               AND sh.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < sh.destination_stop
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       ) AS INTEGER) AS available_seats
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+JOIN route_stops o ON o.train_id = t.id AND o.station_id = ?1
+JOIN route_stops ds ON ds.train_id = t.id AND ds.station_id = ?2
+JOIN coaches c ON c.train_id = t.id
+JOIN seats s ON s.coach_id = c.id AND s.retired = 0
+WHERE o.stop_sequence < ds.stop_sequence
+AND d.status = 'scheduled'
+AND d.departs_at > CURRENT_TIMESTAMP
+-- Optionally only trains leaving the origin from the first UTC time and before the second
+AND (?3 = '' OR datetime(d.departs_at, '+' || o.departure_offset_minutes || ' minutes') >= ?3)
+AND (?4 = '' OR datetime(d.departs_at, '+' || o.departure_offset_minutes || ' minutes') < ?4)
+GROUP BY d.id, c.class, quota
+HAVING available_seats >= ?5
+ORDER BY d.departs_at, c.class, quota
 `
 
-type DeleteTicketParams struct {
-	ID     string
-	UserID string
-}
-
-func (q *Queries) DeleteTicket(ctx context.Context, arg DeleteTicketParams) error {
-	_, err := q.db.ExecContext(ctx, deleteTicket, arg.ID, arg.UserID)
-	return err
+type GetAvailableTicketsParams struct {
+	OriginStationID      string
+	DestinationStationID string
+	DepartsFrom          string
+	DepartsBefore        string
+	Passengers           int64
 }
 
-const getAvailableTickets = `-- name: GetAvailableTickets :many
-SELECT t.id, t.name, t.total_seats, 
-       (t.total_seats - COUNT(tk.id)) AS available_seats
-FROM trains t
-LEFT JOIN tickets tk ON t.id = tk.train_id
-GROUP BY t.id, t.name, t.total_seats
-`
-
 type GetAvailableTicketsRow struct {
-	ID             string
-	Name           string
-	TotalSeats     int64
-	AvailableSeats interface{}
+	ID                     string
+	Name                   string
+	ServiceDate            string
+	DepartsAt              time.Time
+	ArrivesAt              time.Time
+	OriginStop             int64
+	DepartureOffsetMinutes int64
+	DestinationStop        int64
+	ArrivalOffsetMinutes   int64
+	DistanceKm             int64
+	Class                  string
+	Quota                  string
+	SalesOpenAt            string
+	TotalSeats             int64
+	AvailableSeats         int64
 }
 
-func (q *Queries) GetAvailableTickets(ctx context.Context) ([]GetAvailableTicketsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAvailableTickets)
+func (q *Queries) GetAvailableTickets(ctx context.Context, arg GetAvailableTicketsParams) ([]GetAvailableTicketsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAvailableTickets,
+		arg.OriginStationID,
+		arg.DestinationStationID,
+		arg.DepartsFrom,
+		arg.DepartsBefore,
+		arg.Passengers,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -95,6 +216,17 @@ func (q *Queries) GetAvailableTickets(ctx context.Context) ([]GetAvailableTicket
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
+			&i.ServiceDate,
+			&i.DepartsAt,
+			&i.ArrivesAt,
+			&i.OriginStop,
+			&i.DepartureOffsetMinutes,
+			&i.DestinationStop,
+			&i.ArrivalOffsetMinutes,
+			&i.DistanceKm,
+			&i.Class,
+			&i.Quota,
+			&i.SalesOpenAt,
 			&i.TotalSeats,
 			&i.AvailableSeats,
 		); err != nil {
@@ -111,22 +243,139 @@ func (q *Queries) GetAvailableTickets(ctx context.Context) ([]GetAvailableTicket
 	return items, nil
 }
 
+const getTicket = `-- name: GetTicket :one
+SELECT tk.id, tk.user_id, tk.booking_id, tk.status, tk.departure_id, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, o.departure_offset_minutes,
+       sd.name AS destination_name, ds.arrival_offset_minutes,
+       tk.seat_number, s.label AS seat_label, c.class,
+       tk.fare_amount, tk.fare_currency, tk.passenger_name,
+       tk.passenger_age, tk.passenger_gender, tk.passenger_id_document,
+       o.station_id AS origin_station_id, ds.station_id AS destination_station_id,
+       b.pnr
+FROM tickets tk
+LEFT JOIN bookings b ON tk.booking_id = b.id
+JOIN departures d ON tk.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.id = ?
+`
+
+type GetTicketRow struct {
+	ID                     string
+	UserID                 string
+	BookingID              sql.NullString
+	Status                 string
+	DepartureID            string
+	Name                   string
+	ServiceDate            string
+	DepartsAt              time.Time
+	OriginName             string
+	DepartureOffsetMinutes int64
+	DestinationName        string
+	ArrivalOffsetMinutes   int64
+	SeatNumber             int64
+	SeatLabel              string
+	Class                  string
+	FareAmount             int64
+	FareCurrency           string
+	PassengerName          sql.NullString
+	PassengerAge           sql.NullInt64
+	PassengerGender        sql.NullString
+	PassengerIDDocument    sql.NullString
+	OriginStationID        string
+	DestinationStationID   string
+	Pnr                    sql.NullString
+}
+
+func (q *Queries) GetTicket(ctx context.Context, id string) (GetTicketRow, error) {
+	row := q.db.QueryRowContext(ctx, getTicket, id)
+	var i GetTicketRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.BookingID,
+		&i.Status,
+		&i.DepartureID,
+		&i.Name,
+		&i.ServiceDate,
+		&i.DepartsAt,
+		&i.OriginName,
+		&i.DepartureOffsetMinutes,
+		&i.DestinationName,
+		&i.ArrivalOffsetMinutes,
+		&i.SeatNumber,
+		&i.SeatLabel,
+		&i.Class,
+		&i.FareAmount,
+		&i.FareCurrency,
+		&i.PassengerName,
+		&i.PassengerAge,
+		&i.PassengerGender,
+		&i.PassengerIDDocument,
+		&i.OriginStationID,
+		&i.DestinationStationID,
+		&i.Pnr,
+	)
+	return i, err
+}
+
 const getUserTickets = `-- name: GetUserTickets :many
-SELECT tk.id, t.name, tk.seat_number, tk.booked_at
+SELECT tk.id, b.pnr, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, o.departure_offset_minutes,
+       sd.name AS destination_name, ds.arrival_offset_minutes,
+       tk.seat_number, s.label AS seat_label, c.class,
+       tk.fare_amount, tk.fare_currency, tk.booked_at,
+       tk.passenger_name, tk.passenger_age, tk.status, cn.refund_amount
 FROM tickets tk
-JOIN trains t ON tk.train_id = t.id
-WHERE tk.user_id = ?
+JOIN bookings b ON tk.booking_id = b.id
+LEFT JOIN cancellations cn ON cn.ticket_id = tk.id
+JOIN departures d ON tk.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.user_id = ?1
+AND (?2 = '' OR tk.status = ?2)
+ORDER BY d.departs_at
 `
 
+type GetUserTicketsParams struct {
+	UserID string
+	Status string
+}
+
 type GetUserTicketsRow struct {
-	ID         string
-	Name       string
-	SeatNumber int64
-	BookedAt   sql.NullTime
+	ID                     string
+	Pnr                    string
+	Name                   string
+	ServiceDate            string
+	DepartsAt              time.Time
+	OriginName             string
+	DepartureOffsetMinutes int64
+	DestinationName        string
+	ArrivalOffsetMinutes   int64
+	SeatNumber             int64
+	SeatLabel              string
+	Class                  string
+	FareAmount             int64
+	FareCurrency           string
+	BookedAt               sql.NullTime
+	PassengerName          sql.NullString
+	PassengerAge           sql.NullInt64
+	Status                 string
+	RefundAmount           sql.NullInt64
 }
 
-func (q *Queries) GetUserTickets(ctx context.Context, userID string) ([]GetUserTicketsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getUserTickets, userID)
+func (q *Queries) GetUserTickets(ctx context.Context, arg GetUserTicketsParams) ([]GetUserTicketsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserTickets, arg.UserID, arg.Status)
 	if err != nil {
 		return nil, err
 	}
@@ -136,9 +385,24 @@ func (q *Queries) GetUserTickets(ctx context.Context, userID string) ([]GetUserT
 		var i GetUserTicketsRow
 		if err := rows.Scan(
 			&i.ID,
+			&i.Pnr,
 			&i.Name,
+			&i.ServiceDate,
+			&i.DepartsAt,
+			&i.OriginName,
+			&i.DepartureOffsetMinutes,
+			&i.DestinationName,
+			&i.ArrivalOffsetMinutes,
 			&i.SeatNumber,
+			&i.SeatLabel,
+			&i.Class,
+			&i.FareAmount,
+			&i.FareCurrency,
 			&i.BookedAt,
+			&i.PassengerName,
+			&i.PassengerAge,
+			&i.Status,
+			&i.RefundAmount,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// txBeginner is implemented by *sql.DB; a Queries bound to a *sql.Tx does not implement it.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// ExecTx runs fn inside a single database transaction, committing if fn returns nil
+// and rolling back otherwise. When q is already bound to a transaction, fn joins it.
+func (q *Queries) ExecTx(ctx context.Context, fn func(QueriesInterface) error) error {
+	db, ok := q.db.(txBeginner)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(q.WithTx(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,188 @@
+package eticket
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// ValidityAfterArrival is how long an e-ticket stays valid after the train is due
+// at the passenger's destination
+const ValidityAfterArrival = 6 * time.Hour
+
+var (
+	ErrMalformed        = errors.New("malformed e-ticket")
+	ErrInvalidSignature = errors.New("e-ticket signature does not verify")
+	ErrExpired          = errors.New("e-ticket has expired")
+)
+
+// Payload is what an e-ticket carries. Keys are kept short so the QR code stays
+// small enough to scan from a phone screen.
+type Payload struct {
+	TicketID    string `json:"t"`
+	DepartureID string `json:"d"`
+	Seat        string `json:"s"`
+	Passenger   string `json:"p"`
+	ExpiresAt   int64  `json:"x"` // Unix seconds
+}
+
+// Signer issues e-tickets with the operator's private key
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner returns a signer for an ed25519 private key
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key}
+}
+
+// GenerateSigner returns a signer with a fresh random key. E-tickets it signs stop
+// verifying once the process exits, so it is only for development.
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(key), nil
+}
+
+// ParsePrivateKey decodes a base64 ed25519 seed, as given in ETICKET_SIGNING_KEY
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be a %d byte seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// PublicKey is the key verifiers need, base64 encoded
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign encodes a payload as "<payload>.<signature>", both base64url without padding
+func (s *Signer) Sign(p Payload) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	sig := ed25519.Sign(s.key, []byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks an e-ticket against the operator's public key and returns its
+// payload. It needs no access to the booking system.
+func Verify(publicKey ed25519.PublicKey, token string, now time.Time) (Payload, error) {
+	encoded, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return Payload{}, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return Payload{}, ErrMalformed
+	}
+	if !ed25519.Verify(publicKey, []byte(encoded), sig) {
+		return Payload{}, ErrInvalidSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Payload{}, ErrMalformed
+	}
+	var p Payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Payload{}, ErrMalformed
+	}
+	if now.Unix() > p.ExpiresAt {
+		return p, ErrExpired
+	}
+	return p, nil
+}
+
+// Verify checks an e-ticket against the signer's own public key
+func (s *Signer) Verify(token string, now time.Time) (Payload, error) {
+	return Verify(s.key.Public().(ed25519.PublicKey), token, now)
+}
+
+// QRCode renders an e-ticket as a PNG QR code
+func QRCode(token string, size int) ([]byte, error) {
+	return qrcode.Encode(token, qrcode.Medium, size)
+}
+
+// Details is the human-readable part of a printed e-ticket. Times are printed in
+// their own location, so pass them on the operator's clock.
+type Details struct {
+	PNR         string
+	Passenger   string
+	Train       string
+	Date        string
+	From        string
+	To          string
+	Seat        string
+	Class       string
+	Fare        string
+	DepartsAt   time.Time
+	ArrivesAt   time.Time
+	TicketID    string
+	ValidBefore time.Time
+}
+
+// WritePDF renders a printable e-ticket with its QR code
+func WritePDF(w io.Writer, d Details, token string) error {
+	png, err := QRCode(token, 512)
+	if err != nil {
+		return err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("E-ticket "+d.PNR, true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, "E-ticket", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.CellFormat(0, 8, tr("Booking reference: "+d.PNR), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	rows := [][2]string{
+		{"Passenger", d.Passenger},
+		{"Train", d.Train},
+		{"Date", d.Date},
+		{"From", d.From + " " + d.DepartsAt.Format("15:04")},
+		{"To", d.To + " " + d.ArrivesAt.Format("Jan 2 15:04")},
+		{"Seat", d.Seat + " (" + d.Class + ")"},
+		{"Fare", d.Fare},
+		{"Ticket", d.TicketID},
+		{"Valid until", d.ValidBefore.Format("2006-01-02 15:04 MST")},
+	}
+	for _, row := range rows {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(35, 7, row[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 7, tr(row[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("qr", options, bytes.NewReader(png))
+	pdf.ImageOptions("qr", pdf.GetX(), pdf.GetY(), 70, 70, true, options, 0, "")
+	pdf.SetFont("Helvetica", "I", 9)
+	pdf.MultiCell(0, 5, "Show this code to the conductor. It can be checked without a network connection.", "", "L", false)
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,53 @@
+package eticket
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer, err := GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(signer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	want := Payload{TicketID: "t1", DepartureID: "d1", Seat: "CC1-3", Passenger: "Asha Rao", ExpiresAt: now.Add(time.Hour).Unix()}
+	token, err := signer.Sign(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Verify(ed25519.PublicKey(publicKey), token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Verify() = %+v, want %+v", got, want)
+	}
+
+	if _, err := Verify(ed25519.PublicKey(publicKey), token, now.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() after expiry error = %v, want %v", err, ErrExpired)
+	}
+
+	tampered := "x" + token[1:]
+	if _, err := Verify(ed25519.PublicKey(publicKey), tampered, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of tampered token error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	other, err := GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := base64.StdEncoding.DecodeString(other.PublicKey())
+	if _, err := Verify(ed25519.PublicKey(otherKey), token, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() with another key error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
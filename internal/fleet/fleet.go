@@ -0,0 +1,55 @@
+package fleet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MaxCoachSeats is the most seats a coach can be given
+const MaxCoachSeats = 120
+
+// Seat is how a new seat in a coach is labelled and what it offers
+type Seat struct {
+	Label    string
+	IsWindow bool
+	IsAisle  bool
+	// BerthType is empty for chair seats
+	BerthType       string
+	WheelchairSpace bool
+}
+
+var sleeperBerths = [...]string{"lower", "middle", "upper", "lower", "middle", "upper", "side_lower", "side_upper"}
+
+// Layout describes the n'th seat of a coach, counting from 1, the same way the default
+// trains were laid out: chair coaches are 2+2 (window, aisle, aisle, window), sleeper
+// bays hold lower, middle and upper berths on each side plus a side lower and side
+// upper, and the first seat of a second class coach is a wheelchair space.
+func Layout(code, class string, n int) Seat {
+	seat := Seat{Label: code + "-" + strconv.Itoa(n)}
+	i := n - 1
+	if class == "sleeper" {
+		seat.BerthType = sleeperBerths[i%8]
+		seat.IsWindow = i%8 == 6
+		seat.IsAisle = i%8 == 6 || i%8 == 7
+		return seat
+	}
+	seat.IsWindow = i%4 == 0 || i%4 == 3
+	seat.IsAisle = i%4 == 1 || i%4 == 2
+	seat.WheelchairSpace = class == "second" && n == 1
+	return seat
+}
+
+// ParseCoachCode checks a coach code, such as S1 or CC2, and normalizes it to upper case
+func ParseCoachCode(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if code == "" || len(code) > 8 {
+		return "", errors.New("coach code must be 1 to 8 letters or digits")
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", errors.New("coach code must be 1 to 8 letters or digits")
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,40 @@
+package fleet
+
+import "testing"
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		class string
+		n     int
+		want  Seat
+	}{
+		{"chair window", "ac_chair", 1, Seat{Label: "C1-1", IsWindow: true}},
+		{"chair aisle", "ac_chair", 2, Seat{Label: "C1-2", IsAisle: true}},
+		{"chair far window", "ac_chair", 8, Seat{Label: "C1-8", IsWindow: true}},
+		{"second class wheelchair space", "second", 1, Seat{Label: "C1-1", IsWindow: true, WheelchairSpace: true}},
+		{"second class after the first seat", "second", 5, Seat{Label: "C1-5", IsWindow: true}},
+		{"sleeper lower", "sleeper", 1, Seat{Label: "C1-1", BerthType: "lower"}},
+		{"sleeper side lower", "sleeper", 7, Seat{Label: "C1-7", BerthType: "side_lower", IsWindow: true, IsAisle: true}},
+		{"sleeper next bay", "sleeper", 11, Seat{Label: "C1-11", BerthType: "upper"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Layout("C1", tt.class, tt.n); got != tt.want {
+				t.Errorf("Layout() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCoachCode(t *testing.T) {
+	if code, err := ParseCoachCode(" s1 "); err != nil || code != "S1" {
+		t.Errorf("ParseCoachCode() = %q, %v, want S1", code, err)
+	}
+	for _, bad := range []string{"", "S-1", "COACHNINE"} {
+		if _, err := ParseCoachCode(bad); err == nil {
+			t.Errorf("ParseCoachCode(%q) accepted an invalid code", bad)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditPageSize is how many events the audit log shows at a time
+const auditPageSize = 100
+
+// auditEventView is an audit event as the admin API returns it
+type auditEventView struct {
+	Seq        int64     `json:"seq"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target"`
+	NodeID     string    `json:"node_id"`
+	Clock      int64     `json:"lamport_clock"`
+	IP         string    `json:"ip"`
+	Result     string    `json:"result"`
+	Detail     string    `json:"detail,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+// auditVerification is the outcome of checking the audit log's hash chain
+type auditVerification struct {
+	Verified int64  `json:"verified"`
+	Intact   bool   `json:"intact"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RecordAudit adds an event from a request to the audit log, stamped with this node's
+// clock and, unless the event already names one, the client's address. The request goes
+// ahead even if it can't be recorded.
+func RecordAudit(appState *app.AppState, r *http.Request, e audit.Entry) {
+	if e.IP == "" {
+		e.IP = ratelimit.ClientIP(r)
+	}
+	_, err := audit.Append(r.Context(), appState.DB, appState.Node.ID, appState.Node.Tick(), e, time.Now())
+	if err != nil {
+		log.Printf("Error recording %s audit event for %s: %v", e.Action, e.Actor, err)
+	}
+}
+
+// auditResult is the result to record for an action that ended with err
+func auditResult(err error) string {
+	if err != nil {
+		return audit.Failure
+	}
+	return audit.Success
+}
+
+// auditBodyLimit is as much of a JSON request or response as AuditChanges reads, the
+// same as the admin API handlers accept
+const auditBodyLimit = 64 << 10
+
+// statusRecorder notes the status a handler responds with, and the start of a JSON
+// response, which names what a request created
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") && rec.body.Len() < auditBodyLimit {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// jsonChange decodes a JSON request body for the audit log, leaving the body in place
+// for the handler. Forms and anything that isn't a JSON object give nil.
+func jsonChange(r *http.Request) map[string]interface{} {
+	contentType := r.Header.Get("Content-Type")
+	if r.Body == nil || strings.HasPrefix(contentType, "application/x-www-form-urlencoded") || strings.HasPrefix(contentType, "multipart/form-data") {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, auditBodyLimit))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return nil
+	}
+	var change map[string]interface{}
+	if err := json.Unmarshal(body, &change); err != nil {
+		return nil
+	}
+	return change
+}
+
+// auditEntityID is the ID of what an admin request acted on: named in its URL, form or
+// JSON body, or for a create, in the JSON response
+func auditEntityID(r *http.Request, change map[string]interface{}, response []byte) string {
+	if id := r.URL.Query().Get("id"); id != "" {
+		return id
+	}
+	if id := r.PostForm.Get("id"); id != "" {
+		return id
+	}
+	if id, ok := change["id"].(string); ok && id != "" {
+		return id
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(response, &created); err != nil {
+		return ""
+	}
+	return created.ID
+}
+
+// AuditChanges records every request that could change something, that is anything
+// but GET and HEAD, as an admin event, with the ID of what it changed and, for API
+// calls, the change itself. It goes before RequirePermission so that refused attempts
+// are recorded too.
+func AuditChanges(appState *app.AppState) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			change := jsonChange(r)
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			// Forms on the admin pages say what they do in their action field, API
+			// calls in their JSON body
+			detail := r.Method
+			if action := r.PostForm.Get("action"); action != "" {
+				detail += " " + action
+			}
+			detail += " " + strconv.Itoa(rec.status)
+			if id := auditEntityID(r, change, rec.body.Bytes()); id != "" {
+				detail += " id=" + id
+			}
+			if change != nil {
+				if encoded, err := json.Marshal(change); err == nil {
+					detail += " " + string(encoded)
+				}
+			}
+
+			e := audit.Entry{
+				Action: audit.Admin,
+				Target: r.URL.RequestURI(),
+				Result: audit.Success,
+				Detail: detail,
+			}
+			if session, err := appState.Store.Get(r, "session-name"); err == nil {
+				e.Actor, _ = session.Values["userID"].(string)
+			}
+			if rec.status >= http.StatusBadRequest {
+				e.Result = audit.Failure
+			}
+			RecordAudit(appState, r, e)
+		})
+	}
+}
+
+// auditSpan turns the audit log's date filters, days on the operator's calendar in
+// loc, into the UTC instants ListAuditEvents compares with. Either may be empty.
+func auditSpan(from, to string, loc *time.Location) (string, string, error) {
+	var start, end string
+	if from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return "", "", err
+		}
+		start = t.UTC().Format("2006-01-02 15:04:05")
+	}
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return "", "", err
+		}
+		end = t.AddDate(0, 0, 1).UTC().Format("2006-01-02 15:04:05")
+	}
+	return start, end, nil
+}
+
+// HandleAuditLog lets admins search the audit log, newest first, by actor, action,
+// target, result, node and a range of dates, with format=json for the API. With
+// verify=1 it also checks the whole hash chain.
+func HandleAuditLog(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	params := database.ListAuditEventsParams{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Result: query.Get("result"),
+		NodeID: query.Get("node"),
+		From:   query.Get("from"),
+		To:     query.Get("to"),
+		Limit:  auditPageSize,
+	}
+
+	// The form shows the dates as given; the query takes the instants they span
+	filter := params
+	var filterError string
+	from, to, err := auditSpan(params.From, params.To, appState.Location)
+	if err != nil {
+		filterError = "Dates must be in YYYY-MM-DD form"
+	}
+	params.From, params.To = from, to
+	if before := query.Get("before"); before != "" {
+		seq, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || seq < 1 {
+			filterError = "Invalid page"
+		}
+		params.BeforeSeq = seq
+	}
+	if filterError != "" && format == "json" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": filterError})
+		return
+	}
+
+	events := []database.AuditEvent{}
+	if filterError == "" {
+		events, err = appState.DB.ListAuditEvents(r.Context(), params)
+		if err != nil {
+			log.Println("Error fetching audit events:", err)
+			http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var verification *auditVerification
+	if query.Get("verify") != "" {
+		checked, err := audit.VerifyChain(r.Context(), appState.DB)
+		var b *audit.Break
+		if err != nil && !errors.As(err, &b) {
+			log.Println("Error verifying audit log:", err)
+			http.Error(w, "Failed to verify audit log", http.StatusInternalServerError)
+			return
+		}
+		verification = &auditVerification{Verified: checked, Intact: b == nil}
+		if b != nil {
+			log.Println("Audit log failed verification:", b)
+			verification.BrokenAt = b.Seq
+			verification.Reason = b.Reason
+		}
+	}
+
+	// The next page continues below the oldest event shown
+	var older string
+	if len(events) == auditPageSize {
+		next := url.Values{}
+		for key, values := range query {
+			next[key] = values
+		}
+		next.Del("verify")
+		next.Set("before", strconv.FormatInt(events[len(events)-1].Seq, 10))
+		older = "/admin/audit?" + next.Encode()
+	}
+
+	if format == "json" {
+		views := make([]auditEventView, 0, len(events))
+		for _, e := range events {
+			views = append(views, auditEventView{
+				Seq:        e.Seq,
+				OccurredAt: e.OccurredAt,
+				Actor:      e.Actor,
+				Action:     e.Action,
+				Target:     e.Target,
+				NodeID:     e.NodeID,
+				Clock:      e.LamportClock,
+				IP:         e.Ip,
+				Result:     e.Result,
+				Detail:     e.Detail,
+				PrevHash:   e.PrevHash,
+				Hash:       e.Hash,
+			})
+		}
+		response := map[string]interface{}{"events": views}
+		if older != "" {
+			response["next_before"] = events[len(events)-1].Seq
+		}
+		if verification != nil {
+			response["verification"] = verification
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "audit.html", map[string]interface{}{
+		"Events":       events,
+		"Filter":       filter,
+		"TimeZone":     appState.Location.String(),
+		"Actions":      audit.Actions,
+		"Verification": verification,
+		"Older":        older,
+		"Error":        filterError,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
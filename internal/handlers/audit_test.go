@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/pricing"
+)
+
+func (pt *paymentTest) auditEvents(t *testing.T, action string) []database.AuditEvent {
+	t.Helper()
+	events, err := pt.appState.DB.ListAuditEvents(context.Background(), database.ListAuditEventsParams{
+		Action: action,
+		Limit:  auditPageSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestAuditChangesRecordsJSONChange(t *testing.T) {
+	pt := newTestApp(t)
+
+	created := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Class string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Class != "sleeper" {
+			t.Errorf("handler read %+v (%v), want the body AuditChanges left in place", req, err)
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": "quota-1"})
+	})
+	handler := AuditChanges(pt.appState)(created)
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/quotas", strings.NewReader(`{"class": "sleeper", "seats": 4}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.AddCookie(pt.cookie)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	events := pt.auditEvents(t, audit.Admin)
+	if len(events) != 1 {
+		t.Fatalf("got %d admin events, want 1", len(events))
+	}
+	want := `POST 201 id=quota-1 {"class":"sleeper","seats":4}`
+	if e := events[0]; e.Detail != want || e.Actor != pt.userID {
+		t.Errorf("event = %q by %q, want %q by the user", e.Detail, e.Actor, want)
+	}
+}
+
+func TestWebhookBookingAuditedFromCustomerAddress(t *testing.T) {
+	pt := newTestApp(t)
+	// Captures complete by webhook, which the test sends itself
+	pt.fake.WebhookURL = "http://127.0.0.1:0/payments/webhook"
+	pt.fake.AsyncDelay = time.Hour
+	hold := pt.holdSeat(t, 1)
+
+	form := strings.NewReader("hold_id=" + hold.ID + "&payment_source=" + payment.FakeCardAsyncCaptured)
+	r := httptest.NewRequest(http.MethodPost, "/book/confirm", form)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = "203.0.113.7:50123"
+	r.AddCookie(pt.cookie)
+	HandleConfirmBooking(pt.appState, httptest.NewRecorder(), r)
+
+	p := pt.holdPayment(t, hold.ID)
+	if p.Status != string(payment.Authorized) {
+		t.Fatalf("payment = %+v, want authorized until the webhook", p)
+	}
+
+	body, signature, err := pt.fake.Webhook(payment.Event{PaymentID: p.ID, Ref: p.ProviderRef.String, Status: payment.Captured})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	r.RemoteAddr = "198.51.100.1:443"
+	r.Header.Set(payment.SignatureHeader, signature)
+	w := httptest.NewRecorder()
+	HandlePaymentWebhook(pt.appState, w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("webhook got %d, want 200", w.Code)
+	}
+
+	events := pt.auditEvents(t, audit.Booking)
+	if len(events) != 1 || events[0].Result != audit.Success {
+		t.Fatalf("got booking events %+v, want one success", events)
+	}
+	if events[0].Ip != "203.0.113.7" {
+		t.Errorf("booking audited from %q, want the customer's 203.0.113.7", events[0].Ip)
+	}
+}
+
+// modify posts the change ticket form
+func (pt *paymentTest) modify(ticketID string, form url.Values) *httptest.ResponseRecorder {
+	form.Set("ticket_id", ticketID)
+	r := httptest.NewRequest(http.MethodPost, "/modify", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(pt.cookie)
+	w := httptest.NewRecorder()
+	HandleModifyTicket(pt.appState, w, r)
+	return w
+}
+
+func TestModificationsAudited(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+	pt.confirm(hold.ID, "4242424242424242")
+	old := pt.holdPayment(t, hold.ID).TicketID.String
+
+	w := pt.modify(old, url.Values{"departure": {testDepartureID + "/ac_chair"}, "seat_number": {"999"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refused change got %d, want the form again", w.Code)
+	}
+	w = pt.modify(old, url.Values{"departure": {testDepartureID + "/ac_chair"}, "seat_number": {"2"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("change got %d, want a redirect", w.Code)
+	}
+
+	events := pt.auditEvents(t, audit.Modification)
+	if len(events) != 2 {
+		t.Fatalf("got %d modification events, want 2", len(events))
+	}
+	// Newest first
+	changed, refused := events[0], events[1]
+	var replacement string
+	var difference int64
+	err := pt.db.QueryRow("SELECT new_ticket_id, fare_difference FROM ticket_modifications WHERE old_ticket_id = ?", old).Scan(&replacement, &difference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "to ticket:" + replacement + " fare difference " + pricing.FormatAmount(difference, "INR")
+	if changed.Result != audit.Success || changed.Target != "ticket:"+old || changed.Detail != want {
+		t.Errorf("change audited as %s %s %q, want success on ticket:%s %q", changed.Result, changed.Target, changed.Detail, old, want)
+	}
+	if refused.Result != audit.Failure || refused.Target != "ticket:"+old || !strings.HasPrefix(refused.Detail, "to departure:"+testDepartureID) {
+		t.Errorf("refused change audited as %s %s %q", refused.Result, refused.Target, refused.Detail)
+	}
+}
+
+func TestAuditDateFiltersUseOperatorCalendar(t *testing.T) {
+	pt := newTestApp(t)
+	pt.appState.Location = time.FixedZone("IST", 5*60*60+30*60)
+
+	// 20:00 UTC is half past one the next morning for the operator
+	at := time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC)
+	_, err := audit.Append(context.Background(), pt.appState.DB, "node-1", 1, audit.Entry{Actor: "a", Action: audit.Login, Result: audit.Success}, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for date, want := range map[string]int{"2026-03-10": 1, "2026-03-09": 0} {
+		r := httptest.NewRequest(http.MethodGet, "/admin/audit?format=json&from="+date+"&to="+date, nil)
+		w := httptest.NewRecorder()
+		HandleAuditLog(pt.appState, w, r)
+		var got struct{ Events []auditEventView }
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Events) != want {
+			t.Errorf("%s: got %d events, want %d", date, len(got.Events), want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/refund"
+	"rsvbackend/internal/ticketstate"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// refundFor quotes the refund for cancelling a ticket now
+func refundFor(policy *refund.Policy, ticket database.GetTicketRow) refund.Quote {
+	return policy.Quote(refund.Input{
+		Class:       ticket.Class,
+		FareAmount:  ticket.FareAmount,
+		DepartsAt:   ticket.OriginDepartsAt(),
+		CancelledAt: time.Now(),
+	})
+}
+
+// cancelTicket cancels a ticket, records and queues the refund given and hands the
+// seat to the waitlist in one transaction, then makes the refund. The refund is capped
+// at what is left of the payments for the ticket's booking. It must run inside the
+// critical section.
+// sql.ErrNoRows means the ticket was no longer confirmed.
+func cancelTicket(ctx context.Context, appState *app.AppState, ticket database.GetTicketRow, channel, actor string) (refund.Quote, error) {
+	quote := refundFor(appState.Refunds, ticket)
+	var refunds []string
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		freed, err := ticketstate.Transition(ctx, q, ticketstate.Change{
+			TicketID: ticket.ID,
+			From:     ticketstate.Confirmed,
+			To:       ticketstate.Cancelled,
+			Actor:    actor,
+			Reason:   "cancelled via " + channel,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The policy quotes on the ticket's fare, but no more can go back than is left
+		// of what was paid for its booking
+		refundable, err := refundableAmount(ctx, q, ticket.ID)
+		if err != nil {
+			return err
+		}
+		quote.Amount = min(quote.Amount, refundable)
+
+		_, err = q.CreateCancellation(ctx, database.CreateCancellationParams{
+			ID:                   uuid.New().String(),
+			TicketID:             ticket.ID,
+			Channel:              channel,
+			HoursBeforeDeparture: quote.HoursBefore,
+			FareAmount:           quote.Fare,
+			RefundPercent:        quote.RefundPercent,
+			FeeAmount:            quote.Fee,
+			RefundAmount:         quote.Amount,
+			Currency:             ticket.FareCurrency,
+		})
+		if err != nil {
+			return err
+		}
+
+		if quote.Amount > 0 {
+			_, err = ticketstate.Transition(ctx, q, ticketstate.Change{
+				TicketID: ticket.ID,
+				From:     ticketstate.Cancelled,
+				To:       ticketstate.Refunded,
+				Actor:    actor,
+				Reason:   "refund issued",
+			})
+			if err != nil {
+				return err
+			}
+			refunds, err = refundTicket(ctx, q, ticket.ID, quote.Amount)
+			if err != nil {
+				return err
+			}
+		}
+
+		return promoteWaitlist(ctx, appState, q, freed.DepartureID, freed.SeatNumber)
+	})
+	if err == nil {
+		makeRefunds(ctx, appState, refunds...)
+	}
+	return quote, err
+}
+
+// cancellationAudit is the audit event for cancelling a ticket. sql.ErrNoRows means
+// someone else cancelled it first.
+func cancellationAudit(actor string, ticket database.GetTicketRow, quote refund.Quote, err error) audit.Entry {
+	e := audit.Entry{Actor: actor, Action: audit.Cancellation, Target: "ticket:" + ticket.ID, Result: auditResult(err)}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		e.Detail = "already cancelled"
+	case err == nil:
+		e.Detail = "refund " + pricing.FormatAmount(quote.Amount, ticket.FareCurrency)
+	}
+	return e
+}
+
+// renderCancelTicket asks the user to confirm a cancellation, showing the refund it
+// would give. data carries the form action and any fields the form must resubmit.
+func renderCancelTicket(appState *app.AppState, w http.ResponseWriter, ticket database.GetTicketRow, data map[string]interface{}) {
+	data["Ticket"] = ticket
+	data["Refund"] = refundFor(appState.Refunds, ticket)
+	err := appState.Templates.ExecuteTemplate(w, "cancel.html", data)
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
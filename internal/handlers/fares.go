@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/pricing"
+	"time"
+)
+
+// departureOption is an available class on a departure together with its current fare
+type departureOption struct {
+	database.GetAvailableTicketsRow
+	Fare      pricing.Quote
+	salesOpen time.Time
+}
+
+// quoteDepartures prices each available departure and class for display on the
+// booking form, reading sale windows on the operator's clock in loc
+func quoteDepartures(rules *pricing.Rules, loc *time.Location, departures []database.GetAvailableTicketsRow) []departureOption {
+	options := make([]departureOption, 0, len(departures))
+	for _, d := range departures {
+		options = append(options, departureOption{
+			GetAvailableTicketsRow: d,
+			salesOpen:              database.ParseSalesOpenAt(d.SalesOpenAt, loc),
+			Fare: rules.Quote(pricing.Input{
+				Class:          d.Class,
+				DistanceKm:     d.DistanceKm,
+				DepartsAt:      d.OriginDepartsAt(),
+				TotalSeats:     d.TotalSeats,
+				AvailableSeats: d.AvailableSeats,
+			}),
+		})
+	}
+	return options
+}
+
+// distinctDepartures drops the per-class duplicates from availability rows
+func distinctDepartures(departures []database.GetAvailableTicketsRow) []database.GetAvailableTicketsRow {
+	var distinct []database.GetAvailableTicketsRow
+	seen := make(map[string]bool)
+	for _, d := range departures {
+		if !seen[d.ID] {
+			seen[d.ID] = true
+			distinct = append(distinct, d)
+		}
+	}
+	return distinct
+}
+
+// quoteSegment prices a class on a journey between two stops of a departure as it stands now
+func quoteSegment(ctx context.Context, q database.QueriesInterface, rules *pricing.Rules, departureID, class string, originStop, destinationStop int64) (pricing.Quote, error) {
+	inputs, err := q.GetSegmentFareInputs(ctx, database.GetSegmentFareInputsParams{
+		DepartureID:     departureID,
+		OriginStop:      originStop,
+		DestinationStop: destinationStop,
+		Class:           class,
+	})
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	return rules.Quote(pricing.Input{
+		Class:          class,
+		DistanceKm:     inputs.DistanceKm,
+		DepartsAt:      inputs.OriginDepartsAt(),
+		TotalSeats:     inputs.TotalSeats,
+		AvailableSeats: inputs.AvailableSeats,
+	}), nil
+}
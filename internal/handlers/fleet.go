@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/fleet"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errTrainNotFound     = errors.New("no such train, or it is retired")
+	errCoachNotFound     = errors.New("no such coach")
+	errDepartureNotFound = errors.New("no such departure, or it has already left or been cancelled")
+	errBelowSoldSeats    = errors.New("capacity can't go below the seats already sold")
+	errSeatsSold         = errors.New("seats are sold on it; cancel those tickets first")
+	errArrivalTime       = errors.New("the train must arrive after it departs")
+	errDepartureInPast   = errors.New("the departure must be in the future")
+)
+
+// maxTrainNameLength is the longest name a train can be given
+const maxTrainNameLength = 100
+
+// adminDepartureLimit is how many departures of a train the admin views list
+const adminDepartureLimit = 100
+
+// coachRequest adds a coach to a train, or resizes an existing one when ID is set
+type coachRequest struct {
+	ID      string `json:"id,omitempty"`
+	TrainID string `json:"train_id,omitempty"`
+	Code    string `json:"code"`
+	Class   string `json:"class"`
+	Seats   int64  `json:"seats"`
+}
+
+// coachView is a coach as the admin API returns it. SoldSeats are those with a ticket
+// or hold on a departure that hasn't arrived yet, which the coach can't shrink below.
+type coachView struct {
+	ID        string `json:"id"`
+	TrainID   string `json:"train_id"`
+	Code      string `json:"code"`
+	Class     string `json:"class"`
+	Position  int64  `json:"position"`
+	Seats     int64  `json:"seats"`
+	SoldSeats int64  `json:"sold_seats"`
+}
+
+// trainView is a train and its composition as the admin API returns it
+type trainView struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Status     string      `json:"status"`
+	TotalSeats int64       `json:"total_seats"`
+	Coaches    []coachView `json:"coaches"`
+}
+
+// departureView is a departure as the admin API returns it
+type departureView struct {
+	ID          string    `json:"id"`
+	TrainID     string    `json:"train_id"`
+	ServiceDate string    `json:"service_date"`
+	DepartsAt   time.Time `json:"departs_at"`
+	ArrivesAt   time.Time `json:"arrives_at"`
+	Status      string    `json:"status"`
+	RuleID      string    `json:"rule_id,omitempty"`
+	SoldSeats   int64     `json:"sold_seats"`
+}
+
+// parseTrainName validates a train's name
+func parseTrainName(s string) (string, error) {
+	name := strings.TrimSpace(s)
+	if name == "" || len(name) > maxTrainNameLength {
+		return "", fmt.Errorf("train name must be 1 to %d characters", maxTrainNameLength)
+	}
+	return name, nil
+}
+
+// fareClasses are the classes coaches can be, those that have fares
+func fareClasses(appState *app.AppState) []string {
+	classes := make([]string, 0, len(appState.Fares.Classes))
+	for class := range appState.Fares.Classes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// validate checks a new coach, or just the seat count when resizing one
+func (c *coachRequest) validate(appState *app.AppState) error {
+	if c.Seats < 0 || c.Seats > fleet.MaxCoachSeats {
+		return fmt.Errorf("a coach has 0 to %d seats", fleet.MaxCoachSeats)
+	}
+	if c.ID != "" {
+		return nil
+	}
+	code, err := fleet.ParseCoachCode(c.Code)
+	if err != nil {
+		return err
+	}
+	c.Code = code
+	if _, ok := appState.Fares.Classes[c.Class]; !ok {
+		return fmt.Errorf("class must be one of %s", strings.Join(fareClasses(appState), ", "))
+	}
+	if c.Seats < 1 {
+		return errors.New("a new coach needs at least one seat")
+	}
+	return nil
+}
+
+// parseAdminTime reads a time given as RFC 3339 or as a form's datetime-local value,
+// which is on the operator's clock in loc; empty is the zero time
+func parseAdminTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+	return t.UTC(), err
+}
+
+// loadTrain fetches a train's coaches to go with it
+func loadTrain(ctx context.Context, q database.QueriesInterface, train database.Train) (trainView, error) {
+	rows, err := q.ListCoaches(ctx, train.ID)
+	if err != nil {
+		return trainView{}, err
+	}
+	view := trainView{
+		ID:         train.ID,
+		Name:       train.Name,
+		Status:     train.Status,
+		TotalSeats: train.TotalSeats,
+		Coaches:    []coachView{},
+	}
+	for _, row := range rows {
+		view.Coaches = append(view.Coaches, coachView{
+			ID:        row.ID,
+			TrainID:   row.TrainID,
+			Code:      row.Code,
+			Class:     row.Class,
+			Position:  row.Position,
+			Seats:     row.Seats,
+			SoldSeats: row.SoldSeats,
+		})
+	}
+	return view, nil
+}
+
+// listDepartures fetches a train's departures from today, in the operator's time zone
+// loc, on
+func listDepartures(ctx context.Context, q database.QueriesInterface, loc *time.Location, trainID string) ([]departureView, error) {
+	rows, err := q.ListTrainDepartures(ctx, database.ListTrainDeparturesParams{
+		TrainID:     trainID,
+		ServiceDate: time.Now().In(loc).Format("2006-01-02"),
+		Limit:       adminDepartureLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	departures := []departureView{}
+	for _, row := range rows {
+		departures = append(departures, departureView{
+			ID:          row.ID,
+			TrainID:     row.TrainID,
+			ServiceDate: row.ServiceDate,
+			DepartsAt:   row.DepartsAt.In(loc),
+			ArrivesAt:   row.ArrivesAt.In(loc),
+			Status:      row.Status,
+			RuleID:      row.RuleID.String,
+			SoldSeats:   row.SoldSeats,
+		})
+	}
+	return departures, nil
+}
+
+// createTrain adds a train with its coaches
+func createTrain(ctx context.Context, q database.QueriesInterface, name string, coaches []coachRequest) (database.Train, error) {
+	train, err := q.CreateTrain(ctx, database.CreateTrainParams{
+		ID:   uuid.New().String(),
+		Name: name,
+	})
+	if err != nil {
+		return train, err
+	}
+	for _, c := range coaches {
+		if _, err := addCoach(ctx, q, train.ID, c); err != nil {
+			return train, err
+		}
+	}
+	return q.GetTrain(ctx, train.ID)
+}
+
+// addCoach couples a coach to the end of a train
+func addCoach(ctx context.Context, q database.QueriesInterface, trainID string, c coachRequest) (database.GetCoachRow, error) {
+	coach, err := q.CreateCoach(ctx, database.CreateCoachParams{
+		ID:      uuid.New().String(),
+		TrainID: trainID,
+		Code:    c.Code,
+		Class:   c.Class,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.GetCoachRow{}, errTrainNotFound
+	}
+	if err != nil {
+		return database.GetCoachRow{}, err
+	}
+	return resizeCoach(ctx, q, coach.ID, c.Seats)
+}
+
+// resizeCoach brings a coach to the given number of seats. Seats taken out of service
+// are the highest numbered unsold ones; seats added bring back retired ones first
+// before laying out new ones.
+func resizeCoach(ctx context.Context, q database.QueriesInterface, coachID string, seats int64) (database.GetCoachRow, error) {
+	coach, err := q.GetCoach(ctx, coachID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return coach, errCoachNotFound
+	}
+	if err != nil {
+		return coach, err
+	}
+
+	switch {
+	case seats > coach.Seats:
+		missing := seats - coach.Seats
+		restored, err := q.RestoreCoachSeats(ctx, database.RestoreCoachSeatsParams{
+			CoachID: coach.ID,
+			Limit:   missing,
+		})
+		if err != nil {
+			return coach, err
+		}
+		for n := coach.AllSeats + 1; n <= coach.AllSeats+missing-restored; n++ {
+			seat := fleet.Layout(coach.Code, coach.Class, int(n))
+			err := q.AddCoachSeat(ctx, database.AddCoachSeatParams{
+				CoachID:         coach.ID,
+				Label:           seat.Label,
+				IsWindow:        seat.IsWindow,
+				IsAisle:         seat.IsAisle,
+				BerthType:       sql.NullString{String: seat.BerthType, Valid: seat.BerthType != ""},
+				WheelchairSpace: seat.WheelchairSpace,
+			})
+			if err != nil {
+				return coach, err
+			}
+		}
+
+	case seats < coach.Seats:
+		if seats < coach.SoldSeats {
+			return coach, fmt.Errorf("%w: coach %s has %d sold", errBelowSoldSeats, coach.Code, coach.SoldSeats)
+		}
+		retired, err := q.RetireCoachSeats(ctx, database.RetireCoachSeatsParams{
+			CoachID: coach.ID,
+			Limit:   coach.Seats - seats,
+		})
+		if err != nil {
+			return coach, err
+		}
+		// A seat sold since the coach was read
+		if retired < coach.Seats-seats {
+			return coach, fmt.Errorf("%w: more seats on coach %s were just sold", errBelowSoldSeats, coach.Code)
+		}
+		if err := q.DeleteRetiredQuotaSeats(ctx); err != nil {
+			return coach, err
+		}
+	}
+
+	if err := q.UpdateTrainCapacity(ctx, coach.TrainID); err != nil {
+		return coach, err
+	}
+	if err := q.UpdateDepartureCapacity(ctx, coach.TrainID); err != nil {
+		return coach, err
+	}
+	return q.GetCoach(ctx, coach.ID)
+}
+
+// retireTrain stops a train running: its future departures are cancelled and the
+// timetable makes no more. Trains with seats sold on a departure still to run are
+// refused.
+func retireTrain(ctx context.Context, q database.QueriesInterface, trainID string) (int64, error) {
+	booked, err := q.CountBookedDepartures(ctx, trainID)
+	if err != nil {
+		return 0, err
+	}
+	if booked > 0 {
+		return 0, fmt.Errorf("%w: %d departures of the train", errSeatsSold, booked)
+	}
+	n, err := q.RetireTrain(ctx, trainID)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errTrainNotFound
+	}
+	return q.CancelTrainDepartures(ctx, trainID)
+}
+
+// createDeparture schedules a one-off run of a train on the service date it leaves on
+// in the operator's time zone loc. Without an arrival time, the train takes its
+// route's usual running time.
+func createDeparture(ctx context.Context, q database.QueriesInterface, loc *time.Location, trainID string, departsAt, arrivesAt time.Time) (database.Departure, error) {
+	if arrivesAt.IsZero() {
+		runMinutes, err := q.GetTrainRunMinutes(ctx, trainID)
+		if err != nil {
+			return database.Departure{}, err
+		}
+		arrivesAt = departsAt.Add(time.Duration(runMinutes) * time.Minute)
+	}
+	if !departsAt.After(time.Now()) {
+		return database.Departure{}, errDepartureInPast
+	}
+	if !arrivesAt.After(departsAt) {
+		return database.Departure{}, errArrivalTime
+	}
+
+	departure, err := q.CreateDeparture(ctx, database.CreateDepartureParams{
+		ID:          uuid.New().String(),
+		TrainID:     trainID,
+		ServiceDate: departsAt.In(loc).Format("2006-01-02"),
+		DepartsAt:   departsAt.UTC(),
+		ArrivesAt:   arrivesAt.UTC(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return departure, errTrainNotFound
+	}
+	return departure, err
+}
+
+// rescheduleDeparture moves a departure that hasn't left yet, keeping its service date.
+// Without an arrival time, its running time stays the same.
+func rescheduleDeparture(ctx context.Context, q database.QueriesInterface, departureID string, departsAt, arrivesAt time.Time) (database.Departure, error) {
+	current, err := q.GetDeparture(ctx, departureID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Departure{}, errDepartureNotFound
+	}
+	if err != nil {
+		return database.Departure{}, err
+	}
+	if arrivesAt.IsZero() {
+		arrivesAt = departsAt.Add(current.ArrivesAt.Sub(current.DepartsAt))
+	}
+	if !departsAt.After(time.Now()) {
+		return database.Departure{}, errDepartureInPast
+	}
+	if !arrivesAt.After(departsAt) {
+		return database.Departure{}, errArrivalTime
+	}
+
+	departure, err := q.RescheduleDeparture(ctx, database.RescheduleDepartureParams{
+		DepartsAt: departsAt.UTC(),
+		ArrivesAt: arrivesAt.UTC(),
+		ID:        departureID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return departure, errDepartureNotFound
+	}
+	return departure, err
+}
+
+// cancelDeparture takes a departure out of service, if nothing is sold on it
+func cancelDeparture(ctx context.Context, q database.QueriesInterface, departureID string) error {
+	n, err := q.CancelDeparture(ctx, departureID)
+	if err != nil || n > 0 {
+		return err
+	}
+	departure, err := q.GetDeparture(ctx, departureID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && departure.Status != "scheduled") {
+		return errDepartureNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return errSeatsSold
+}
+
+// fleetFailure is the status and message a failed change to trains, coaches or
+// departures is reported with. Reasons the admin can act on are passed on; anything
+// else is logged.
+func fleetFailure(err error, action string) (int, string) {
+	switch {
+	case errors.Is(err, errTrainNotFound), errors.Is(err, errCoachNotFound), errors.Is(err, errDepartureNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, errBelowSoldSeats), errors.Is(err, errSeatsSold):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, errArrivalTime), errors.Is(err, errDepartureInPast):
+		return http.StatusUnprocessableEntity, err.Error()
+	}
+	// Most likely a coach code or departure time the train already has
+	log.Printf("Error trying to %s: %v", action, err)
+	return http.StatusUnprocessableEntity, "failed to " + action
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/auth"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/ratelimit"
+	"rsvbackend/internal/ticketstate"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// pnrAlphabet leaves out characters that are easily confused when read aloud (0/O, 1/I)
+const pnrAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const pnrLength = 6
+
+// Where a booking was made, kept for reporting
+const (
+	channelWeb      = "web"
+	channelAgent    = "agent"
+	channelWaitlist = "waitlist"
+)
+
+// newPNR generates a random booking reference
+func newPNR() (string, error) {
+	pnr := make([]byte, pnrLength)
+	for i := range pnr {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(pnrAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		pnr[i] = pnrAlphabet[n.Int64()]
+	}
+	return string(pnr), nil
+}
+
+// bookingChannel is the channel a user's own booking is made through: agents book for
+// customers, everyone else books on the web
+func bookingChannel(ctx context.Context, q database.QueriesInterface, userID string) string {
+	user, err := q.GetUser(ctx, userID)
+	if err != nil {
+		log.Println("Error fetching user:", err)
+		return channelWeb
+	}
+	if auth.Role(user.Role) == auth.Agent {
+		return channelAgent
+	}
+	return channelWeb
+}
+
+// createBooking opens a booking under a fresh PNR, retrying on the rare collision
+func createBooking(ctx context.Context, q database.QueriesInterface, userID, channel string) (database.Booking, error) {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var pnr string
+		pnr, err = newPNR()
+		if err != nil {
+			return database.Booking{}, err
+		}
+
+		var booking database.Booking
+		booking, err = q.CreateBooking(ctx, database.CreateBookingParams{
+			ID:      uuid.New().String(),
+			Pnr:     pnr,
+			UserID:  userID,
+			Channel: channel,
+		})
+		if err == nil {
+			return booking, nil
+		}
+	}
+	return database.Booking{}, err
+}
+
+// lookupBooking finds the booking for the PNR and surname on a manage booking form
+func lookupBooking(appState *app.AppState, r *http.Request) (database.Booking, error) {
+	pnr := strings.ToUpper(strings.TrimSpace(r.FormValue("pnr")))
+	surname := strings.ToLower(strings.TrimSpace(r.FormValue("surname")))
+	if pnr == "" || surname == "" {
+		return database.Booking{}, sql.ErrNoRows
+	}
+	return appState.DB.GetBookingByPNR(r.Context(), database.GetBookingByPNRParams{
+		Pnr:     pnr,
+		Surname: surname,
+	})
+}
+
+// renderManageBooking shows the manage booking page, with the booking's tickets once found
+func renderManageBooking(appState *app.AppState, w http.ResponseWriter, r *http.Request, booking *database.Booking, message string) {
+	data := map[string]interface{}{
+		"PNR":     r.FormValue("pnr"),
+		"Surname": r.FormValue("surname"),
+		"Error":   message,
+	}
+	if booking != nil {
+		tickets, err := appState.DB.GetBookingTickets(r.Context(), sql.NullString{String: booking.ID, Valid: true})
+		if err != nil {
+			log.Println("Error fetching booking tickets:", err)
+			http.Error(w, "Failed to fetch booking", http.StatusInternalServerError)
+			return
+		}
+		data["Booking"] = booking
+		data["Tickets"] = tickets
+	}
+
+	err := appState.Templates.ExecuteTemplate(w, "manage.html", data)
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// HandleManageBooking lets anyone holding a PNR and the passenger's surname view
+// the booking without logging in
+func HandleManageBooking(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		renderManageBooking(appState, w, r, nil, "")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	if !appState.ManageLimiter.Allow(ratelimit.ClientIP(r)) {
+		log.Println("Booking lookup rate limited for", ratelimit.ClientIP(r))
+		w.WriteHeader(http.StatusTooManyRequests)
+		renderManageBooking(appState, w, r, nil, "Too many attempts, please try again later")
+		return
+	}
+
+	booking, err := lookupBooking(appState, r)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("Error looking up booking:", err)
+		}
+		renderManageBooking(appState, w, r, nil, "No booking matches that reference and surname")
+		return
+	}
+
+	renderManageBooking(appState, w, r, &booking, "")
+}
+
+// HandleManageCancel cancels a ticket from the manage booking page and hands the seat
+// to the waitlist. The refund is shown first; the PNR and surname are checked again
+// on every step.
+func HandleManageCancel(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	if !appState.ManageLimiter.Allow(ratelimit.ClientIP(r)) {
+		log.Println("Booking lookup rate limited for", ratelimit.ClientIP(r))
+		w.WriteHeader(http.StatusTooManyRequests)
+		renderManageBooking(appState, w, r, nil, "Too many attempts, please try again later")
+		return
+	}
+
+	booking, err := lookupBooking(appState, r)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("Error looking up booking:", err)
+		}
+		renderManageBooking(appState, w, r, nil, "No booking matches that reference and surname")
+		return
+	}
+
+	ticket, err := appState.DB.GetTicket(r.Context(), r.FormValue("ticket_id"))
+	if err != nil || ticket.BookingID.String != booking.ID || ticket.Status != string(ticketstate.Confirmed) {
+		log.Println("Ticket not found in booking for cancellation:", r.FormValue("ticket_id"), err)
+		renderManageBooking(appState, w, r, &booking, "Ticket not found in this booking")
+		return
+	}
+
+	if r.FormValue("confirm") == "" {
+		renderCancelTicket(appState, w, ticket, map[string]interface{}{
+			"Action":  "/manage/cancel",
+			"PNR":     r.FormValue("pnr"),
+			"Surname": r.FormValue("surname"),
+		})
+		return
+	}
+
+	if !requestCriticalSection(appState, booking.UserID) {
+		// A 503 lets the form be sent again with the same idempotency key
+		w.WriteHeader(http.StatusServiceUnavailable)
+		renderManageBooking(appState, w, r, &booking, "Booking system is busy, please try again")
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	quote, err := cancelTicket(r.Context(), appState, ticket, "manage", "pnr:"+booking.Pnr)
+	RecordAudit(appState, r, cancellationAudit("pnr:"+booking.Pnr, ticket, quote, err))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Println("Ticket already cancelled:", ticket.ID)
+		renderManageBooking(appState, w, r, &booking, "Ticket has already been cancelled")
+		return
+	}
+	if err != nil {
+		log.Println("Error cancelling ticket:", err)
+		http.Error(w, "Failed to cancel ticket", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Cancelled ticket %s from booking %s, refunding %d", ticket.ID, booking.Pnr, quote.Amount)
+	renderManageBooking(appState, w, r, &booking, "")
+}
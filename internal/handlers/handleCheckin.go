@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/auth"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/ticketstate"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of a check-in or boarding attempt
+const (
+	checkinAccepted  = "checked_in"
+	boardingAccepted = "boarded"
+	checkinDuplicate = "duplicate"
+	checkinRejected  = "rejected"
+)
+
+// StaffFromRequest returns the staff member whose API token is in the request's
+// Authorization header, or the email of a logged-in user whose role can check
+// passengers in
+func StaffFromRequest(appState *app.AppState, r *http.Request) (string, bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		for known, staff := range appState.StaffTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(known)) == 1 {
+				return staff, true
+			}
+		}
+		return "", false
+	}
+	if user, ok := UserFromRequest(appState, r); ok && auth.Role(user.Role).Can(auth.CheckIn) {
+		return user.Email, true
+	}
+	return "", false
+}
+
+// checkinScan is one scan from a staff device: a signed e-ticket, or a PNR read out
+// by the passenger. Scans made offline carry the time they were made.
+type checkinScan struct {
+	ETicket     string    `json:"eticket,omitempty"`
+	PNR         string    `json:"pnr,omitempty"`
+	DepartureID string    `json:"departure_id,omitempty"`
+	ScannedAt   time.Time `json:"scanned_at"`
+}
+
+type checkinResult struct {
+	TicketID    string     `json:"ticket_id,omitempty"`
+	Passenger   string     `json:"passenger,omitempty"`
+	Result      string     `json:"result"`
+	Reason      string     `json:"reason,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// checkInTicket moves a confirmed ticket to checked in and records who scanned it.
+// A ticket that is already checked in or boarded is reported as a duplicate.
+func checkInTicket(ctx context.Context, appState *app.AppState, ticketID, departureID, method, staff, deviceID string, scannedAt time.Time) checkinResult {
+	result := checkinResult{TicketID: ticketID}
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		_, err := ticketstate.Transition(ctx, q, ticketstate.Change{
+			TicketID: ticketID,
+			From:     ticketstate.Confirmed,
+			To:       ticketstate.CheckedIn,
+			Actor:    "staff:" + staff,
+			Reason:   "checked in by " + method,
+		})
+		if err != nil {
+			return err
+		}
+
+		checkin, err := q.CreateCheckin(ctx, database.CreateCheckinParams{
+			ID:          uuid.New().String(),
+			TicketID:    ticketID,
+			DepartureID: departureID,
+			Method:      method,
+			Staff:       staff,
+			DeviceID:    deviceID,
+			ScannedAt:   scannedAt.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		result.CheckedInAt = &checkin.ScannedAt
+		return nil
+	})
+	if err == nil {
+		result.Result = checkinAccepted
+		return result
+	}
+	result.CheckedInAt = nil
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Error checking in ticket:", err)
+		result.Result = checkinRejected
+		result.Reason = "check-in failed"
+		return result
+	}
+
+	// The ticket was not confirmed; say why
+	ticket, err := appState.DB.GetTicket(ctx, ticketID)
+	if err != nil {
+		result.Result = checkinRejected
+		result.Reason = "unknown ticket"
+		return result
+	}
+	if ticket.Status == string(ticketstate.CheckedIn) || ticket.Status == string(ticketstate.Boarded) {
+		result.Result = checkinDuplicate
+		result.Reason = "ticket is already " + ticket.Status
+		if checkin, err := appState.DB.GetCheckin(ctx, ticketID); err == nil {
+			result.CheckedInAt = &checkin.ScannedAt
+		}
+		return result
+	}
+	result.Result = checkinRejected
+	result.Reason = "ticket is " + ticket.Status
+	return result
+}
+
+// scanAction is what a scan does to each ticket it refers to
+type scanAction func(ctx context.Context, appState *app.AppState, ticketID, departureID, method, staff, deviceID string, scannedAt time.Time) checkinResult
+
+// boardTicket moves a checked-in ticket to boarded. A ticket that has already boarded
+// is reported as a duplicate.
+func boardTicket(ctx context.Context, appState *app.AppState, ticketID, departureID, method, staff, deviceID string, scannedAt time.Time) checkinResult {
+	result := checkinResult{TicketID: ticketID}
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		_, err := ticketstate.Transition(ctx, q, ticketstate.Change{
+			TicketID: ticketID,
+			From:     ticketstate.CheckedIn,
+			To:       ticketstate.Boarded,
+			Actor:    "staff:" + staff,
+			Reason:   "boarded by " + method,
+		})
+		return err
+	})
+	if err == nil {
+		result.Result = boardingAccepted
+		return result
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Error boarding ticket:", err)
+		result.Result = checkinRejected
+		result.Reason = "boarding failed"
+		return result
+	}
+
+	// The ticket was not checked in; say why
+	ticket, err := appState.DB.GetTicket(ctx, ticketID)
+	if err != nil {
+		result.Result = checkinRejected
+		result.Reason = "unknown ticket"
+		return result
+	}
+	if ticket.Status == string(ticketstate.Boarded) {
+		result.Result = checkinDuplicate
+		result.Reason = "ticket is already boarded"
+		return result
+	}
+	result.Result = checkinRejected
+	if ticket.Status == string(ticketstate.Confirmed) {
+		result.Reason = "ticket is not checked in"
+	} else {
+		result.Reason = "ticket is " + ticket.Status
+	}
+	return result
+}
+
+// processScan applies a check-in or boarding action to the tickets a scan refers to:
+// the e-ticket's ticket, or every ticket of the PNR on the scan's departure
+func processScan(ctx context.Context, appState *app.AppState, scan checkinScan, staff, deviceID string, action scanAction) []checkinResult {
+	if scan.ScannedAt.IsZero() {
+		scan.ScannedAt = time.Now()
+	}
+
+	if scan.ETicket != "" {
+		// Offline scans are judged at the time they were made
+		payload, err := appState.ETickets.Verify(scan.ETicket, scan.ScannedAt)
+		if err != nil {
+			return []checkinResult{{TicketID: payload.TicketID, Result: checkinRejected, Reason: err.Error()}}
+		}
+		if scan.DepartureID != "" && payload.DepartureID != scan.DepartureID {
+			return []checkinResult{{TicketID: payload.TicketID, Result: checkinRejected, Reason: "ticket is for another departure"}}
+		}
+		result := action(ctx, appState, payload.TicketID, payload.DepartureID, "eticket", staff, deviceID, scan.ScannedAt)
+		result.Passenger = payload.Passenger
+		return []checkinResult{result}
+	}
+
+	pnr := strings.ToUpper(strings.TrimSpace(scan.PNR))
+	if pnr == "" || scan.DepartureID == "" {
+		return []checkinResult{{Result: checkinRejected, Reason: "an e-ticket, or a PNR and departure, is required"}}
+	}
+	tickets, err := appState.DB.GetPNRTickets(ctx, pnr)
+	if err != nil {
+		log.Println("Error fetching PNR tickets:", err)
+		return []checkinResult{{Result: checkinRejected, Reason: "check-in failed"}}
+	}
+	var results []checkinResult
+	for _, ticket := range tickets {
+		if ticket.DepartureID != scan.DepartureID {
+			continue
+		}
+		result := action(ctx, appState, ticket.ID, ticket.DepartureID, "pnr", staff, deviceID, scan.ScannedAt)
+		result.Passenger = ticket.PassengerName.String
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return []checkinResult{{Result: checkinRejected, Reason: "no ticket for this PNR on this departure"}}
+	}
+	return results
+}
+
+// HandleCheckin validates a scanned e-ticket or PNR and checks its tickets in.
+// It answers 200 if any ticket was checked in, 409 if they all already were and
+// 422 otherwise.
+func HandleCheckin(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	staff, _ := StaffFromRequest(appState, r)
+
+	var scan checkinScan
+	if err := json.NewDecoder(r.Body).Decode(&scan); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	results := processScan(r.Context(), appState, scan, staff, r.Header.Get("X-Device-ID"), checkInTicket)
+	writeJSON(w, scanStatus(results, checkinAccepted), map[string]interface{}{"results": results})
+}
+
+// HandleBoard records passengers with a scanned e-ticket or PNR boarding the train.
+// Only checked-in tickets can board. It answers like HandleCheckin.
+func HandleBoard(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	staff, _ := StaffFromRequest(appState, r)
+
+	var scan checkinScan
+	if err := json.NewDecoder(r.Body).Decode(&scan); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	results := processScan(r.Context(), appState, scan, staff, r.Header.Get("X-Device-ID"), boardTicket)
+	writeJSON(w, scanStatus(results, boardingAccepted), map[string]interface{}{"results": results})
+}
+
+// scanStatus is 200 if any ticket was accepted, 409 if they all were duplicates and
+// 422 otherwise
+func scanStatus(results []checkinResult, accepted string) int {
+	duplicates := 0
+	for _, result := range results {
+		if result.Result == accepted {
+			return http.StatusOK
+		}
+		if result.Result == checkinDuplicate {
+			duplicates++
+		}
+	}
+	if duplicates == len(results) {
+		return http.StatusConflict
+	}
+	return http.StatusUnprocessableEntity
+}
+
+type manifestPassenger struct {
+	TicketID    string     `json:"ticket_id"`
+	PNR         string     `json:"pnr"`
+	Passenger   string     `json:"passenger"`
+	Seat        string     `json:"seat"`
+	Class       string     `json:"class"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Status      string     `json:"status"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+}
+
+// HandleManifest lists everyone travelling on a departure, with the e-ticket public
+// key, so a staff device can check passengers in while offline
+func HandleManifest(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	departure, err := appState.DB.GetDeparture(r.Context(), r.FormValue("departure_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Departure not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Error fetching departure:", err)
+		http.Error(w, "Failed to fetch manifest", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := appState.DB.GetDepartureManifest(r.Context(), departure.ID)
+	if err != nil {
+		log.Println("Error fetching manifest:", err)
+		http.Error(w, "Failed to fetch manifest", http.StatusInternalServerError)
+		return
+	}
+
+	passengers := []manifestPassenger{}
+	for _, row := range rows {
+		p := manifestPassenger{
+			TicketID:  row.ID,
+			PNR:       row.Pnr.String,
+			Passenger: row.PassengerName.String,
+			Seat:      row.SeatLabel,
+			Class:     row.Class,
+			From:      row.OriginName,
+			To:        row.DestinationName,
+			Status:    row.Status,
+		}
+		if row.CheckedInAt.Valid {
+			p.CheckedInAt = &row.CheckedInAt.Time
+		}
+		passengers = append(passengers, p)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"departure_id": departure.ID,
+		"train":        departure.Name,
+		"service_date": departure.ServiceDate,
+		"departs_at":   departure.DepartsAt,
+		"generated_at": time.Now().UTC(),
+		"public_key":   appState.ETickets.PublicKey(),
+		"passengers":   passengers,
+	})
+}
+
+// HandleCheckinSync takes the scans a staff device made offline and checks them in
+// in order. Scans of tickets another device got to first come back as duplicates.
+func HandleCheckinSync(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	staff, _ := StaffFromRequest(appState, r)
+
+	var batch struct {
+		DeviceID string        `json:"device_id"`
+		Scans    []checkinScan `json:"scans"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	type scanResults struct {
+		Scan    int             `json:"scan"`
+		Results []checkinResult `json:"results"`
+	}
+	synced := []scanResults{}
+	for i, scan := range batch.Scans {
+		synced = append(synced, scanResults{
+			Scan:    i,
+			Results: processScan(r.Context(), appState, scan, staff, batch.DeviceID, checkInTicket),
+		})
+	}
+
+	log.Printf("Synced %d offline scans from %s (%s)", len(batch.Scans), batch.DeviceID, staff)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"scans": synced})
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errConnectionSeatTaken = errors.New("a seat on this connection was just taken, please try again")
+	errConnectionNotOnSale = errors.New("tickets for one of these trains aren't on sale yet")
+)
+
+// connectionLeg is one train of a connecting itinerary being booked
+type connectionLeg struct {
+	DepartureID string
+	Train       string
+	ServiceDate string
+	Origin      database.Station
+	Destination database.Station
+	Stops       database.GetJourneyStopsRow
+}
+
+// connectionForm is a connecting itinerary picked from the search results
+type connectionForm struct {
+	Origin      string
+	Destination string
+	Via         string
+	Class       string
+	Legs        []connectionLeg
+	Transfer    time.Duration
+	Valid       bool
+}
+
+// parseConnection reads the two legs of a connection from a request and checks each
+// train runs its leg and the change at the via station leaves enough time
+func parseConnection(ctx context.Context, q database.QueriesInterface, r *http.Request) (connectionForm, error) {
+	c := connectionForm{
+		Origin:      r.FormValue("origin"),
+		Destination: r.FormValue("destination"),
+		Via:         r.FormValue("via"),
+		Class:       r.FormValue("class"),
+	}
+	if c.Class == "" {
+		return c, errors.New("class is required")
+	}
+
+	stations, err := q.ListTransferStations(ctx, database.ListTransferStationsParams{
+		OriginStationID:      c.Origin,
+		DestinationStationID: c.Destination,
+	})
+	if err != nil {
+		return c, err
+	}
+	var via database.Station
+	for _, station := range stations {
+		if station.ID == c.Via {
+			via = station
+		}
+	}
+	if via.ID == "" {
+		return c, errors.New("trains don't connect at the selected station")
+	}
+
+	allStations, err := q.ListStations(ctx)
+	if err != nil {
+		return c, err
+	}
+	byID := make(map[string]database.Station, len(allStations))
+	for _, station := range allStations {
+		byID[station.ID] = station
+	}
+
+	ends := [][2]string{{c.Origin, c.Via}, {c.Via, c.Destination}}
+	for i, field := range []string{"leg1", "leg2"} {
+		departureID, err := uuid.Parse(r.FormValue(field))
+		if err != nil {
+			return c, errors.New("invalid departure ID")
+		}
+		departure, err := q.GetDeparture(ctx, departureID.String())
+		if err != nil {
+			return c, errors.New("invalid departure ID")
+		}
+		stops, err := q.GetJourneyStops(ctx, database.GetJourneyStopsParams{
+			DepartureID:          departureID.String(),
+			OriginStationID:      ends[i][0],
+			DestinationStationID: ends[i][1],
+		})
+		if err != nil {
+			return c, errors.New("this train does not run between the selected stations")
+		}
+		c.Legs = append(c.Legs, connectionLeg{
+			DepartureID: departure.ID,
+			Train:       departure.Name,
+			ServiceDate: departure.ServiceDate,
+			Origin:      byID[ends[i][0]],
+			Destination: byID[ends[i][1]],
+			Stops:       stops,
+		})
+	}
+
+	if c.Legs[0].DepartureID == c.Legs[1].DepartureID {
+		return c, errors.New("a connection changes trains")
+	}
+
+	c.Transfer = c.Legs[1].Stops.OriginDepartsAt().Sub(c.Legs[0].Stops.DestinationArrivesAt())
+	if c.Transfer < time.Duration(via.MinTransferMinutes)*time.Minute || c.Transfer > maxTransferWait {
+		return c, errors.New("there isn't a valid connection between these trains")
+	}
+	c.Valid = true
+	return c, nil
+}
+
+// HandleBookConnection shows a connecting itinerary (GET) and holds a seat on each of
+// its legs for every passenger in the party (POST). The holds are made in one
+// transaction, so if anyone can't be seated on either leg nothing is held; they are
+// then paid for and confirmed together.
+func HandleBookConnection(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	connection, err := parseConnection(r.Context(), appState.DB, r)
+	if err != nil {
+		log.Println("Invalid connection:", err)
+		renderConnection(appState, w, r, userID.String(), connection, displayText(err.Error()))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderConnection(appState, w, r, userID.String(), connection, "")
+		return
+	}
+
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	party, err := partyFromForm(r.Context(), appState.DB, r, userID.String())
+	if err != nil {
+		log.Println("Invalid passenger:", err)
+		renderConnection(appState, w, r, userID.String(), connection, displayText(err.Error()))
+		return
+	}
+
+	// Every passenger gets a seat on each leg, all held under one itinerary so they are
+	// paid for together and become tickets under one PNR
+	itineraryID := uuid.New().String()
+	expiresAt := time.Now().UTC().Add(appState.HoldTTL)
+	var holdIDs []string
+	err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+		for i, leg := range connection.Legs {
+			opens, err := salesOpenAt(r.Context(), q, appState.Location, leg.DepartureID, connection.Class)
+			if err != nil {
+				return err
+			}
+			if opens.After(time.Now()) {
+				return errConnectionNotOnSale
+			}
+
+			// Each leg's fare is quoted now and locked in with its holds
+			fare, err := quoteSegment(r.Context(), q, appState.Fares, leg.DepartureID, connection.Class, leg.Stops.OriginStop, leg.Stops.DestinationStop)
+			if err != nil {
+				return err
+			}
+
+			for j, passenger := range party {
+				// Connections are only sold in the general quota
+				seatNumber, _, err := chooseSeat(r.Context(), q, leg.DepartureID, connection.Class, leg.Stops, "", r.FormValue("preference"), quota.General)
+				if err != nil {
+					return fmt.Errorf("%w on the %s leg", err, leg.Train)
+				}
+
+				hold, err := q.CreateSeatHold(r.Context(), database.CreateSeatHoldParams{
+					ID:                  uuid.New().String(),
+					DepartureID:         leg.DepartureID,
+					UserID:              userID.String(),
+					SeatNumber:          seatNumber,
+					OriginStop:          leg.Stops.OriginStop,
+					DestinationStop:     leg.Stops.DestinationStop,
+					FareAmount:          fare.Total,
+					FareCurrency:        fare.Currency,
+					ExpiresAt:           expiresAt,
+					PassengerName:       passenger.Name,
+					PassengerAge:        passenger.Age,
+					PassengerGender:     passenger.Gender,
+					PassengerIDDocument: passenger.IDDocument,
+					ItineraryID:         sql.NullString{String: itineraryID, Valid: true},
+					Leg:                 int64(i + 1),
+					Legs:                int64(len(connection.Legs)),
+					Passenger:           int64(j + 1),
+					Passengers:          int64(len(party)),
+					Quota:               string(quota.General),
+					SalesClock:          database.SalesClock(time.Now(), appState.Location),
+				})
+				if errors.Is(err, sql.ErrNoRows) {
+					return errConnectionSeatTaken
+				}
+				if err != nil {
+					return err
+				}
+				holdIDs = append(holdIDs, hold.ID)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errNoSeatInClass) || errors.Is(err, errConnectionSeatTaken) || errors.Is(err, errConnectionNotOnSale) {
+		// Nothing was held, so the other leg is free for someone else
+		log.Println("Could not hold connection:", err)
+		renderConnection(appState, w, r, userID.String(), connection, displayText(err.Error()))
+		return
+	}
+	if err != nil {
+		log.Println("Error holding connection:", err)
+		http.Error(w, "Failed to hold seats", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/book/confirm?hold_id="+holdIDs[0], http.StatusSeeOther)
+}
+
+// renderConnection shows a connecting itinerary with the party form to book it
+func renderConnection(appState *app.AppState, w http.ResponseWriter, r *http.Request, userID string, connection connectionForm, message string) {
+	passengers, err := appState.DB.ListSavedPassengers(r.Context(), userID)
+	if err != nil {
+		log.Println("Error fetching saved passengers:", err)
+		http.Error(w, "Failed to fetch saved passengers", http.StatusInternalServerError)
+		return
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "connection.html", map[string]interface{}{
+		"Connection":      connection,
+		"TransferMinutes": int64(connection.Transfer / time.Minute),
+		"SavedPassengers": passengers,
+		"Genders":         passengerGenders,
+		"PartyPlaces":     partyPlaces,
+		"Error":           message,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
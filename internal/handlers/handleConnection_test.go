@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"rsvbackend/internal/database"
+)
+
+// Seed stations and the second seeded train, which runs Harbour, Hillview, Central
+const (
+	riversideStationID = "7c9e6679-7425-40de-944b-e07fc1f90ae2"
+	hillviewStationID  = "7c9e6679-7425-40de-944b-e07fc1f90ae3"
+	centralStationID   = "7c9e6679-7425-40de-944b-e07fc1f90ae1"
+	returnTrainID      = "550e8400-e29b-41d4-a716-446655440001"
+)
+
+// connectingDeparture times a run of the return train to leave Hillview half an hour
+// after the test departure gets there, so Riverside to Central connects at Hillview
+func (pt *paymentTest) connectingDeparture(t *testing.T) string {
+	t.Helper()
+	var id string
+	var departsAt time.Time
+	err := pt.db.QueryRow("SELECT id FROM departures WHERE train_id = ? AND id <> train_id LIMIT 1", returnTrainID).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pt.db.QueryRow("SELECT departs_at FROM departures WHERE id = ?", testDepartureID).Scan(&departsAt); err != nil {
+		t.Fatal(err)
+	}
+	// The test train reaches Hillview after 235 minutes; the return train leaves it
+	// 180 minutes into its run
+	departs := departsAt.Add(235*time.Minute + 30*time.Minute - 180*time.Minute)
+	_, err = pt.db.Exec("UPDATE departures SET departs_at = ?, arrives_at = ?, service_date = ? WHERE id = ?",
+		departs.Format("2006-01-02 15:04:05"), departs.Add(8*time.Hour).Format("2006-01-02 15:04:05"), departs.Format("2006-01-02"), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestBookConnectionHoldsEveryLegForTheParty(t *testing.T) {
+	pt := newTestApp(t)
+	second := pt.connectingDeparture(t)
+
+	form := url.Values{
+		"origin":           {riversideStationID},
+		"destination":      {centralStationID},
+		"via":              {hillviewStationID},
+		"class":            {"second"},
+		"leg1":             {testDepartureID},
+		"leg2":             {second},
+		"passenger_name":   {"Asha Rao", "Ravi Rao"},
+		"passenger_age":    {"34", "36"},
+		"passenger_gender": {"female", "male"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/book/connection", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(pt.cookie)
+	w := httptest.NewRecorder()
+	HandleBookConnection(pt.appState, w, r)
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/book/confirm?hold_id=") {
+		t.Fatalf("got %d to %q: %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+
+	if n := pt.count(t, "SELECT COUNT(*) FROM seat_holds WHERE legs = 2 AND passengers = 2"); n != 4 {
+		t.Fatalf("%d holds, want a seat on both legs for both passengers", n)
+	}
+	if n := pt.count(t, "SELECT COUNT(DISTINCT itinerary_id) FROM seat_holds"); n != 1 {
+		t.Errorf("holds spread over %d itineraries, want 1", n)
+	}
+	var total int64
+	if err := pt.db.QueryRow("SELECT SUM(fare_amount) FROM seat_holds").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+
+	holdID := strings.TrimPrefix(w.Header().Get("Location"), "/book/confirm?hold_id=")
+	pt.confirm(holdID, "4242424242424242")
+	p := pt.holdPayment(t, holdID)
+	if p.Amount != total {
+		t.Errorf("charged %d, want the %d the four holds cost", p.Amount, total)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM tickets WHERE booking_id = ? AND status = 'confirmed'", p.BookingID); n != 4 {
+		t.Errorf("%d tickets on the booking, want 4", n)
+	}
+}
+
+func TestConnectLegsSkipsTheSameDeparture(t *testing.T) {
+	now := time.Now()
+	leg := searchResult{DepartureID: "d1", Train: "Express", Class: "second", Quota: "general", Currency: "INR", OnSale: true, DepartsAt: now, ArrivesAt: now.Add(time.Hour)}
+	later := leg
+	later.DepartsAt, later.ArrivesAt = now.Add(90*time.Minute), now.Add(3*time.Hour)
+
+	if got := connectLegs(database.Station{}, []searchResult{leg}, []searchResult{later}, 1); len(got) != 0 {
+		t.Errorf("connected a departure to itself: %+v", got)
+	}
+	// Another run of a train with the same name is a real change of trains
+	later.DepartureID = "d2"
+	if got := connectLegs(database.Station{}, []searchResult{leg}, []searchResult{later}, 1); len(got) != 1 {
+		t.Errorf("got %d connections between two departures, want 1", len(got))
+	}
+}
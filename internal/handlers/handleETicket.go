@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/eticket"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/ticketstate"
+	"strconv"
+	"time"
+)
+
+// eticketStates are the ticket states an e-ticket can be issued in
+var eticketStates = []ticketstate.State{ticketstate.Confirmed, ticketstate.CheckedIn, ticketstate.Boarded}
+
+// eticketTicket fetches the requested ticket if it belongs to the user and can be
+// travelled on
+func eticketTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request) (database.GetTicketRow, bool) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return database.GetTicketRow{}, false
+	}
+
+	ticket, err := appState.DB.GetTicket(r.Context(), r.FormValue("ticket_id"))
+	if err == nil && ticket.UserID == userID.String() {
+		for _, state := range eticketStates {
+			if ticket.Status == string(state) {
+				return ticket, true
+			}
+		}
+	}
+
+	log.Println("Ticket not found for e-ticket:", r.FormValue("ticket_id"), err)
+	http.Error(w, "Ticket not found", http.StatusNotFound)
+	return database.GetTicketRow{}, false
+}
+
+// signETicket signs the e-ticket for a ticket, valid until a while after the train
+// reaches the passenger's destination
+func signETicket(appState *app.AppState, ticket database.GetTicketRow) (eticket.Payload, string, error) {
+	payload := eticket.Payload{
+		TicketID:    ticket.ID,
+		DepartureID: ticket.DepartureID,
+		Seat:        ticket.SeatLabel,
+		Passenger:   ticket.PassengerName.String,
+		ExpiresAt:   ticket.DestinationArrivesAt().Add(eticket.ValidityAfterArrival).Unix(),
+	}
+	token, err := appState.ETickets.Sign(payload)
+	return payload, token, err
+}
+
+// HandleETicketQR serves a ticket's signed e-ticket as a QR code
+func HandleETicketQR(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	ticket, ok := eticketTicket(appState, w, r)
+	if !ok {
+		return
+	}
+
+	_, token, err := signETicket(appState, ticket)
+	if err != nil {
+		log.Println("Error signing e-ticket:", err)
+		http.Error(w, "Failed to issue e-ticket", http.StatusInternalServerError)
+		return
+	}
+
+	png, err := eticket.QRCode(token, 320)
+	if err != nil {
+		log.Println("Error rendering QR code:", err)
+		http.Error(w, "Failed to issue e-ticket", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Write(png)
+}
+
+// HandleETicketPDF serves a printable e-ticket
+func HandleETicketPDF(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	ticket, ok := eticketTicket(appState, w, r)
+	if !ok {
+		return
+	}
+
+	payload, token, err := signETicket(appState, ticket)
+	if err != nil {
+		log.Println("Error signing e-ticket:", err)
+		http.Error(w, "Failed to issue e-ticket", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = eticket.WritePDF(&buf, eticket.Details{
+		PNR:         ticket.Pnr.String,
+		Passenger:   ticket.PassengerName.String,
+		Train:       ticket.Name,
+		Date:        ticket.ServiceDate,
+		From:        ticket.OriginName,
+		To:          ticket.DestinationName,
+		Seat:        ticket.SeatLabel,
+		Class:       ticket.Class,
+		Fare:        pricing.FormatAmount(ticket.FareAmount, ticket.FareCurrency),
+		DepartsAt:   ticket.OriginDepartsAt().In(appState.Location),
+		ArrivesAt:   ticket.DestinationArrivesAt().In(appState.Location),
+		TicketID:    ticket.ID,
+		ValidBefore: time.Unix(payload.ExpiresAt, 0).In(appState.Location),
+	}, token)
+	if err != nil {
+		log.Println("Error rendering e-ticket PDF:", err)
+		http.Error(w, "Failed to issue e-ticket", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="eticket-`+ticket.ID+`.pdf"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// HandleETicketPublicKey publishes the key conductors' devices use to verify
+// e-tickets offline
+func HandleETicketPublicKey(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(appState.ETickets.PublicKey() + "\n"))
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"strconv"
+)
+
+// HandleFleet is the admin page for trains, their coaches and departures. Every form
+// on it posts back here with an action; a change that fails shows the page again
+// with the reason.
+func HandleFleet(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	trainID := r.URL.Query().Get("train_id")
+
+	var formError string
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			log.Println("Error parsing form:", err)
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		trainID = r.FormValue("train_id")
+
+		var err error
+		trainID, formError, err = fleetAction(appState, r, trainID)
+		if err != nil {
+			_, message := fleetFailure(err, r.FormValue("action"))
+			formError = displayText(message)
+		}
+		if formError == "" {
+			http.Redirect(w, r, "/admin/fleet?train_id="+url.QueryEscape(trainID), http.StatusSeeOther)
+			return
+		}
+	}
+
+	trains, err := appState.DB.ListTrains(r.Context())
+	if err != nil {
+		log.Println("Error fetching trains:", err)
+		http.Error(w, "Failed to fetch trains", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Trains":   trains,
+		"Classes":  fareClasses(appState),
+		"Error":    formError,
+		"TimeZone": appState.Location.String(),
+	}
+	for _, t := range trains {
+		if t.ID != trainID {
+			continue
+		}
+		train, err := loadTrain(r.Context(), appState.DB, t)
+		if err != nil {
+			log.Println("Error fetching coaches:", err)
+			http.Error(w, "Failed to fetch train", http.StatusInternalServerError)
+			return
+		}
+		departures, err := listDepartures(r.Context(), appState.DB, appState.Location, t.ID)
+		if err != nil {
+			log.Println("Error fetching departures:", err)
+			http.Error(w, "Failed to fetch departures", http.StatusInternalServerError)
+			return
+		}
+		data["Train"] = train
+		data["Departures"] = departures
+	}
+
+	if formError != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	err = appState.Templates.ExecuteTemplate(w, "fleet.html", data)
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// fleetAction carries out one of the fleet page's forms and returns the train to show
+// afterwards. Forms filled in wrongly are reported as a message rather than an error.
+func fleetAction(appState *app.AppState, r *http.Request, trainID string) (string, string, error) {
+	ctx := r.Context()
+	switch r.FormValue("action") {
+	case "create train":
+		name, err := parseTrainName(r.FormValue("name"))
+		if err != nil {
+			return trainID, displayText(err.Error()), nil
+		}
+		var train database.Train
+		err = appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+			train, err = createTrain(ctx, q, name, nil)
+			return err
+		})
+		if err != nil {
+			return trainID, "", err
+		}
+		return train.ID, "", nil
+
+	case "rename train":
+		name, err := parseTrainName(r.FormValue("name"))
+		if err != nil {
+			return trainID, displayText(err.Error()), nil
+		}
+		n, err := appState.DB.RenameTrain(ctx, database.RenameTrainParams{Name: name, ID: trainID})
+		if err == nil && n == 0 {
+			err = errTrainNotFound
+		}
+		return trainID, "", err
+
+	case "retire train":
+		err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+			_, err := retireTrain(ctx, q, trainID)
+			return err
+		})
+		return trainID, "", err
+
+	case "add coach", "resize coach":
+		seats, err := strconv.ParseInt(r.FormValue("seats"), 10, 64)
+		if err != nil {
+			return trainID, "Number of seats is required", nil
+		}
+		req := coachRequest{
+			ID:    r.FormValue("coach_id"),
+			Code:  r.FormValue("code"),
+			Class: r.FormValue("class"),
+			Seats: seats,
+		}
+		if err := req.validate(appState); err != nil {
+			return trainID, displayText(err.Error()), nil
+		}
+		err = appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+			if req.ID != "" {
+				_, err := resizeCoach(ctx, q, req.ID, req.Seats)
+				return err
+			}
+			_, err := addCoach(ctx, q, trainID, req)
+			return err
+		})
+		return trainID, "", err
+
+	case "schedule departure", "reschedule departure":
+		departsAt, err := parseAdminTime(r.FormValue("departs_at"), appState.Location)
+		if err != nil || departsAt.IsZero() {
+			return trainID, "Departure time is required", nil
+		}
+		arrivesAt, err := parseAdminTime(r.FormValue("arrives_at"), appState.Location)
+		if err != nil {
+			return trainID, "Invalid arrival time", nil
+		}
+		if id := r.FormValue("departure_id"); id != "" {
+			_, err = rescheduleDeparture(ctx, appState.DB, id, departsAt, arrivesAt)
+		} else {
+			_, err = createDeparture(ctx, appState.DB, appState.Location, trainID, departsAt, arrivesAt)
+		}
+		return trainID, "", err
+
+	case "cancel departure":
+		return trainID, "", cancelDeparture(ctx, appState.DB, r.FormValue("departure_id"))
+	}
+	return trainID, "Unknown action", nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/ratelimit"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// heldItinerary is the seat holds that are paid for together: one per passenger of
+// the party, and per leg of a connection
+type heldItinerary struct {
+	Holds     []database.GetItineraryHoldsRow
+	Amount    int64
+	Currency  string
+	ExpiresAt time.Time
+}
+
+// getHeldItinerary fetches a user's hold with the other holds of its party and
+// connection. The itinerary is priced at the sum of its holds and expires with its
+// earliest hold.
+func getHeldItinerary(ctx context.Context, q database.QueriesInterface, holdID, userID string) (heldItinerary, error) {
+	holds, err := q.GetItineraryHolds(ctx, database.GetItineraryHoldsParams{
+		HoldID: holdID,
+		UserID: userID,
+	})
+	if err != nil {
+		return heldItinerary{}, err
+	}
+	if len(holds) == 0 || int64(len(holds)) < holds[0].Legs*holds[0].Passengers {
+		// A passenger's seat or a leg has already been released or swept
+		return heldItinerary{}, sql.ErrNoRows
+	}
+
+	it := heldItinerary{Holds: holds, Currency: holds[0].FareCurrency, ExpiresAt: holds[0].ExpiresAt}
+	for _, hold := range holds {
+		it.Amount += hold.FareAmount
+		if hold.ExpiresAt.Before(it.ExpiresAt) {
+			it.ExpiresAt = hold.ExpiresAt
+		}
+	}
+	return it, nil
+}
+
+// HandleConfirmBooking shows a held seat (GET) and pays for it (POST). The hold only
+// becomes a ticket once the payment is captured. The legs of a connection are shown
+// and paid for together.
+func HandleConfirmBooking(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		holdID, err := uuid.Parse(r.URL.Query().Get("hold_id"))
+		if err != nil {
+			log.Println("Invalid hold UUID:", err)
+			http.Redirect(w, r, "/book", http.StatusSeeOther)
+			return
+		}
+
+		itinerary, err := getHeldItinerary(r.Context(), appState.DB, holdID.String(), userID.String())
+		if err != nil {
+			log.Println("Error fetching seat hold:", err)
+			http.Redirect(w, r, "/book", http.StatusSeeOther)
+			return
+		}
+
+		err = appState.Templates.ExecuteTemplate(w, "confirm.html", map[string]interface{}{
+			"HoldID":    holdID.String(),
+			"Itinerary": itinerary,
+			"Remaining": time.Until(itinerary.ExpiresAt).Round(time.Second),
+			"Expired":   !itinerary.ExpiresAt.After(time.Now()),
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	holdID, err := uuid.Parse(r.FormValue("hold_id"))
+	if err != nil {
+		log.Println("Invalid hold UUID:", err)
+		http.Redirect(w, r, "/book", http.StatusSeeOther)
+		return
+	}
+
+	itinerary, err := getHeldItinerary(r.Context(), appState.DB, holdID.String(), userID.String())
+	if err != nil || !itinerary.ExpiresAt.After(time.Now()) {
+		log.Println("Seat hold not found or expired:", holdID, err)
+		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+			"Error": "Your seat hold has expired, please book again",
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+		}
+		return
+	}
+
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	// A seat offered from the waitlist comes with its payment already started
+	p, err := appState.DB.GetHoldPayment(r.Context(), database.GetHoldPaymentParams{
+		HoldID: holdID.String(),
+		UserID: userID.String(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		p, err = appState.DB.CreatePayment(r.Context(), database.CreatePaymentParams{
+			ID:       uuid.New().String(),
+			UserID:   userID.String(),
+			HoldID:   holdID.String(),
+			Provider: appState.Payments.Name(),
+			Amount:   itinerary.Amount,
+			Currency: itinerary.Currency,
+		})
+	}
+	if err != nil {
+		log.Println("Error creating payment:", err)
+		http.Error(w, "Failed to start payment", http.StatusInternalServerError)
+		return
+	}
+
+	// A payment settled later by the provider's webhook is audited against this address
+	p.ClientIP = ratelimit.ClientIP(r)
+	err = appState.DB.SetPaymentClientIP(r.Context(), database.SetPaymentClientIPParams{
+		ID:       p.ID,
+		ClientIP: p.ClientIP,
+	})
+	if err != nil {
+		log.Println("Error recording payment client address:", err)
+	}
+
+	p, err = chargePayment(r.Context(), appState, p, r.FormValue("payment_source"))
+	auditBooking(appState, r, p, err)
+	if err != nil && !errors.Is(err, errHoldExpired) {
+		log.Println("Error charging payment:", err)
+		http.Error(w, "Failed to process payment", http.StatusInternalServerError)
+		return
+	}
+
+	if p.Status == string(payment.Captured) {
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/payments/status?payment_id="+p.ID, http.StatusSeeOther)
+}
+
+// HandleReleaseHold gives up a held seat, and the other legs of its connection, before
+// its expiry. The freed seats are offered to the waitlist.
+func HandleReleaseHold(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	holdID, err := uuid.Parse(r.FormValue("hold_id"))
+	if err != nil {
+		log.Println("Invalid hold UUID:", err)
+		http.Redirect(w, r, "/book", http.StatusSeeOther)
+		return
+	}
+
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	err = releaseItinerary(r.Context(), appState, appState.DB, holdID.String(), userID.String())
+	if err != nil {
+		log.Println("Error releasing seat hold:", err)
+		http.Error(w, "Failed to release seat", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/book", http.StatusSeeOther)
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/quota"
+	"rsvbackend/internal/ticketstate"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errWrongRoute     = errors.New("this train does not run between your stations")
+	errSeatTaken      = errors.New("seat already booked or invalid")
+	errTicketChanged  = errors.New("this ticket can no longer be changed")
+	errTicketDeparted = errors.New("this train has already left")
+	errFareNotPaid    = errors.New("the fare difference could not be charged, your ticket has not been changed")
+)
+
+// modifyOption is a departure a ticket can be changed to, with the fare to pay
+// (positive) or have refunded (negative) for the change
+type modifyOption struct {
+	departureOption
+	Difference int64
+}
+
+// renderModifyTicket lists the departures a ticket can be changed to
+func renderModifyTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request, ticket database.GetTicketRow, message string) {
+	departures, err := appState.DB.GetAvailableTickets(r.Context(), database.GetAvailableTicketsParams{
+		OriginStationID:      ticket.OriginStationID,
+		DestinationStationID: ticket.DestinationStationID,
+	})
+	if err != nil {
+		log.Println("Error fetching available tickets:", err)
+		http.Error(w, "Failed to fetch available tickets", http.StatusInternalServerError)
+		return
+	}
+
+	var options []modifyOption
+	for _, option := range quoteDepartures(appState.Fares, appState.Location, generalRows(onSaleRows(departures, appState.Location))) {
+		options = append(options, modifyOption{
+			departureOption: option,
+			Difference:      option.Fare.Total - ticket.FareAmount,
+		})
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "modify.html", map[string]interface{}{
+		"Ticket":  ticket,
+		"Options": options,
+		"Error":   message,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// modifyTicket issues a replacement for a ticket on another seat, train or date and
+// cancels the original. The new seat is taken before the old one is released, all in
+// one transaction, so the user never ends up without a seat. A dearer fare is charged
+// to the booking before the change is made, and refunded if the change then fails; a
+// cheaper one is refunded from the booking's payments as part of the change. It must
+// run inside the critical section.
+func modifyTicket(ctx context.Context, appState *app.AppState, old database.GetTicketRow, departureID, class, seatNumber, preference, source string) (database.TicketModification, error) {
+	if !old.OriginDepartsAt().After(time.Now()) {
+		return database.TicketModification{}, errTicketDeparted
+	}
+
+	stops, err := appState.DB.GetJourneyStops(ctx, database.GetJourneyStopsParams{
+		DepartureID:          departureID,
+		OriginStationID:      old.OriginStationID,
+		DestinationStationID: old.DestinationStationID,
+	})
+	if err != nil {
+		log.Println("Invalid journey for departure:", err)
+		return database.TicketModification{}, errWrongRoute
+	}
+
+	fare, err := quoteSegment(ctx, appState.DB, appState.Fares, departureID, class, stops.OriginStop, stops.DestinationStop)
+	if err != nil {
+		return database.TicketModification{}, err
+	}
+	difference := fare.Total - old.FareAmount
+
+	var charged database.Payment
+	if difference > 0 {
+		charged, err = chargeFareDifference(ctx, appState, old, difference, fare.Currency, source)
+		if err != nil {
+			return database.TicketModification{}, err
+		}
+	}
+
+	var modification database.TicketModification
+	var refunds []string
+	err = appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		// Replacement tickets are issued in the general quota
+		seat, _, err := chooseSeat(ctx, q, departureID, class, stops, seatNumber, preference, quota.General)
+		if err != nil {
+			return err
+		}
+
+		replacement, err := q.CreateTicket(ctx, database.CreateTicketParams{
+			ID:                  uuid.New().String(),
+			DepartureID:         departureID,
+			UserID:              old.UserID,
+			SeatNumber:          seat,
+			OriginStop:          stops.OriginStop,
+			DestinationStop:     stops.DestinationStop,
+			FareAmount:          fare.Total,
+			FareCurrency:        fare.Currency,
+			PassengerName:       old.PassengerName,
+			PassengerAge:        old.PassengerAge,
+			PassengerGender:     old.PassengerGender,
+			PassengerIDDocument: old.PassengerIDDocument,
+			BookingID:           old.BookingID,
+			SalesClock:          database.SalesClock(time.Now(), appState.Location),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errSeatTaken
+		}
+		if err != nil {
+			return err
+		}
+
+		err = ticketstate.Issue(ctx, q, replacement.ID, ticketstate.Confirmed, old.UserID, "replaces ticket "+old.ID)
+		if err != nil {
+			return err
+		}
+
+		freed, err := ticketstate.Transition(ctx, q, ticketstate.Change{
+			TicketID: old.ID,
+			From:     ticketstate.Confirmed,
+			To:       ticketstate.Cancelled,
+			Actor:    old.UserID,
+			Reason:   "changed to ticket " + replacement.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errTicketChanged
+		}
+		if err != nil {
+			return err
+		}
+
+		modification, err = q.CreateTicketModification(ctx, database.CreateTicketModificationParams{
+			ID:             uuid.New().String(),
+			OldTicketID:    old.ID,
+			NewTicketID:    replacement.ID,
+			OldFareAmount:  old.FareAmount,
+			NewFareAmount:  replacement.FareAmount,
+			FareDifference: difference,
+			Currency:       replacement.FareCurrency,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case difference > 0:
+			err = q.SetPaymentTicket(ctx, database.SetPaymentTicketParams{
+				ID:        charged.ID,
+				TicketID:  sql.NullString{String: replacement.ID, Valid: true},
+				BookingID: old.BookingID,
+			})
+		case difference < 0:
+			// Nothing is refunded beyond what was paid for the booking
+			refunds, err = refundTicket(ctx, q, old.ID, -difference)
+		}
+		if err != nil {
+			return err
+		}
+
+		return promoteWaitlist(ctx, appState, q, freed.DepartureID, freed.SeatNumber)
+	})
+	if err != nil {
+		// The change rolled back, and any refund queued with it
+		refunds = nil
+		if difference > 0 {
+			if refundErr := queueRefund(ctx, appState.DB, charged, charged.Amount); refundErr != nil {
+				log.Printf("Error refunding fare difference %s: %v", charged.ID, refundErr)
+			} else {
+				refunds = append(refunds, charged.ID)
+			}
+		}
+	}
+	makeRefunds(ctx, appState, refunds...)
+	return modification, err
+}
+
+// chargeFareDifference takes the extra fare for changing a ticket as a payment on its
+// booking. The change only goes ahead once the payment is captured. A capture the
+// provider confirms later by webhook has no seat hold to confirm, so it is refunded.
+func chargeFareDifference(ctx context.Context, appState *app.AppState, ticket database.GetTicketRow, amount int64, currency, source string) (database.Payment, error) {
+	// A fare difference is not taken against a seat hold
+	p, err := appState.DB.CreatePayment(ctx, database.CreatePaymentParams{
+		ID:       uuid.New().String(),
+		UserID:   ticket.UserID,
+		HoldID:   "",
+		Provider: appState.Payments.Name(),
+		Amount:   amount,
+		Currency: currency,
+	})
+	if err != nil {
+		return p, err
+	}
+	err = appState.DB.SetPaymentTicket(ctx, database.SetPaymentTicketParams{
+		ID:        p.ID,
+		TicketID:  sql.NullString{String: ticket.ID, Valid: true},
+		BookingID: ticket.BookingID,
+	})
+	if err != nil {
+		return p, err
+	}
+
+	res, err := appState.Payments.Authorize(ctx, payment.Request{
+		PaymentID: p.ID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Source:    source,
+	})
+	if err != nil {
+		log.Println("Error authorizing payment:", err)
+		res = payment.Result{Status: payment.Failed, Reason: "payment provider unavailable"}
+	}
+	if res.Ref != "" {
+		p.ProviderRef = sql.NullString{String: res.Ref, Valid: true}
+		err = appState.DB.SetPaymentRef(ctx, database.SetPaymentRefParams{ID: p.ID, ProviderRef: p.ProviderRef})
+		if err != nil {
+			return p, err
+		}
+	}
+	if res.Status == payment.Authorized {
+		p, err = appState.DB.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+			ID:         p.ID,
+			FromStatus: p.Status,
+			ToStatus:   string(payment.Authorized),
+		})
+		if err != nil {
+			return p, err
+		}
+		res, err = appState.Payments.Capture(ctx, res.Ref, p.Amount)
+		if err != nil {
+			log.Println("Error capturing payment:", err)
+			return p, errFareNotPaid
+		}
+	}
+
+	switch res.Status {
+	case payment.Captured:
+		return appState.DB.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+			ID:         p.ID,
+			FromStatus: p.Status,
+			ToStatus:   string(payment.Captured),
+		})
+	case payment.Failed:
+		_, err = appState.DB.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+			ID:            p.ID,
+			FromStatus:    p.Status,
+			ToStatus:      string(payment.Failed),
+			FailureReason: res.Reason,
+		})
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, errFareNotPaid
+}
+
+// modificationAudit is the audit event for changing a ticket: the ticket it became and
+// the fare difference charged (positive) or refunded (negative), or the change asked
+// for and why it was refused
+func modificationAudit(actor string, old database.GetTicketRow, departureID, class string, m database.TicketModification, err error) audit.Entry {
+	e := audit.Entry{Actor: actor, Action: audit.Modification, Target: "ticket:" + old.ID, Result: auditResult(err)}
+	if err != nil {
+		e.Detail = "to departure:" + departureID + " " + class + ": " + err.Error()
+		return e
+	}
+	e.Detail = "to ticket:" + m.NewTicketID + " fare difference " + pricing.FormatAmount(m.FareDifference, m.Currency)
+	return e
+}
+
+// HandleModifyTicket changes a user's ticket to another seat, train or date between
+// the same stations
+func HandleModifyTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := appState.DB.GetTicket(r.Context(), r.FormValue("ticket_id"))
+	if err != nil || ticket.UserID != userID.String() || ticket.Status != string(ticketstate.Confirmed) {
+		log.Println("Ticket not found for modification:", r.FormValue("ticket_id"), err)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+
+	if !ticket.OriginDepartsAt().After(time.Now()) {
+		log.Println("Ticket has departed, cannot modify:", ticket.ID)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderModifyTicket(appState, w, r, ticket, "")
+		return
+	}
+
+	// The departure select carries "<departure id>/<class>"
+	departureIDStr, class, _ := strings.Cut(r.FormValue("departure"), "/")
+	departureID, err := uuid.Parse(departureIDStr)
+	if err != nil {
+		log.Println("Invalid departure UUID:", err)
+		renderModifyTicket(appState, w, r, ticket, "Invalid departure ID")
+		return
+	}
+
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	// The ticket may have been cancelled or changed by another request while this one
+	// waited, and must not be charged for again
+	current, err := appState.DB.GetTicket(r.Context(), ticket.ID)
+	if err != nil || current.Status != string(ticketstate.Confirmed) {
+		log.Println("Ticket changed before modification:", ticket.ID, err)
+		renderModifyTicket(appState, w, r, ticket, displayText(errTicketChanged.Error()))
+		return
+	}
+	ticket = current
+
+	modification, err := modifyTicket(r.Context(), appState, ticket, departureID.String(), class, r.FormValue("seat_number"), r.FormValue("preference"), r.FormValue("payment_source"))
+	RecordAudit(appState, r, modificationAudit(userID.String(), ticket, departureID.String(), class, modification, err))
+	switch {
+	case errors.Is(err, errWrongRoute), errors.Is(err, errNoSeatInClass), errors.Is(err, errInvalidSeat),
+		errors.Is(err, errSeatTaken), errors.Is(err, errTicketChanged), errors.Is(err, errTicketDeparted),
+		errors.Is(err, errFareNotPaid):
+		renderModifyTicket(appState, w, r, ticket, displayText(err.Error()))
+		return
+	case err != nil:
+		log.Println("Error modifying ticket:", err)
+		http.Error(w, "Failed to change ticket", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Changed ticket %s to %s, fare difference %d", modification.OldTicketID, modification.NewTicketID, modification.FareDifference)
+	http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+}
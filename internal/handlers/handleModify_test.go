@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestModifyRetryIsReplayed(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+	pt.confirm(hold.ID, "4242424242424242")
+	old := pt.holdPayment(t, hold.ID).TicketID.String
+	modify := Idempotent("modify", HandleModifyTicket)
+
+	post := func() *httptest.ResponseRecorder {
+		form := url.Values{"ticket_id": {old}, "departure": {testDepartureID + "/ac_chair"}, "seat_number": {"2"}, idempotencyField: {"modify-key"}}
+		r := httptest.NewRequest(http.MethodPost, "/modify", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(pt.cookie)
+		w := httptest.NewRecorder()
+		modify(pt.appState, w, r)
+		return w
+	}
+
+	first := post()
+	retry := post()
+	if first.Code != http.StatusSeeOther || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("got %d then %d (replayed %q), want the change replayed", first.Code, retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM ticket_modifications"); n != 1 {
+		t.Errorf("%d modifications after a retried change, want 1", n)
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// passengerGenders are the values accepted for a passenger's gender
+var passengerGenders = []string{"female", "male", "other"}
+
+// maxPartySize is the most passengers that can be booked together on one form
+const maxPartySize = 6
+
+// partyPlaces numbers the passenger fieldsets of the booking form
+var partyPlaces = func() []int {
+	places := make([]int, maxPartySize)
+	for i := range places {
+		places[i] = i + 1
+	}
+	return places
+}()
+
+// passengerDetails is the traveller a seat is booked for, copied onto holds,
+// waitlist entries and tickets
+type passengerDetails struct {
+	Name       sql.NullString
+	Age        sql.NullInt64
+	Gender     sql.NullString
+	IDDocument sql.NullString
+}
+
+// parsePassenger validates the passenger fields of a form
+func parsePassenger(form url.Values) (database.CreateSavedPassengerParams, error) {
+	name := strings.TrimSpace(form.Get("passenger_name"))
+	if name == "" {
+		return database.CreateSavedPassengerParams{}, errors.New("passenger name is required")
+	}
+
+	age, err := strconv.Atoi(form.Get("passenger_age"))
+	if err != nil || age < 0 || age > 120 {
+		return database.CreateSavedPassengerParams{}, errors.New("invalid passenger age")
+	}
+
+	gender := form.Get("passenger_gender")
+	valid := false
+	for _, g := range passengerGenders {
+		if g == gender {
+			valid = true
+		}
+	}
+	if !valid {
+		return database.CreateSavedPassengerParams{}, errors.New("invalid passenger gender")
+	}
+
+	idDocument := strings.TrimSpace(form.Get("passenger_id_document"))
+	return database.CreateSavedPassengerParams{
+		Name:       name,
+		Age:        int64(age),
+		Gender:     gender,
+		IDDocument: sql.NullString{String: idDocument, Valid: idDocument != ""},
+	}, nil
+}
+
+// passengerFromForm resolves who a booking form is for: one of the user's saved
+// passengers, or details typed into the form, which are saved if asked to
+func passengerFromForm(ctx context.Context, q database.QueriesInterface, r *http.Request, userID string) (passengerDetails, error) {
+	if err := r.ParseForm(); err != nil {
+		return passengerDetails{}, errors.New("invalid passenger details")
+	}
+	return resolvePassenger(ctx, q, r.Form, userID)
+}
+
+// partyFromForm resolves the passengers of a booking form that repeats the passenger
+// fields once per place in the party. Places left blank are skipped, and each
+// "save_passenger" box carries the number of the place it saves.
+func partyFromForm(ctx context.Context, q database.QueriesInterface, r *http.Request, userID string) ([]passengerDetails, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.New("invalid passenger details")
+	}
+
+	at := func(key string, i int) string {
+		if values := r.Form[key]; i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+
+	var party []passengerDetails
+	for i := range r.Form["passenger_name"] {
+		place := url.Values{}
+		for _, key := range []string{"saved_passenger_id", "passenger_name", "passenger_age", "passenger_gender", "passenger_id_document"} {
+			place.Set(key, at(key, i))
+		}
+		if place.Get("saved_passenger_id") == "" && strings.TrimSpace(place.Get("passenger_name")) == "" {
+			continue
+		}
+		if slices.Contains(r.Form["save_passenger"], strconv.Itoa(i+1)) {
+			place.Set("save_passenger", "on")
+		}
+
+		p, err := resolvePassenger(ctx, q, place, userID)
+		if err != nil {
+			return nil, fmt.Errorf("passenger %d: %w", i+1, err)
+		}
+		party = append(party, p)
+	}
+
+	if len(party) == 0 {
+		return nil, errors.New("passenger name is required")
+	}
+	if len(party) > maxPartySize {
+		return nil, fmt.Errorf("at most %d passengers can be booked together", maxPartySize)
+	}
+	return party, nil
+}
+
+// resolvePassenger reads one passenger's fields: a saved passenger, or typed-in
+// details that are saved if asked to
+func resolvePassenger(ctx context.Context, q database.QueriesInterface, form url.Values, userID string) (passengerDetails, error) {
+	var p database.SavedPassenger
+	if savedID := form.Get("saved_passenger_id"); savedID != "" {
+		saved, err := q.GetSavedPassenger(ctx, database.GetSavedPassengerParams{
+			ID:     savedID,
+			UserID: userID,
+		})
+		if err != nil {
+			log.Println("Error fetching saved passenger:", err)
+			return passengerDetails{}, errors.New("unknown saved passenger")
+		}
+		p = saved
+	} else {
+		params, err := parsePassenger(form)
+		if err != nil {
+			return passengerDetails{}, err
+		}
+		p = database.SavedPassenger{
+			Name:       params.Name,
+			Age:        params.Age,
+			Gender:     params.Gender,
+			IDDocument: params.IDDocument,
+		}
+
+		if form.Get("save_passenger") != "" {
+			params.ID = uuid.New().String()
+			params.UserID = userID
+			if _, err := q.CreateSavedPassenger(ctx, params); err != nil {
+				// The booking can go ahead without the address book entry
+				log.Println("Error saving passenger:", err)
+			}
+		}
+	}
+
+	return passengerDetails{
+		Name:       sql.NullString{String: p.Name, Valid: true},
+		Age:        sql.NullInt64{Int64: p.Age, Valid: true},
+		Gender:     sql.NullString{String: p.Gender, Valid: true},
+		IDDocument: p.IDDocument,
+	}, nil
+}
+
+// HandlePassengers lists the user's saved passengers and adds new ones
+func HandlePassengers(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	var formError string
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			log.Println("Error parsing form:", err)
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+
+		params, err := parsePassenger(r.Form)
+		if err == nil {
+			params.ID = uuid.New().String()
+			params.UserID = userID.String()
+			_, err = appState.DB.CreateSavedPassenger(r.Context(), params)
+			if err != nil {
+				log.Println("Error saving passenger:", err)
+				http.Error(w, "Failed to save passenger", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/passengers", http.StatusSeeOther)
+			return
+		}
+		formError = displayText(err.Error())
+	}
+
+	passengers, err := appState.DB.ListSavedPassengers(r.Context(), userID.String())
+	if err != nil {
+		log.Println("Error fetching saved passengers:", err)
+		http.Error(w, "Failed to fetch saved passengers", http.StatusInternalServerError)
+		return
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "passengers.html", map[string]interface{}{
+		"Passengers": passengers,
+		"Genders":    passengerGenders,
+		"Error":      formError,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// HandleDeletePassenger removes a saved passenger; issued tickets keep their copy
+func HandleDeletePassenger(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	err := appState.DB.DeleteSavedPassenger(r.Context(), database.DeleteSavedPassengerParams{
+		ID:     r.FormValue("passenger_id"),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		log.Println("Error deleting saved passenger:", err)
+		http.Error(w, "Failed to delete passenger", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/passengers", http.StatusSeeOther)
+}
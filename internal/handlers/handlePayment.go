@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/audit"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/jobs"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/ticketstate"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errHoldExpired    = errors.New("your seat hold expired before the payment completed; the payment has been refunded")
+	errPaymentSettled = errors.New("payment has already been settled")
+	errRefundTooLarge = errors.New("refund is more than is left of the payment")
+)
+
+// chargePayment authorizes and captures a payment for a seat hold. Capture turns the
+// hold into a ticket; failure releases it. If the provider completes the capture
+// later, the payment is returned still authorized and a webhook settles it. It must
+// run inside the critical section.
+func chargePayment(ctx context.Context, appState *app.AppState, p database.Payment, source string) (database.Payment, error) {
+	res, err := appState.Payments.Authorize(ctx, payment.Request{
+		PaymentID: p.ID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Source:    source,
+	})
+	if err != nil {
+		log.Println("Error authorizing payment:", err)
+		res = payment.Result{Status: payment.Failed, Reason: "payment provider unavailable"}
+	}
+	if res.Ref != "" {
+		p.ProviderRef = sql.NullString{String: res.Ref, Valid: true}
+		err = appState.DB.SetPaymentRef(ctx, database.SetPaymentRefParams{ID: p.ID, ProviderRef: p.ProviderRef})
+		if err != nil {
+			return p, err
+		}
+	}
+	if res.Status != payment.Authorized {
+		return settlePayment(ctx, appState, p, res.Status, res.Reason)
+	}
+
+	p, err = settlePayment(ctx, appState, p, payment.Authorized, "")
+	if err != nil {
+		return p, err
+	}
+
+	res, err = appState.Payments.Capture(ctx, res.Ref, p.Amount)
+	if err != nil {
+		// The capture may or may not have gone through; the provider's webhook will say
+		log.Println("Error capturing payment:", err)
+		return p, nil
+	}
+	if res.Status == payment.Pending {
+		return p, nil
+	}
+	return settlePayment(ctx, appState, p, res.Status, res.Reason)
+}
+
+// settlePayment moves a payment to a new status. A capture confirms the payment's seat
+// hold as a ticket and a failure releases the hold, offering its seats to the waitlist.
+// Moves that are no longer possible, such as a repeated webhook, return
+// errPaymentSettled. Captures and failures must run inside the critical section.
+func settlePayment(ctx context.Context, appState *app.AppState, p database.Payment, to payment.Status, reason string) (database.Payment, error) {
+	if !payment.CanTransition(payment.Status(p.Status), to) {
+		return p, errPaymentSettled
+	}
+	if to == payment.Captured {
+		return capturePayment(ctx, appState, p)
+	}
+
+	var updated database.Payment
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		var err error
+		updated, err = q.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+			ID:            p.ID,
+			FromStatus:    p.Status,
+			ToStatus:      string(to),
+			FailureReason: reason,
+		})
+		if err != nil || to != payment.Failed {
+			return err
+		}
+		return releaseItinerary(ctx, appState, q, p.HoldID, p.UserID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, errPaymentSettled
+	}
+	if err != nil {
+		return p, err
+	}
+	if to == payment.Failed {
+		log.Printf("Payment %s failed (%s), released seat hold %s", p.ID, reason, p.HoldID)
+	}
+	return updated, nil
+}
+
+// capturePayment records a captured payment and issues the tickets for its seat hold,
+// or for every leg of its connection, as one booking. The legs are confirmed together
+// or not at all: if any hold expired while the payment was in flight, nothing is
+// issued and the money is refunded.
+func capturePayment(ctx context.Context, appState *app.AppState, p database.Payment) (database.Payment, error) {
+	var captured database.Payment
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		var err error
+		captured, err = q.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+			ID:         p.ID,
+			FromStatus: p.Status,
+			ToStatus:   string(payment.Captured),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errPaymentSettled
+		}
+		if err != nil {
+			return err
+		}
+
+		itinerary, err := getHeldItinerary(ctx, q, p.HoldID, p.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errHoldExpired
+		}
+		if err != nil {
+			return err
+		}
+
+		channel := itinerary.Holds[0].Channel
+		if channel == "" {
+			channel = bookingChannel(ctx, q, p.UserID)
+		}
+		booking, err := createBooking(ctx, q, p.UserID, channel)
+		if err != nil {
+			return err
+		}
+		for i, hold := range itinerary.Holds {
+			ticket, err := q.ConfirmSeatHold(ctx, database.ConfirmSeatHoldParams{
+				ID:        uuid.New().String(),
+				HoldID:    hold.ID,
+				UserID:    p.UserID,
+				BookingID: sql.NullString{String: booking.ID, Valid: true},
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				// Rolling back undoes the holds already confirmed
+				return errHoldExpired
+			}
+			if err != nil {
+				return err
+			}
+			err = ticketstate.Issue(ctx, q, ticket.ID, ticketstate.Confirmed, p.UserID, "payment "+p.ID+" captured")
+			if err != nil {
+				return err
+			}
+			if hold.Channel == channelWaitlist {
+				err = q.SetWaitlistTicket(ctx, database.SetWaitlistTicketParams{
+					HoldID:   sql.NullString{String: hold.ID, Valid: true},
+					TicketID: sql.NullString{String: ticket.ID, Valid: true},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if i > 0 {
+				continue
+			}
+			captured.TicketID = sql.NullString{String: ticket.ID, Valid: true}
+			captured.BookingID = sql.NullString{String: booking.ID, Valid: true}
+			err = q.SetPaymentTicket(ctx, database.SetPaymentTicketParams{
+				ID:        p.ID,
+				TicketID:  captured.TicketID,
+				BookingID: captured.BookingID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return q.DeleteItineraryHolds(ctx, database.DeleteItineraryHoldsParams{
+			HoldID: p.HoldID,
+			UserID: p.UserID,
+		})
+	})
+	if !errors.Is(err, errHoldExpired) {
+		if err != nil {
+			return p, err
+		}
+		return captured, nil
+	}
+
+	// A seat went back on sale before the money arrived: keep the record of the
+	// capture and give the money back
+	captured, err = appState.DB.UpdatePaymentStatus(ctx, database.UpdatePaymentStatusParams{
+		ID:            p.ID,
+		FromStatus:    p.Status,
+		ToStatus:      string(payment.Captured),
+		FailureReason: "seat hold expired before payment completed",
+	})
+	if err != nil {
+		return p, err
+	}
+	if err := queueRefund(ctx, appState.DB, captured, captured.Amount); err != nil {
+		return captured, err
+	}
+	makeRefunds(ctx, appState, captured.ID)
+	if refunded, err := appState.DB.GetPayment(ctx, captured.ID); err == nil {
+		captured = refunded
+	}
+	return captured, errHoldExpired
+}
+
+// auditBooking records how a payment for a booking turned out. Payments still pending
+// are recorded when the provider's webhook settles them, from the address the customer
+// paid from rather than the provider's.
+func auditBooking(appState *app.AppState, r *http.Request, p database.Payment, err error) {
+	e := audit.Entry{Actor: p.UserID, Action: audit.Booking, Target: "payment:" + p.ID, IP: p.ClientIP, Detail: p.FailureReason}
+	switch {
+	case err == nil && p.Status == string(payment.Captured):
+		e.Target = "booking:" + p.BookingID.String
+		e.Result = audit.Success
+		e.Detail = "payment:" + p.ID
+	case errors.Is(err, errHoldExpired), err == nil && p.Status == string(payment.Failed):
+		e.Result = audit.Failure
+	default:
+		return
+	}
+	RecordAudit(appState, r, e)
+}
+
+// queueRefund queues a refund on a captured payment. Called with a transaction's
+// queries it commits or rolls back with the caller's other changes; the provider is
+// only asked for it by makeRefunds once the transaction has committed. No more than is
+// left of the payment is ever queued.
+func queueRefund(ctx context.Context, q database.QueriesInterface, p database.Payment, amount int64) error {
+	queued, err := q.QueueRefund(ctx, database.QueueRefundParams{
+		ID:        p.ID,
+		RefundDue: amount,
+	})
+	if err != nil {
+		return err
+	}
+	if queued == 0 {
+		return errRefundTooLarge
+	}
+	return nil
+}
+
+// makeRefunds asks the provider for the refunds queued on payments. A refund the
+// provider can't make now stays queued for jobs.RetryRefunds.
+func makeRefunds(ctx context.Context, appState *app.AppState, paymentIDs ...string) {
+	for _, id := range paymentIDs {
+		if _, err := jobs.MakeRefund(ctx, appState.DB, appState.Payments, id); err != nil {
+			log.Printf("Error refunding payment %s, queued for retry: %v", id, err)
+		}
+	}
+}
+
+// refundableAmount is how much of a ticket's booking was captured and not yet
+// refunded or queued for refund. Tickets issued without a payment have nothing to
+// refund.
+func refundableAmount(ctx context.Context, q database.QueriesInterface, ticketID string) (int64, error) {
+	payments, err := q.ListTicketPayments(ctx, ticketID)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, p := range payments {
+		total += p.Amount - p.RefundedAmount - p.RefundDue - p.RefundClaimed
+	}
+	return total, nil
+}
+
+// refundTicket queues refunds of up to amount for a ticket on the payments for its
+// booking, oldest first. A booking can have several: the first covers every ticket
+// and leg booked together, later ones the fare differences paid for changes. It
+// returns the payments to pass to makeRefunds once the caller's transaction commits.
+func refundTicket(ctx context.Context, q database.QueriesInterface, ticketID string, amount int64) ([]string, error) {
+	payments, err := q.ListTicketPayments(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	var queued []string
+	for _, p := range payments {
+		share := min(amount, p.Amount-p.RefundedAmount-p.RefundDue-p.RefundClaimed)
+		if share <= 0 {
+			break
+		}
+		if err := queueRefund(ctx, q, p, share); err != nil {
+			return nil, err
+		}
+		amount -= share
+		queued = append(queued, p.ID)
+	}
+	return queued, nil
+}
+
+// HandlePaymentStatus shows where a user's payment has got to, refreshing until the
+// provider settles it
+func HandlePaymentStatus(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	p, err := appState.DB.GetPayment(r.Context(), r.FormValue("payment_id"))
+	if err != nil || p.UserID != userID.String() {
+		log.Println("Payment not found:", r.FormValue("payment_id"), err)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "payment.html", map[string]interface{}{
+		"Payment": p,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// HandlePaymentWebhook applies a payment provider's notification that a payment was
+// captured, failed or refunded. Only correctly signed webhooks are accepted.
+func HandlePaymentWebhook(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	event, err := appState.Payments.ParseWebhook(body, r.Header.Get(payment.SignatureHeader))
+	if err != nil {
+		log.Println("Rejected payment webhook:", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	p, err := appState.DB.GetPayment(r.Context(), event.PaymentID)
+	if err != nil || (p.ProviderRef.Valid && p.ProviderRef.String != event.Ref) {
+		log.Println("Payment webhook for unknown payment:", event.PaymentID, event.Ref, err)
+		http.Error(w, "Unknown payment", http.StatusNotFound)
+		return
+	}
+
+	if event.Status == payment.Refunded {
+		// Refunds are started here, so the provider is only confirming one we recorded
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if event.Status == payment.Captured || event.Status == payment.Failed {
+		if !requestCriticalSection(appState, p.UserID) {
+			// The provider retries webhooks that don't succeed
+			http.Error(w, "Busy, try again", http.StatusServiceUnavailable)
+			return
+		}
+		defer releaseCriticalSection(appState)
+	}
+
+	settled, err := settlePayment(r.Context(), appState, p, event.Status, event.Reason)
+	auditBooking(appState, r, settled, err)
+	switch {
+	case errors.Is(err, errPaymentSettled):
+		log.Printf("Ignoring %s webhook for payment %s in status %s", event.Status, p.ID, p.Status)
+	case errors.Is(err, errHoldExpired):
+		log.Printf("Payment %s captured after its hold expired, refunded", p.ID)
+	case err != nil:
+		log.Println("Error applying payment webhook:", err)
+		http.Error(w, "Failed to apply webhook", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
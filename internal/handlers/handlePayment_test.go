@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/jobs"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/quota"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/sessions"
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+// testDepartureID is a departure from the seed data, moved into the future by newTestApp
+const testDepartureID = "550e8400-e29b-41d4-a716-446655440000"
+
+// paymentTest is an app backed by a migrated in-memory database and the fake gateway,
+// with one signed-in user
+type paymentTest struct {
+	appState *app.AppState
+	db       *sql.DB
+	fake     *payment.Fake
+	userID   string
+	cookie   *http.Cookie
+}
+
+func newTestApp(t *testing.T) *paymentTest {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite&_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "../../sql/schema"); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	departs := time.Now().UTC().Add(48 * time.Hour)
+	_, err = db.Exec("UPDATE departures SET departs_at = ?, service_date = ? WHERE id = ?",
+		departs.Format("2006-01-02 15:04:05"), departs.Format("2006-01-02"), testDepartureID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates := template.Must(template.New("").Funcs(template.FuncMap{
+		"money":          pricing.FormatAmount,
+		"idempotencyKey": uuid.NewString,
+		"local":          func(t time.Time) time.Time { return t },
+	}).ParseGlob("../../templates/*.html"))
+
+	queries := database.New(db)
+	store := sessions.NewCookieStore([]byte("test-session-key-0123456789abcdef"))
+	appState := app.NewAppState(queries, store, templates, "node-1", nil)
+	fake := payment.NewFake([]byte("test-webhook-secret"))
+	appState.Payments = fake
+
+	user, err := queries.CreateUser(context.Background(), database.CreateUserParams{
+		ID:       uuid.New().String(),
+		Email:    "passenger@example.com",
+		Password: "not-a-real-hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &paymentTest{
+		appState: appState,
+		db:       db,
+		fake:     fake,
+		userID:   user.ID,
+		cookie:   sessionCookie(t, store, user.ID),
+	}
+}
+
+// sessionCookie signs a user in the way HandleLogin does
+func sessionCookie(t *testing.T, store *sessions.CookieStore, userID string) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	session, err := store.Get(r, "session-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["userID"] = userID
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies()[0]
+}
+
+// holdSeat holds a seat on the test departure for the signed-in user
+func (pt *paymentTest) holdSeat(t *testing.T, seatNumber int64) database.SeatHold {
+	t.Helper()
+	hold, err := pt.tryHoldSeat(seatNumber)
+	if err != nil {
+		t.Fatalf("holding seat %d: %v", seatNumber, err)
+	}
+	return hold
+}
+
+// tryHoldSeat is holdSeat for holds the test expects to be refused
+func (pt *paymentTest) tryHoldSeat(seatNumber int64) (database.SeatHold, error) {
+	return pt.appState.DB.CreateSeatHold(context.Background(), database.CreateSeatHoldParams{
+		ID:              uuid.New().String(),
+		DepartureID:     testDepartureID,
+		UserID:          pt.userID,
+		SeatNumber:      seatNumber,
+		OriginStop:      1,
+		DestinationStop: 2,
+		FareAmount:      45000,
+		FareCurrency:    "INR",
+		ExpiresAt:       time.Now().UTC().Add(10 * time.Minute),
+		PassengerName:   sql.NullString{String: "Asha Rao", Valid: true},
+		PassengerAge:    sql.NullInt64{Int64: 34, Valid: true},
+		PassengerGender: sql.NullString{String: "female", Valid: true},
+		Leg:             1,
+		Legs:            1,
+		Passenger:       1,
+		Passengers:      1,
+		Quota:           string(quota.General),
+		SalesClock:      database.SalesClock(time.Now(), time.UTC),
+	})
+}
+
+// confirm posts the confirm booking form for a hold
+func (pt *paymentTest) confirm(holdID, card string) *httptest.ResponseRecorder {
+	form := url.Values{"hold_id": {holdID}, "payment_source": {card}}
+	r := httptest.NewRequest(http.MethodPost, "/book/confirm", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(pt.cookie)
+	w := httptest.NewRecorder()
+	HandleConfirmBooking(pt.appState, w, r)
+	return w
+}
+
+func (pt *paymentTest) count(t *testing.T, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := pt.db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func (pt *paymentTest) holdPayment(t *testing.T, holdID string) database.Payment {
+	t.Helper()
+	var id string
+	if err := pt.db.QueryRow("SELECT id FROM payments WHERE hold_id = ?", holdID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pt.appState.DB.GetPayment(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestConfirmBookingCaptureIssuesTicket(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+
+	w := pt.confirm(hold.ID, "4242424242424242")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/tickets" {
+		t.Fatalf("got %d to %q, want a redirect to /tickets", w.Code, w.Header().Get("Location"))
+	}
+
+	p := pt.holdPayment(t, hold.ID)
+	if p.Status != string(payment.Captured) || !p.TicketID.Valid || !p.BookingID.Valid {
+		t.Fatalf("payment = %+v, want captured with its ticket and booking", p)
+	}
+	ticket, err := pt.appState.DB.GetTicket(context.Background(), p.TicketID.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Status != "confirmed" || ticket.SeatNumber != 1 || ticket.UserID != pt.userID {
+		t.Errorf("ticket = %+v, want seat 1 confirmed for the user", ticket)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM seat_holds"); n != 0 {
+		t.Errorf("%d seat holds left after capture, want 0", n)
+	}
+}
+
+func TestConfirmBookingDeclineReleasesHold(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+
+	w := pt.confirm(hold.ID, payment.FakeCardDeclined)
+	if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/payments/status") {
+		t.Fatalf("got %d to %q, want a redirect to the payment status", w.Code, w.Header().Get("Location"))
+	}
+
+	p := pt.holdPayment(t, hold.ID)
+	if p.Status != string(payment.Failed) || p.FailureReason != "card declined" {
+		t.Errorf("payment = %+v, want failed as declined", p)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM seat_holds WHERE id = ?", hold.ID); n != 0 {
+		t.Error("seat hold kept after the payment was declined")
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM tickets"); n != 0 {
+		t.Errorf("%d tickets issued for a declined payment", n)
+	}
+
+	// The released seat can be held again straight away
+	pt.holdSeat(t, 1)
+}
+
+func TestWebhookCaptureAfterHoldExpiredRefunds(t *testing.T) {
+	pt := newTestApp(t)
+	ctx := context.Background()
+	hold := pt.holdSeat(t, 1)
+
+	p, err := pt.appState.DB.CreatePayment(ctx, database.CreatePaymentParams{
+		ID:       uuid.New().String(),
+		UserID:   pt.userID,
+		HoldID:   hold.ID,
+		Provider: pt.fake.Name(),
+		Amount:   hold.FareAmount,
+		Currency: hold.FareCurrency,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The gateway took the money, but only reports it once the hold has lapsed
+	res, err := pt.fake.Authorize(ctx, payment.Request{PaymentID: p.ID, Amount: p.Amount, Currency: p.Currency, Source: "4242424242424242"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.fake.Capture(ctx, res.Ref, p.Amount); err != nil {
+		t.Fatal(err)
+	}
+	if err := pt.appState.DB.SetPaymentRef(ctx, database.SetPaymentRefParams{ID: p.ID, ProviderRef: sql.NullString{String: res.Ref, Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.db.Exec("UPDATE seat_holds SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), hold.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	body, signature, err := pt.fake.Webhook(payment.Event{PaymentID: p.ID, Ref: res.Ref, Status: payment.Captured})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	r.Header.Set(payment.SignatureHeader, signature)
+	w := httptest.NewRecorder()
+	HandlePaymentWebhook(pt.appState, w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("webhook got %d, want 200", w.Code)
+	}
+
+	refunded, err := pt.appState.DB.GetPayment(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Status != string(payment.Refunded) || refunded.RefundedAmount != p.Amount || refunded.RefundDue != 0 {
+		t.Errorf("payment = %+v, want fully refunded", refunded)
+	}
+	if n := pt.fake.Refunded(res.Ref); n != p.Amount {
+		t.Errorf("provider refunded %d, want %d", n, p.Amount)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM tickets"); n != 0 {
+		t.Errorf("%d tickets issued for an expired hold", n)
+	}
+}
+
+func TestQueuedRefundClaimedOnce(t *testing.T) {
+	pt := newTestApp(t)
+	ctx := context.Background()
+	hold := pt.holdSeat(t, 1)
+	pt.confirm(hold.ID, "4242424242424242")
+	p := pt.holdPayment(t, hold.ID)
+
+	if err := queueRefund(ctx, pt.appState.DB, p, 100); err != nil {
+		t.Fatal(err)
+	}
+	// A node claims the refund and stops before asking the provider
+	if _, err := pt.appState.DB.ClaimRefund(ctx, database.ClaimRefundParams{ID: p.ID, LeaseSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	if made, err := jobs.MakeRefund(ctx, pt.appState.DB, pt.fake, p.ID); made || err != nil {
+		t.Fatalf("refund claimed by another node made again: %v, %v", made, err)
+	}
+
+	if _, err := pt.db.Exec("UPDATE payments SET refund_claimed_at = datetime('now', '-1 hour') WHERE id = ?", p.ID); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := jobs.MakeRefund(ctx, pt.appState.DB, pt.fake, p.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if refunded := pt.fake.Refunded(p.ProviderRef.String); refunded != 100 {
+		t.Errorf("provider refunded %d, want the lapsed claim made once", refunded)
+	}
+	p, err := pt.appState.DB.GetPayment(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RefundedAmount != 100 || p.RefundClaimed != 0 || p.RefundDue != 0 {
+		t.Errorf("payment = %+v, want 100 refunded and nothing left queued", p)
+	}
+
+	if err := queueRefund(ctx, pt.appState.DB, p, p.Amount); !errors.Is(err, errRefundTooLarge) {
+		t.Errorf("queueing more than is left = %v, want errRefundTooLarge", err)
+	}
+}
+
+// fillDeparture books every seat on the test departure except the given one
+func (pt *paymentTest) fillDeparture(t *testing.T, freeSeat int64) {
+	t.Helper()
+	_, err := pt.db.Exec(`INSERT INTO tickets (id, departure_id, user_id, seat_number, origin_stop, destination_stop, fare_amount, fare_currency, status)
+		SELECT lower(hex(randomblob(16))), ?, ?, seat_number, 1, 4, 45000, 'INR', 'confirmed'
+		FROM seats WHERE train_id = (SELECT train_id FROM departures WHERE id = ?) AND retired = 0 AND seat_number != ?`,
+		testDepartureID, pt.userID, testDepartureID, freeSeat)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (pt *paymentTest) joinWaitlist(t *testing.T) database.WaitlistEntry {
+	t.Helper()
+	entry, err := pt.appState.DB.JoinWaitlist(context.Background(), database.JoinWaitlistParams{
+		ID:              uuid.New().String(),
+		DepartureID:     testDepartureID,
+		UserID:          pt.userID,
+		OriginStop:      1,
+		DestinationStop: 2,
+		PassengerName:   sql.NullString{String: "Asha Rao", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("joining the waitlist: %v", err)
+	}
+	return entry
+}
+
+// offeredHold is the seat hold offered to a waitlist entry, if any
+func (pt *paymentTest) offeredHold(t *testing.T, entryID string) (string, bool) {
+	t.Helper()
+	var holdID string
+	err := pt.db.QueryRow("SELECT hold_id FROM waitlist_entries WHERE id = ? AND status = 'promoted'", entryID).Scan(&holdID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return holdID, true
+}
+
+func TestWaitlistOfferBecomesTicketOnlyWhenPaid(t *testing.T) {
+	pt := newTestApp(t)
+	ctx := context.Background()
+
+	// With every seat but one booked, the waitlist is open
+	pt.fillDeparture(t, 0)
+	entry := pt.joinWaitlist(t)
+	if _, err := pt.db.Exec("UPDATE tickets SET status = 'cancelled' WHERE departure_id = ? AND seat_number = 1", testDepartureID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := pt.appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		return promoteWaitlist(ctx, pt.appState, q, testDepartureID, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holdID, ok := pt.offeredHold(t, entry.ID)
+	if !ok {
+		t.Fatal("waitlist entry not offered a hold")
+	}
+	if p := pt.holdPayment(t, holdID); p.Status != string(payment.Pending) {
+		t.Errorf("offer payment = %+v, want pending", p)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM tickets WHERE status = 'confirmed' AND seat_number = 1"); n != 0 {
+		t.Fatal("ticket issued before the offer was paid for")
+	}
+
+	if w := pt.confirm(holdID, "4242424242424242"); w.Header().Get("Location") != "/tickets" {
+		t.Fatalf("confirming the offer got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM payments WHERE hold_id = ?", holdID); n != 1 {
+		t.Errorf("%d payments for the offer, want its own pending one reused", n)
+	}
+	var channel string
+	err = pt.db.QueryRow(`SELECT b.channel FROM waitlist_entries w
+		JOIN tickets tk ON tk.id = w.ticket_id
+		JOIN bookings b ON b.id = tk.booking_id
+		WHERE w.id = ? AND tk.status = 'confirmed'`, entry.ID).Scan(&channel)
+	if err != nil {
+		t.Fatalf("waitlist entry not linked to its ticket: %v", err)
+	}
+	if channel != channelWaitlist {
+		t.Errorf("booking channel = %q, want %q", channel, channelWaitlist)
+	}
+}
+
+func TestReleasedHoldOffersSeatToWaitlist(t *testing.T) {
+	pt := newTestApp(t)
+	pt.fillDeparture(t, 1)
+	hold := pt.holdSeat(t, 1)
+	entry := pt.joinWaitlist(t)
+
+	form := url.Values{"hold_id": {hold.ID}}
+	r := httptest.NewRequest(http.MethodPost, "/book/release", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(pt.cookie)
+	HandleReleaseHold(pt.appState, httptest.NewRecorder(), r)
+
+	holdID, ok := pt.offeredHold(t, entry.ID)
+	if !ok {
+		t.Fatal("released seat not offered to the waitlist")
+	}
+	if holdID == hold.ID {
+		t.Error("waitlist offered the released hold itself")
+	}
+}
+
+func TestLapsedOfferFailsItsPayment(t *testing.T) {
+	// offer puts the test user's waitlist entry on seat 1 with a pending payment
+	offer := func(t *testing.T) (*paymentTest, string) {
+		pt := newTestApp(t)
+		ctx := context.Background()
+		pt.fillDeparture(t, 0)
+		entry := pt.joinWaitlist(t)
+		if _, err := pt.db.Exec("UPDATE tickets SET status = 'cancelled' WHERE departure_id = ? AND seat_number = 1", testDepartureID); err != nil {
+			t.Fatal(err)
+		}
+		err := pt.appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+			return promoteWaitlist(ctx, pt.appState, q, testDepartureID, 1)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		holdID, ok := pt.offeredHold(t, entry.ID)
+		if !ok {
+			t.Fatal("waitlist entry not offered a hold")
+		}
+		return pt, holdID
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		pt, holdID := offer(t)
+		if _, err := pt.db.Exec("UPDATE seat_holds SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), holdID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReleaseExpiredHolds(context.Background(), pt.appState); err != nil {
+			t.Fatal(err)
+		}
+		if p := pt.holdPayment(t, holdID); p.Status != string(payment.Failed) || p.FailureReason != "seat hold expired" {
+			t.Errorf("lapsed offer payment is %s (%q), want failed", p.Status, p.FailureReason)
+		}
+	})
+
+	t.Run("released", func(t *testing.T) {
+		pt, holdID := offer(t)
+		form := url.Values{"hold_id": {holdID}}
+		r := httptest.NewRequest(http.MethodPost, "/book/release", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(pt.cookie)
+		HandleReleaseHold(pt.appState, httptest.NewRecorder(), r)
+
+		if p := pt.holdPayment(t, holdID); p.Status != string(payment.Failed) || p.FailureReason != "seat hold released" {
+			t.Errorf("released offer payment is %s (%q), want failed", p.Status, p.FailureReason)
+		}
+	})
+}
+
+func TestExpiredHoldOffersSeatOnlyBeforeDeparture(t *testing.T) {
+	pt := newTestApp(t)
+	pt.fillDeparture(t, 1)
+	hold := pt.holdSeat(t, 1)
+	entry := pt.joinWaitlist(t)
+
+	// The train left while the seat was held
+	if _, err := pt.db.Exec("UPDATE seat_holds SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), hold.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.db.Exec("UPDATE departures SET departs_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Hour), testDepartureID); err != nil {
+		t.Fatal(err)
+	}
+
+	released, err := ReleaseExpiredHolds(context.Background(), pt.appState)
+	if err != nil || released != 1 {
+		t.Fatalf("released %d holds (%v), want 1", released, err)
+	}
+	if _, ok := pt.offeredHold(t, entry.ID); ok {
+		t.Error("seat on a departed train offered to the waitlist")
+	}
+}
+
+func TestConfirmBookingRetryIsReplayed(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+	confirm := Idempotent("confirm", HandleConfirmBooking)
+
+	post := func() *httptest.ResponseRecorder {
+		form := url.Values{"hold_id": {hold.ID}, "payment_source": {"4242424242424242"}, idempotencyField: {"confirm-key"}}
+		r := httptest.NewRequest(http.MethodPost, "/book/confirm", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(pt.cookie)
+		w := httptest.NewRecorder()
+		confirm(pt.appState, w, r)
+		return w
+	}
+
+	first := post()
+	retry := post()
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Fatalf("retry got %d to %q, want the first response replayed", retry.Code, retry.Header().Get("Location"))
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM payments"); n != 1 {
+		t.Errorf("%d payments after a retried confirm, want 1", n)
+	}
+
+	// The key was leased while the payment ran, and is kept for the window once answered
+	var expiresAt time.Time
+	if err := pt.db.QueryRow("SELECT expires_at FROM idempotency_keys WHERE idempotency_key = 'confirm-key'").Scan(&expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expiresAt) <= idempotencyLease {
+		t.Errorf("answered key expires at %s, want it kept for the idempotency window", expiresAt)
+	}
+}
+
+func TestBusyConfirmReleasesIdempotencyKey(t *testing.T) {
+	pt := newTestApp(t)
+	hold := pt.holdSeat(t, 1)
+	confirm := Idempotent("confirm", HandleConfirmBooking)
+
+	post := func() *httptest.ResponseRecorder {
+		form := url.Values{"hold_id": {hold.ID}, "payment_source": {"4242424242424242"}, idempotencyField: {"busy-key"}}
+		r := httptest.NewRequest(http.MethodPost, "/book/confirm", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(pt.cookie)
+		w := httptest.NewRecorder()
+		confirm(pt.appState, w, r)
+		return w
+	}
+
+	// Another node holds the critical section
+	pt.appState.Node.AnyCS = true
+	busy := post()
+	if busy.Code != http.StatusServiceUnavailable {
+		t.Fatalf("busy confirm got %d, want 503", busy.Code)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM idempotency_keys WHERE idempotency_key = 'busy-key'"); n != 0 {
+		t.Fatal("key kept after a busy refusal, so the retry would replay it")
+	}
+
+	pt.appState.Node.AnyCS = false
+	retry := post()
+	if retry.Code != http.StatusSeeOther || retry.Header().Get("Location") != "/tickets" || retry.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry got %d to %q, want the booking made", retry.Code, retry.Header().Get("Location"))
+	}
+	if again := post(); again.Header().Get("Idempotent-Replayed") != "true" || again.Code != retry.Code {
+		t.Errorf("second retry got %d, want the booking replayed", again.Code)
+	}
+	if n := pt.count(t, "SELECT COUNT(*) FROM payments"); n != 1 {
+		t.Errorf("%d payments, want 1", n)
+	}
+}
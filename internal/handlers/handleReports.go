@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/reports"
+	"sort"
+	"time"
+)
+
+// reportSpec is one of the admin reports: how to build it for a range of dates on the
+// operator's calendar in loc
+type reportSpec struct {
+	Name  string
+	Title string
+	build func(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error)
+}
+
+// reportSpecs lists the reports in the order the index page shows them. Amounts are in
+// minor units of their currency.
+var reportSpecs = []reportSpec{
+	{"load", "Load factor per departure", departureLoadReport},
+	{"routes", "Load factor per route", routeLoadReport},
+	{"cancellations", "Cancellations and refunds", cancellationsReport},
+	{"channels", "Bookings per channel", channelsReport},
+	{"peak-hours", "Bookings by local hour of day", peakHoursReport},
+}
+
+// ReportNames lists the reports HandleReport serves
+func ReportNames() []string {
+	names := make([]string, len(reportSpecs))
+	for i, spec := range reportSpecs {
+		names[i] = spec.Name
+	}
+	return names
+}
+
+// HandleReports is the admin index of reports
+func HandleReports(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	from, to, err := reports.ParseRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now().In(appState.Location))
+	if err != nil {
+		from, to, _ = reports.ParseRange("", "", time.Now().In(appState.Location))
+	}
+	err = appState.Templates.ExecuteTemplate(w, "reports.html", map[string]interface{}{
+		"Reports": reportSpecs,
+		"From":    from,
+		"To":      to,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// HandleReport serves the named report for the dates from and to in the query string,
+// as a page, or as a download with format=csv or format=json
+func HandleReport(name string) func(*app.AppState, http.ResponseWriter, *http.Request) {
+	var spec reportSpec
+	for _, s := range reportSpecs {
+		if s.Name == name {
+			spec = s
+		}
+	}
+	return func(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+		if spec.build == nil {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		format := query.Get("format")
+		from, to, err := reports.ParseRange(query.Get("from"), query.Get("to"), time.Now().In(appState.Location))
+		if err != nil {
+			if format == "json" {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+				return
+			}
+			http.Error(w, displayText(err.Error()), http.StatusUnprocessableEntity)
+			return
+		}
+
+		table, err := spec.build(r.Context(), appState.DB, appState.Location, from, to)
+		if err != nil {
+			log.Printf("Error building %s report: %v", spec.Name, err)
+			http.Error(w, "Failed to build report", http.StatusInternalServerError)
+			return
+		}
+		table.Name = spec.Name
+		table.Title = spec.Title
+		filename := spec.Name + "-" + from + "-" + to
+
+		switch format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+			if err := table.WriteCSV(w); err != nil {
+				log.Println("Error writing CSV:", err)
+			}
+		case "json":
+			w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"report": table.Name,
+				"from":   from,
+				"to":     to,
+				"rows":   table.Records(),
+			})
+		default:
+			err = appState.Templates.ExecuteTemplate(w, "report.html", map[string]interface{}{
+				"Report": table,
+				"From":   from,
+				"To":     to,
+			})
+			if err != nil {
+				log.Println("Error rendering template:", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}
+	}
+}
+
+func departureLoadReport(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error) {
+	rows, err := db.ReportDepartureLoad(ctx, database.ReportDepartureLoadParams{From: from, To: to})
+	if err != nil {
+		return reports.Table{}, err
+	}
+	table := reports.Table{Columns: []string{
+		"departure_id", "train", "service_date", "departs_at", "origin", "destination",
+		"seats", "tickets_sold", "seat_km", "passenger_km", "load_factor_pct",
+	}}
+	for _, row := range rows {
+		var load reports.Load
+		load.Add(row.TotalSeats, row.RouteKm, row.TicketsSold, row.PassengerKm)
+		table.Rows = append(table.Rows, []interface{}{
+			row.ID, row.Name, row.ServiceDate, row.DepartsAt.In(loc).Format(time.RFC3339),
+			row.OriginName, row.DestinationName,
+			row.TotalSeats, row.TicketsSold, load.SeatKm, row.PassengerKm, load.Factor(),
+		})
+	}
+	return table, nil
+}
+
+// routeLoadReport adds up the departures of each train, which runs a single route
+func routeLoadReport(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error) {
+	rows, err := db.ReportDepartureLoad(ctx, database.ReportDepartureLoadParams{From: from, To: to})
+	if err != nil {
+		return reports.Table{}, err
+	}
+	loads := make(map[string]*reports.Load)
+	routes := make(map[string]database.ReportDepartureLoadRow)
+	for _, row := range rows {
+		if loads[row.TrainID] == nil {
+			loads[row.TrainID] = &reports.Load{}
+			routes[row.TrainID] = row
+		}
+		loads[row.TrainID].Add(row.TotalSeats, row.RouteKm, row.TicketsSold, row.PassengerKm)
+	}
+	trainIDs := make([]string, 0, len(routes))
+	for id := range routes {
+		trainIDs = append(trainIDs, id)
+	}
+	sort.Slice(trainIDs, func(i, j int) bool {
+		a, b := routes[trainIDs[i]], routes[trainIDs[j]]
+		if a.OriginName+a.DestinationName != b.OriginName+b.DestinationName {
+			return a.OriginName+a.DestinationName < b.OriginName+b.DestinationName
+		}
+		return a.Name < b.Name
+	})
+
+	table := reports.Table{Columns: []string{
+		"train", "origin", "destination", "route_km", "departures",
+		"seats", "tickets_sold", "seat_km", "passenger_km", "load_factor_pct",
+	}}
+	for _, id := range trainIDs {
+		route, load := routes[id], loads[id]
+		table.Rows = append(table.Rows, []interface{}{
+			route.Name, route.OriginName, route.DestinationName, route.RouteKm, load.Departures,
+			load.Seats, load.TicketsSold, load.SeatKm, load.PassengerKm, load.Factor(),
+		})
+	}
+	return table, nil
+}
+
+// cancellationsReport totals cancellations by the operator's local day
+func cancellationsReport(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error) {
+	start, end, err := reports.Span(from, to, loc)
+	if err != nil {
+		return reports.Table{}, err
+	}
+	rows, err := db.ReportCancellations(ctx, database.ReportCancellationsParams{From: start, To: end})
+	if err != nil {
+		return reports.Table{}, err
+	}
+	type dayKey struct{ day, channel, currency string }
+	totals := make(map[dayKey]*database.ReportCancellationsRow)
+	var keys []dayKey
+	for _, row := range rows {
+		at, err := reports.LocalMinute(row.Minute, loc)
+		if err != nil {
+			return reports.Table{}, err
+		}
+		key := dayKey{at.Format("2006-01-02"), row.Channel, row.Currency}
+		total := totals[key]
+		if total == nil {
+			total = &database.ReportCancellationsRow{Channel: row.Channel, Currency: row.Currency}
+			totals[key] = total
+			keys = append(keys, key)
+		}
+		total.Cancellations += row.Cancellations
+		total.FareAmount += row.FareAmount
+		total.FeeAmount += row.FeeAmount
+		total.RefundAmount += row.RefundAmount
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.day != b.day {
+			return a.day < b.day
+		}
+		if a.channel != b.channel {
+			return a.channel < b.channel
+		}
+		return a.currency < b.currency
+	})
+
+	table := reports.Table{Columns: []string{
+		"day", "channel", "currency", "cancellations", "fare_amount", "fee_amount", "refund_amount",
+	}}
+	for _, key := range keys {
+		total := totals[key]
+		table.Rows = append(table.Rows, []interface{}{
+			key.day, total.Channel, total.Currency, total.Cancellations, total.FareAmount, total.FeeAmount, total.RefundAmount,
+		})
+	}
+	return table, nil
+}
+
+func channelsReport(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error) {
+	start, end, err := reports.Span(from, to, loc)
+	if err != nil {
+		return reports.Table{}, err
+	}
+	rows, err := db.ReportBookingChannels(ctx, database.ReportBookingChannelsParams{From: start, To: end})
+	if err != nil {
+		return reports.Table{}, err
+	}
+	table := reports.Table{Columns: []string{"channel", "currency", "bookings", "tickets", "fare_amount"}}
+	for _, row := range rows {
+		table.Rows = append(table.Rows, []interface{}{
+			row.Channel, row.FareCurrency, row.Bookings, row.Tickets, row.FareAmount,
+		})
+	}
+	return table, nil
+}
+
+// peakHoursReport has a row for every hour of the operator's day, including hours with
+// no bookings
+func peakHoursReport(ctx context.Context, db database.QueriesInterface, loc *time.Location, from, to string) (reports.Table, error) {
+	start, end, err := reports.Span(from, to, loc)
+	if err != nil {
+		return reports.Table{}, err
+	}
+	rows, err := db.ReportBookingHours(ctx, database.ReportBookingHoursParams{From: start, To: end})
+	if err != nil {
+		return reports.Table{}, err
+	}
+	var bookings, tickets [24]int64
+	for _, row := range rows {
+		at, err := reports.LocalMinute(row.Minute, loc)
+		if err != nil {
+			return reports.Table{}, err
+		}
+		bookings[at.Hour()] += row.Bookings
+		tickets[at.Hour()] += row.Tickets
+	}
+	table := reports.Table{Columns: []string{"hour", "bookings", "tickets"}}
+	for hour := range bookings {
+		table.Rows = append(table.Rows, []interface{}{int64(hour), bookings[hour], tickets[hour]})
+	}
+	return table, nil
+}
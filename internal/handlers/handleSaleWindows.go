@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"time"
+)
+
+// saleWindow is a booking window as the admin API sends and returns it
+type saleWindow struct {
+	TrainID         string `json:"train_id"`
+	Class           string `json:"class"`
+	OpensDaysBefore int64  `json:"opens_days_before"`
+	OpensTime       string `json:"opens_time"`
+}
+
+// HandleSaleWindows lists booking windows (GET), sets one for a train or one of its
+// classes (POST), or removes one so the train is always on sale (DELETE, by train_id
+// and class in the query string)
+func HandleSaleWindows(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := appState.DB.ListSaleWindows(r.Context())
+		if err != nil {
+			log.Println("Error fetching sale windows:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch sale windows"})
+			return
+		}
+		windows := []saleWindow{}
+		for _, row := range rows {
+			windows = append(windows, saleWindow(row))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"sale_windows": windows})
+
+	case http.MethodPost:
+		var req saleWindow
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		if req.OpensTime == "" {
+			req.OpensTime = "00:00"
+		}
+		opens, err := time.Parse("15:04", req.OpensTime)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "opens_time must be HH:MM"})
+			return
+		}
+		// The embargo is worked out in SQL, which only reads two-digit hours
+		req.OpensTime = opens.Format("15:04")
+		if req.TrainID == "" || req.OpensDaysBefore < 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "train_id and a non-negative opens_days_before are required"})
+			return
+		}
+
+		row, err := appState.DB.UpsertSaleWindow(r.Context(), database.UpsertSaleWindowParams(req))
+		if err != nil {
+			// Most likely a train that doesn't exist
+			log.Println("Error saving sale window:", err)
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "failed to save sale window"})
+			return
+		}
+		writeJSON(w, http.StatusOK, saleWindow(row))
+
+	case http.MethodDelete:
+		err := appState.DB.DeleteSaleWindow(r.Context(), database.DeleteSaleWindowParams{
+			TrainID: r.URL.Query().Get("train_id"),
+			Class:   r.URL.Query().Get("class"),
+		})
+		if err != nil {
+			log.Println("Error deleting sale window:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete sale window"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSaleWindowWithOneDigitHourEmbargoesSales(t *testing.T) {
+	pt := newTestApp(t)
+	var trainID string
+	if err := pt.db.QueryRow("SELECT train_id FROM departures WHERE id = ?", testDepartureID).Scan(&trainID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The departure is two days out, so a window opening the day before at 9:05 is
+	// still shut
+	body := `{"train_id": "` + trainID + `", "opens_days_before": 1, "opens_time": "9:05"}`
+	w := httptest.NewRecorder()
+	HandleSaleWindows(pt.appState, w, httptest.NewRequest(http.MethodPost, "/admin/sale-windows", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("saving sale window got %d: %s", w.Code, w.Body)
+	}
+	var saved saleWindow
+	if err := json.NewDecoder(w.Body).Decode(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.OpensTime != "09:05" {
+		t.Errorf("opens_time stored as %q, want 09:05", saved.OpensTime)
+	}
+
+	if _, err := pt.tryHoldSeat(1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("seat held before its sale window opened: %v", err)
+	}
+}
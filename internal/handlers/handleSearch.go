@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxSearchPassengers is the largest party a search can be for
+const maxSearchPassengers = 9
+
+// maxTransferWait is the longest a connection waits at the station it changes trains at
+const maxTransferWait = 6 * time.Hour
+
+// maxConnections caps how many connecting itineraries a search returns
+const maxConnections = 20
+
+// searchTimeLayout is how the bounds of a search day are passed to the query
+const searchTimeLayout = "2006-01-02 15:04:05"
+
+// searchSorts are the orders search results can be sorted in
+var searchSorts = []string{"departure", "duration", "price"}
+
+// searchQuery is a parsed journey search
+type searchQuery struct {
+	Origin      string
+	Destination string
+	Date        string
+	Passengers  int
+	Sort        string
+}
+
+// searchResult is a class on a departure that can seat the whole party
+type searchResult struct {
+	DepartureID     string    `json:"departure_id"`
+	Train           string    `json:"train"`
+	ServiceDate     string    `json:"service_date"`
+	Class           string    `json:"class"`
+	Quota           string    `json:"quota"`
+	DepartsAt       time.Time `json:"departs_at"`
+	ArrivesAt       time.Time `json:"arrives_at"`
+	DurationMinutes int64     `json:"duration_minutes"`
+	AvailableSeats  int64     `json:"available_seats"`
+	Fare            int64     `json:"fare"`
+	TotalFare       int64     `json:"total_fare"`
+	Currency        string    `json:"currency"`
+	OnSale          bool      `json:"on_sale"`
+	// SalesOpenAt is when a departure not yet on sale can be booked
+	SalesOpenAt *time.Time `json:"sales_open_at,omitempty"`
+	OpensIn     string     `json:"-"`
+}
+
+// connectionResult is an itinerary that changes trains once, at the Via station, with
+// both legs in the same class
+type connectionResult struct {
+	Legs            []searchResult `json:"legs"`
+	Via             string         `json:"via"`
+	ViaStationID    string         `json:"via_station_id"`
+	TransferMinutes int64          `json:"transfer_minutes"`
+	Class           string         `json:"class"`
+	DepartsAt       time.Time      `json:"departs_at"`
+	ArrivesAt       time.Time      `json:"arrives_at"`
+	DurationMinutes int64          `json:"duration_minutes"`
+	Fare            int64          `json:"fare"`
+	TotalFare       int64          `json:"total_fare"`
+	Currency        string         `json:"currency"`
+}
+
+// parseSearch reads and validates a search from the query string. The date defaults
+// to today in the operator's time zone loc and the party to one passenger.
+func parseSearch(r *http.Request, loc *time.Location) (searchQuery, error) {
+	query := r.URL.Query()
+	s := searchQuery{
+		Origin:      query.Get("origin"),
+		Destination: query.Get("destination"),
+		Date:        query.Get("date"),
+		Passengers:  1,
+		Sort:        query.Get("sort"),
+	}
+	if s.Origin == "" || s.Destination == "" {
+		return s, errors.New("origin and destination are required")
+	}
+	if s.Origin == s.Destination {
+		return s, errors.New("origin and destination must differ")
+	}
+
+	if s.Date == "" {
+		s.Date = time.Now().In(loc).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", s.Date); err != nil {
+		return s, errors.New("date must be in YYYY-MM-DD format")
+	}
+
+	if p := query.Get("passengers"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > maxSearchPassengers {
+			return s, errors.New("passengers must be between 1 and " + strconv.Itoa(maxSearchPassengers))
+		}
+		s.Passengers = n
+	}
+
+	if s.Sort == "" {
+		s.Sort = "departure"
+	}
+	valid := false
+	for _, option := range searchSorts {
+		if option == s.Sort {
+			valid = true
+		}
+	}
+	if !valid {
+		return s, errors.New("sort must be departure, duration or price")
+	}
+	return s, nil
+}
+
+// searchLeg finds the departures between two stations on a date of the operator's
+// calendar with seats for the whole party, priced at the current fare, in departure
+// order
+func searchLeg(ctx context.Context, appState *app.AppState, origin, destination, date string, passengers int) ([]searchResult, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, appState.Location)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := appState.DB.GetAvailableTickets(ctx, database.GetAvailableTicketsParams{
+		OriginStationID:      origin,
+		DestinationStationID: destination,
+		DepartsFrom:          day.UTC().Format(searchTimeLayout),
+		DepartsBefore:        day.AddDate(0, 0, 1).UTC().Format(searchTimeLayout),
+		Passengers:           int64(passengers),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]searchResult, 0, len(rows))
+	for _, option := range quoteDepartures(appState.Fares, appState.Location, rows) {
+		// Shown and returned on the operator's clock, like the service date
+		departs, arrives := option.OriginDepartsAt().In(appState.Location), option.DestinationArrivesAt().In(appState.Location)
+		result := searchResult{
+			DepartureID:     option.ID,
+			Train:           option.Name,
+			ServiceDate:     option.ServiceDate,
+			Class:           option.Class,
+			Quota:           option.Quota,
+			DepartsAt:       departs,
+			ArrivesAt:       arrives,
+			DurationMinutes: int64(arrives.Sub(departs) / time.Minute),
+			AvailableSeats:  option.AvailableSeats,
+			Fare:            option.Fare.Total,
+			TotalFare:       option.Fare.Total * int64(passengers),
+			Currency:        option.Fare.Currency,
+			OnSale:          option.OnSale(),
+		}
+		if !result.OnSale {
+			opens := option.SalesOpen()
+			result.SalesOpenAt = &opens
+			result.OpensIn = option.OpensIn()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// searchJourneys finds the direct departures for a search
+func searchJourneys(ctx context.Context, appState *app.AppState, s searchQuery) ([]searchResult, error) {
+	results, err := searchLeg(ctx, appState, s.Origin, s.Destination, s.Date, s.Passengers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ties keep the query's order, which is by departure time
+	sort.SliceStable(results, func(i, j int) bool {
+		switch s.Sort {
+		case "duration":
+			return results[i].DurationMinutes < results[j].DurationMinutes
+		case "price":
+			return results[i].TotalFare < results[j].TotalFare
+		}
+		return results[i].DepartsAt.Before(results[j].DepartsAt)
+	})
+	return results, nil
+}
+
+// connectLegs pairs first legs into a station with second legs out of it. The second
+// train must leave at least minTransfer after the first arrives, and no more than
+// maxTransferWait after, in the same class on a different train. Both legs must be on
+// sale, as they are booked together, and in the general quota.
+func connectLegs(via database.Station, first, second []searchResult, passengers int) []connectionResult {
+	minTransfer := time.Duration(via.MinTransferMinutes) * time.Minute
+	var connections []connectionResult
+	for _, a := range first {
+		for _, b := range second {
+			wait := b.DepartsAt.Sub(a.ArrivesAt)
+			if !a.OnSale || !b.OnSale || a.Quota != string(quota.General) || b.Quota != string(quota.General) || a.Class != b.Class || a.DepartureID == b.DepartureID || a.Currency != b.Currency || wait < minTransfer || wait > maxTransferWait {
+				continue
+			}
+			connections = append(connections, connectionResult{
+				Legs:            []searchResult{a, b},
+				Via:             via.Name,
+				ViaStationID:    via.ID,
+				TransferMinutes: int64(wait / time.Minute),
+				Class:           a.Class,
+				DepartsAt:       a.DepartsAt,
+				ArrivesAt:       b.ArrivesAt,
+				DurationMinutes: int64(b.ArrivesAt.Sub(a.DepartsAt) / time.Minute),
+				Fare:            a.Fare + b.Fare,
+				TotalFare:       (a.Fare + b.Fare) * int64(passengers),
+				Currency:        a.Currency,
+			})
+		}
+	}
+	return connections
+}
+
+// searchConnections finds itineraries that change trains once for a search. The first
+// leg leaves on the search date; the second may leave the next day after an evening
+// arrival.
+func searchConnections(ctx context.Context, appState *app.AppState, s searchQuery) ([]connectionResult, error) {
+	stations, err := appState.DB.ListTransferStations(ctx, database.ListTransferStationsParams{
+		OriginStationID:      s.Origin,
+		DestinationStationID: s.Destination,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse("2006-01-02", s.Date)
+	if err != nil {
+		return nil, err
+	}
+	nextDate := date.AddDate(0, 0, 1).Format("2006-01-02")
+
+	connections := []connectionResult{}
+	for _, via := range stations {
+		first, err := searchLeg(ctx, appState, s.Origin, via.ID, s.Date, s.Passengers)
+		if err != nil {
+			return nil, err
+		}
+		if len(first) == 0 {
+			continue
+		}
+
+		second, err := searchLeg(ctx, appState, via.ID, s.Destination, s.Date, s.Passengers)
+		if err != nil {
+			return nil, err
+		}
+		later, err := searchLeg(ctx, appState, via.ID, s.Destination, nextDate, s.Passengers)
+		if err != nil {
+			return nil, err
+		}
+		connections = append(connections, connectLegs(via, first, append(second, later...), s.Passengers)...)
+	}
+
+	sort.SliceStable(connections, func(i, j int) bool {
+		switch s.Sort {
+		case "duration":
+			if connections[i].DurationMinutes != connections[j].DurationMinutes {
+				return connections[i].DurationMinutes < connections[j].DurationMinutes
+			}
+		case "price":
+			if connections[i].TotalFare != connections[j].TotalFare {
+				return connections[i].TotalFare < connections[j].TotalFare
+			}
+		}
+		return connections[i].DepartsAt.Before(connections[j].DepartsAt)
+	})
+	if len(connections) > maxConnections {
+		connections = connections[:maxConnections]
+	}
+	return connections, nil
+}
+
+// HandleSearch shows the journey search page with results once a search is made
+func HandleSearch(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	stations, err := appState.DB.ListStations(r.Context())
+	if err != nil {
+		log.Println("Error fetching stations:", err)
+		http.Error(w, "Failed to fetch stations", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Stations": stations,
+		"Sorts":    searchSorts,
+	}
+	s, err := parseSearch(r, appState.Location)
+	data["Search"] = s
+	if r.URL.Query().Get("origin") != "" {
+		if err != nil {
+			data["Error"] = displayText(err.Error())
+		} else {
+			results, err := searchJourneys(r.Context(), appState, s)
+			if err != nil {
+				log.Println("Error searching departures:", err)
+				http.Error(w, "Failed to search departures", http.StatusInternalServerError)
+				return
+			}
+			connections, err := searchConnections(r.Context(), appState, s)
+			if err != nil {
+				log.Println("Error searching connections:", err)
+				http.Error(w, "Failed to search departures", http.StatusInternalServerError)
+				return
+			}
+			data["Results"] = results
+			data["Connections"] = connections
+			data["Searched"] = true
+		}
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "search.html", data)
+	if err != nil {
+		log.Println("Error rendering template:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// HandleSearchAPI is the JSON form of the journey search
+func HandleSearchAPI(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	s, err := parseSearch(r, appState.Location)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	results, err := searchJourneys(r.Context(), appState, s)
+	if err != nil {
+		log.Println("Error searching departures:", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "search failed"})
+		return
+	}
+
+	connections, err := searchConnections(r.Context(), appState, s)
+	if err != nil {
+		log.Println("Error searching connections:", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "search failed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"origin":      s.Origin,
+		"destination": s.Destination,
+		"date":        s.Date,
+		"passengers":  s.Passengers,
+		"sort":        s.Sort,
+		"results":     results,
+		"connections": connections,
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// routeStations is the test departure's first and second station
+func (pt *paymentTest) routeStations(t *testing.T) (origin, next string) {
+	t.Helper()
+	err := pt.db.QueryRow(`
+		SELECT a.station_id, b.station_id
+		FROM departures d
+		JOIN route_stops a ON a.train_id = d.train_id AND a.stop_sequence = 1
+		JOIN route_stops b ON b.train_id = d.train_id AND b.stop_sequence = 2
+		WHERE d.id = ?`, testDepartureID).Scan(&origin, &next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return origin, next
+}
+
+func TestSearchLegShowsOperatorTimes(t *testing.T) {
+	pt := newTestApp(t)
+	pt.appState.Location = time.FixedZone("IST", 5*60*60+30*60)
+	origin, destination := pt.routeStations(t)
+
+	var departsAt time.Time
+	if err := pt.db.QueryRow("SELECT departs_at FROM departures WHERE id = ?", testDepartureID).Scan(&departsAt); err != nil {
+		t.Fatal(err)
+	}
+	date := departsAt.In(pt.appState.Location).Format("2006-01-02")
+
+	results, err := searchLeg(context.Background(), pt.appState, origin, destination, date, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range results {
+		if r.DepartureID != testDepartureID {
+			continue
+		}
+		found = true
+		if r.DepartsAt.Location() != pt.appState.Location || !r.DepartsAt.Equal(departsAt) {
+			t.Errorf("departs at %s, want %s on the operator's clock", r.DepartsAt, departsAt.In(pt.appState.Location))
+		}
+	}
+	if !found {
+		t.Fatalf("test departure not found searching %s", date)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+
+	"github.com/google/uuid"
+)
+
+var errQuotaNotAllocated = errors.New("not enough free seats for the quota")
+
+// seatQuota is a quota as the admin API returns it
+type seatQuota struct {
+	ID                 string `json:"id"`
+	DepartureID        string `json:"departure_id"`
+	Category           string `json:"category"`
+	Class              string `json:"class"`
+	ReleaseHoursBefore int64  `json:"release_hours_before"`
+	// ReleasesAt is when unsold seats return to general sale, in UTC
+	ReleasesAt string `json:"releases_at"`
+	Seats      int64  `json:"seats"`
+	SoldSeats  int64  `json:"sold_seats"`
+}
+
+// seatQuotaRequest defines a quota: how many seats of a class on a departure to hold
+// back for a category, and when to release those unsold
+type seatQuotaRequest struct {
+	DepartureID        string `json:"departure_id"`
+	Category           string `json:"category"`
+	Class              string `json:"class"`
+	Seats              int64  `json:"seats"`
+	ReleaseHoursBefore *int64 `json:"release_hours_before"`
+}
+
+// HandleSeatQuotas lists a departure's quotas (GET, by departure_id in the query
+// string), reserves seats for a new one (POST) or removes one, returning its seats to
+// general sale (DELETE, by id in the query string)
+func HandleSeatQuotas(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := appState.DB.ListSeatQuotas(r.Context(), r.URL.Query().Get("departure_id"))
+		if err != nil {
+			log.Println("Error fetching seat quotas:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch seat quotas"})
+			return
+		}
+		quotas := []seatQuota{}
+		for _, row := range rows {
+			quotas = append(quotas, seatQuota(row))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"quotas": quotas})
+
+	case http.MethodPost:
+		var req seatQuotaRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		category, err := quota.Parse(req.Category)
+		if err != nil || category == quota.General {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "category must be senior, women, disabled or emergency"})
+			return
+		}
+		releaseHours := int64(quota.DefaultReleaseHours)
+		if req.ReleaseHoursBefore != nil {
+			releaseHours = *req.ReleaseHoursBefore
+		}
+		if req.DepartureID == "" || req.Class == "" || req.Seats < 1 || releaseHours < 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "departure_id, class, a positive number of seats and a non-negative release_hours_before are required"})
+			return
+		}
+
+		id := uuid.New().String()
+		var allocated int64
+		err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+			if _, err := q.CreateSeatQuota(r.Context(), database.CreateSeatQuotaParams{
+				ID:                 id,
+				DepartureID:        req.DepartureID,
+				Category:           string(category),
+				Class:              req.Class,
+				ReleaseHoursBefore: releaseHours,
+			}); err != nil {
+				return err
+			}
+			allocated, err = q.AllocateQuotaSeats(r.Context(), database.AllocateQuotaSeatsParams{
+				ID:    id,
+				Limit: req.Seats,
+			})
+			if err != nil {
+				return err
+			}
+			if allocated < req.Seats {
+				return errQuotaNotAllocated
+			}
+			return nil
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "no such departure, or it has no seats in that class"})
+			return
+		}
+		if errors.Is(err, errQuotaNotAllocated) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": fmt.Sprintf("only %d free seats in that class aren't already in a quota", allocated)})
+			return
+		}
+		if err != nil {
+			// Most likely the departure already has a quota for this category and class
+			log.Println("Error creating seat quota:", err)
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "failed to create seat quota"})
+			return
+		}
+		log.Printf("Reserved %d %s seats for the %s quota on departure %s", allocated, req.Class, category, req.DepartureID)
+
+		rows, err := appState.DB.ListSeatQuotas(r.Context(), req.DepartureID)
+		if err != nil {
+			log.Println("Error fetching seat quotas:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch seat quotas"})
+			return
+		}
+		for _, row := range rows {
+			if row.ID == id {
+				writeJSON(w, http.StatusCreated, seatQuota(row))
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		var deleted int64
+		err := appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+			if err := q.DeleteQuotaSeats(r.Context(), id); err != nil {
+				return err
+			}
+			var err error
+			deleted, err = q.DeleteSeatQuota(r.Context(), id)
+			return err
+		})
+		if err != nil {
+			log.Println("Error deleting seat quota:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete seat quota"})
+			return
+		}
+		if deleted == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such seat quota"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
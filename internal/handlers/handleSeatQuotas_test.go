@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+)
+
+func TestQuotaSeatsGoOnlyToTheirCategoryUntilReleased(t *testing.T) {
+	pt := newTestApp(t)
+	ctx := context.Background()
+
+	body := `{"departure_id": "` + testDepartureID + `", "category": "senior", "class": "ac_chair", "seats": 2, "release_hours_before": 24}`
+	w := httptest.NewRecorder()
+	HandleSeatQuotas(pt.appState, w, httptest.NewRequest(http.MethodPost, "/admin/quotas", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("creating quota got %d: %s", w.Code, w.Body)
+	}
+
+	reserved := map[int64]bool{}
+	rows, err := pt.db.Query("SELECT seat_number FROM quota_seats WHERE departure_id = ?", testDepartureID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		reserved[n] = true
+	}
+	rows.Close()
+	if len(reserved) != 2 {
+		t.Fatalf("%d seats reserved, want 2", len(reserved))
+	}
+	var quotaSeat int64
+	for n := range reserved {
+		quotaSeat = n
+	}
+
+	// A general booking can't take a quota seat, by picking it or by being given it
+	if _, err := pt.tryHoldSeat(quotaSeat); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("general hold on quota seat %d: %v, want it refused", quotaSeat, err)
+	}
+	stops := database.GetJourneyStopsRow{OriginStop: 1, DestinationStop: 2}
+	if _, _, err := chooseSeat(ctx, pt.appState.DB, testDepartureID, "ac_chair", stops, strconv.FormatInt(quotaSeat, 10), "", quota.General); !errors.Is(err, errSeatReserved) {
+		t.Errorf("general booking asking for quota seat %d: %v, want errSeatReserved", quotaSeat, err)
+	}
+	seat, _, err := chooseSeat(ctx, pt.appState.DB, testDepartureID, "ac_chair", stops, "", "", quota.General)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved[seat] {
+		t.Errorf("general booking given quota seat %d", seat)
+	}
+
+	// A senior booking gets one of the quota's seats
+	seat, soldUnder, err := chooseSeat(ctx, pt.appState.DB, testDepartureID, "ac_chair", stops, "", "", quota.Senior)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reserved[seat] || soldUnder != quota.Senior {
+		t.Errorf("senior booking got seat %d under %s, want a quota seat", seat, soldUnder)
+	}
+
+	// Once the quota's release time passes its seats are on general sale
+	if _, err := pt.db.Exec("UPDATE seat_quotas SET release_hours_before = 72 WHERE departure_id = ?", testDepartureID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.tryHoldSeat(quotaSeat); err != nil {
+		t.Errorf("general hold on released quota seat %d: %v", quotaSeat, err)
+	}
+}
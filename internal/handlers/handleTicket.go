@@ -2,18 +2,22 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"rsvbackend/internal/app"
 	"rsvbackend/internal/database"
-	"strconv"
+	"rsvbackend/internal/quota"
+	"rsvbackend/internal/ticketstate"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// HandleBookTicket handles ticket booking requests, enforcing distributed mutual exclusion
+// HandleBookTicket places a temporary hold on a seat, enforcing distributed mutual exclusion
 func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
 	session, err := appState.Store.Get(r, "session-name")
 	if err != nil {
@@ -51,20 +55,51 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 
 	if r.Method == http.MethodGet {
 		if !requestCriticalSection(appState, userIDStr) {
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+			respondBusy(w)
 			return
 		}
 		defer releaseCriticalSection(appState)
 
-		trains, err := appState.DB.GetAvailableTickets(r.Context())
+		stations, err := appState.DB.ListStations(r.Context())
 		if err != nil {
-			log.Println("Error fetching available tickets:", err)
-			http.Error(w, "Failed to fetch available tickets", http.StatusInternalServerError)
+			log.Println("Error fetching stations:", err)
+			http.Error(w, "Failed to fetch stations", http.StatusInternalServerError)
 			return
 		}
+
+		origin := r.URL.Query().Get("origin")
+		destination := r.URL.Query().Get("destination")
+		var departures []database.GetAvailableTicketsRow
+		if origin != "" && destination != "" {
+			departures, err = appState.DB.GetAvailableTickets(r.Context(), database.GetAvailableTicketsParams{
+				OriginStationID:      origin,
+				DestinationStationID: destination,
+			})
+			if err != nil {
+				log.Println("Error fetching available tickets:", err)
+				http.Error(w, "Failed to fetch available tickets", http.StatusInternalServerError)
+				return
+			}
+		}
+		passengers, err := appState.DB.ListSavedPassengers(r.Context(), userIDStr)
+		if err != nil {
+			log.Println("Error fetching saved passengers:", err)
+			http.Error(w, "Failed to fetch saved passengers", http.StatusInternalServerError)
+			return
+		}
+		// Departures outside their booking window are listed with a countdown instead
+		onSale, upcoming := splitOnSale(quoteDepartures(appState.Fares, appState.Location, departures))
 		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]interface{}{
-			"Trains": trains,
-			"Error":  nil,
+			"Stations":        stations,
+			"Origin":          origin,
+			"Destination":     destination,
+			"Departures":      onSale,
+			"Upcoming":        upcoming,
+			"Waitlist":        distinctDepartures(onSaleRows(departures, appState.Location)),
+			"SavedPassengers": passengers,
+			"Genders":         passengerGenders,
+			"PartyPlaces":     partyPlaces,
+			"Error":           nil,
 		})
 		if err != nil {
 			log.Println("Error rendering template:", err)
@@ -79,7 +114,7 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 	}
 
 	if !requestCriticalSection(appState, userIDStr) {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		respondBusy(w)
 		return
 	}
 	defer releaseCriticalSection(appState)
@@ -91,14 +126,17 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	trainIDStr := r.FormValue("train_id")
+	// The departure select carries "<departure id>/<class>/<quota>"
+	departureIDStr, option, _ := strings.Cut(r.FormValue("departure"), "/")
+	class, requestedQuota, _ := strings.Cut(option, "/")
 	seatNumberStr := r.FormValue("seat_number")
+	preference := r.FormValue("preference")
 
-	trainID, err := uuid.Parse(trainIDStr)
+	departureID, err := uuid.Parse(departureIDStr)
 	if err != nil {
-		log.Println("Invalid train UUID:", err)
+		log.Println("Invalid departure UUID:", err)
 		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
-			"Error": "Invalid train ID",
+			"Error": "Invalid departure ID",
 		})
 		if err != nil {
 			log.Println("Error rendering template:", err)
@@ -106,11 +144,15 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	seatNumber, err := strconv.Atoi(seatNumberStr)
-	if err != nil || seatNumber < 1 {
-		log.Println("Invalid seat number:", seatNumberStr)
+	stops, err := appState.DB.GetJourneyStops(r.Context(), database.GetJourneyStopsParams{
+		DepartureID:          departureID.String(),
+		OriginStationID:      r.FormValue("origin"),
+		DestinationStationID: r.FormValue("destination"),
+	})
+	if err != nil {
+		log.Println("Invalid journey for departure:", err)
 		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
-			"Error": "Invalid seat number",
+			"Error": "This train does not run between the selected stations",
 		})
 		if err != nil {
 			log.Println("Error rendering template:", err)
@@ -118,17 +160,15 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	ticketID := uuid.New()
-	_, err = appState.DB.CreateTicket(r.Context(), database.CreateTicketParams{
-		ID:         ticketID.String(),
-		TrainID:    trainID.String(),
-		UserID:     userID.String(),
-		SeatNumber: int64(seatNumber),
-	})
+	opens, err := salesOpenAt(r.Context(), appState.DB, appState.Location, departureID.String(), class)
 	if err != nil {
-		log.Println("Error booking ticket:", err)
+		log.Println("Error fetching sale opening:", err)
+		http.Error(w, "Failed to check booking window", http.StatusInternalServerError)
+		return
+	}
+	if opens.After(time.Now()) {
 		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
-			"Error": "Seat already booked or invalid",
+			"Error": notOnSaleMessage(opens),
 		})
 		if err != nil {
 			log.Println("Error rendering template:", err)
@@ -136,50 +176,160 @@ func HandleBookTicket(appState *app.AppState, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	http.Redirect(w, r, "/tickets", http.StatusSeeOther)
-}
-
-// HandleCancelTicket cancels a user's ticket
-func HandleCancelTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
-	session, err := appState.Store.Get(r, "session-name")
+	party, err := partyFromForm(r.Context(), appState.DB, r, userIDStr)
 	if err != nil {
-		log.Println("Error getting session:", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Println("Invalid passenger:", err)
+		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+			"Error": displayText(err.Error()),
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+		}
 		return
 	}
 
-	userIDStr, ok := session.Values["userID"].(string)
-	if !ok || userIDStr == "" {
-		log.Println("User not authenticated")
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	// Everyone in the party books under the requested quota
+	var category quota.Category
+	for _, passenger := range party {
+		category, err = bookingQuota(r, requestedQuota, passenger)
+		if err != nil {
+			log.Println("Passenger not eligible for quota:", err)
+			err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+				"Error": displayText(err.Error()),
+			})
+			if err != nil {
+				log.Println("Error rendering template:", err)
+			}
+			return
+		}
+	}
+
+	// The fare is quoted now and locked in with the holds
+	fare, err := quoteSegment(r.Context(), appState.DB, appState.Fares, departureID.String(), class, stops.OriginStop, stops.DestinationStop)
+	if err != nil {
+		log.Println("Error quoting fare:", err)
+		http.Error(w, "Failed to quote fare", http.StatusInternalServerError)
 		return
 	}
 
-	userID, err := uuid.Parse(userIDStr)
+	// Hold a seat for each passenger; the party's holds share an itinerary so they are
+	// paid for together on /book/confirm and become tickets under one PNR. A requested
+	// seat number is for the first passenger, the others are seated by preference.
+	itineraryID := uuid.New().String()
+	expiresAt := time.Now().UTC().Add(appState.HoldTTL)
+	var holdIDs []string
+	err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+		for i, passenger := range party {
+			requested := seatNumberStr
+			if i > 0 {
+				requested = ""
+			}
+			seatNumber, soldUnder, err := chooseSeat(r.Context(), q, departureID.String(), class, stops, requested, preference, category)
+			if err != nil {
+				return err
+			}
+
+			hold, err := q.CreateSeatHold(r.Context(), database.CreateSeatHoldParams{
+				ID:                  uuid.New().String(),
+				DepartureID:         departureID.String(),
+				UserID:              userID.String(),
+				SeatNumber:          seatNumber,
+				OriginStop:          stops.OriginStop,
+				DestinationStop:     stops.DestinationStop,
+				FareAmount:          fare.Total,
+				FareCurrency:        fare.Currency,
+				ExpiresAt:           expiresAt,
+				PassengerName:       passenger.Name,
+				PassengerAge:        passenger.Age,
+				PassengerGender:     passenger.Gender,
+				PassengerIDDocument: passenger.IDDocument,
+				ItineraryID:         sql.NullString{String: itineraryID, Valid: true},
+				Leg:                 1,
+				Legs:                1,
+				Passenger:           int64(i + 1),
+				Passengers:          int64(len(party)),
+				Quota:               string(soldUnder),
+				ConcessionRef:       concessionRef(r, soldUnder),
+				SalesClock:          database.SalesClock(time.Now(), appState.Location),
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				return errSeatTaken
+			}
+			if err != nil {
+				return err
+			}
+			holdIDs = append(holdIDs, hold.ID)
+		}
+		return nil
+	})
 	if err != nil {
-		log.Println("Invalid user UUID:", err)
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		// Nothing was held, so the seats already picked for the party are free again
+		log.Println("Error holding seats:", err)
+		message := "Seat already booked or invalid"
+		if errors.Is(err, errNoSeatInClass) || errors.Is(err, errInvalidSeat) || errors.Is(err, errSeatReserved) {
+			message = displayText(err.Error())
+		}
+		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+			"Error": message,
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+		}
 		return
 	}
 
-	ticketIDStr := r.URL.Query().Get("ticket_id")
-	ticketID, err := uuid.Parse(ticketIDStr)
+	http.Redirect(w, r, "/book/confirm?hold_id="+holdIDs[0], http.StatusSeeOther)
+}
+
+// HandleCancelTicket shows the refund for cancelling a user's ticket and, once
+// confirmed, cancels it and hands the seat to the waitlist
+func HandleCancelTicket(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	ticketID, err := uuid.Parse(r.FormValue("ticket_id"))
 	if err != nil {
 		log.Println("Invalid ticket UUID:", err)
 		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
 		return
 	}
 
-	err = appState.DB.DeleteTicket(r.Context(), database.DeleteTicketParams{
-		ID:     ticketID.String(),
-		UserID: userID.String(),
-	})
+	ticket, err := appState.DB.GetTicket(r.Context(), ticketID.String())
+	if err != nil || ticket.UserID != userID.String() || ticket.Status != string(ticketstate.Confirmed) {
+		log.Println("Ticket not found for cancellation:", ticketID, err)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		renderCancelTicket(appState, w, ticket, map[string]interface{}{
+			"Action": "/cancel",
+		})
+		return
+	}
+
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	quote, err := cancelTicket(r.Context(), appState, ticket, "account", userID.String())
+	RecordAudit(appState, r, cancellationAudit(userID.String(), ticket, quote, err))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Println("Ticket already cancelled:", ticketID)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
 	if err != nil {
 		log.Println("Error cancelling ticket:", err)
 		http.Error(w, "Failed to cancel ticket", http.StatusInternalServerError)
 		return
 	}
 
+	log.Printf("Cancelled ticket %s, refunding %d", ticketID, quote.Amount)
 	http.Redirect(w, r, "/tickets", http.StatusSeeOther)
 }
 
@@ -206,15 +356,41 @@ func HandleViewTickets(appState *app.AppState, w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	tickets, err := appState.DB.GetUserTickets(r.Context(), userID.String())
+	// An unknown state filter shows every ticket
+	status := r.URL.Query().Get("status")
+	if !ticketstate.Valid(ticketstate.State(status)) {
+		status = ""
+	}
+	tickets, err := appState.DB.GetUserTickets(r.Context(), database.GetUserTicketsParams{
+		UserID: userID.String(),
+		Status: status,
+	})
 	if err != nil {
 		log.Println("Error fetching user tickets:", err)
 		http.Error(w, "Failed to fetch user tickets", http.StatusInternalServerError)
 		return
 	}
 
+	waitlist, err := appState.DB.GetUserWaitlist(r.Context(), userID.String())
+	if err != nil {
+		log.Println("Error fetching user waitlist:", err)
+		http.Error(w, "Failed to fetch user tickets", http.StatusInternalServerError)
+		return
+	}
+
+	offers, err := appState.DB.GetUserWaitlistOffers(r.Context(), userID.String())
+	if err != nil {
+		log.Println("Error fetching waitlist offers:", err)
+		http.Error(w, "Failed to fetch user tickets", http.StatusInternalServerError)
+		return
+	}
+
 	err = appState.Templates.ExecuteTemplate(w, "tickets.html", map[string]interface{}{
-		"Tickets": tickets,
+		"Tickets":  tickets,
+		"Waitlist": waitlist,
+		"Offers":   offers,
+		"Status":   status,
+		"States":   ticketstate.All,
 	})
 	if err != nil {
 		log.Println("Error rendering template:", err)
@@ -222,18 +398,36 @@ func HandleViewTickets(appState *app.AppState, w http.ResponseWriter, r *http.Re
 	}
 }
 
-// HandleViewAvailableTickets displays all available tickets
+// HandleViewAvailableTickets displays seat availability between two stations
 func HandleViewAvailableTickets(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
-	availableTickets, err := appState.DB.GetAvailableTickets(r.Context())
+	stations, err := appState.DB.ListStations(r.Context())
 	if err != nil {
-		log.Println("Error fetching available tickets:", err)
-		http.Error(w, "Failed to fetch available tickets", http.StatusInternalServerError)
+		log.Println("Error fetching stations:", err)
+		http.Error(w, "Failed to fetch stations", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Fetched available tickets: %+v", availableTickets) // Debug log
+
+	origin := r.URL.Query().Get("origin")
+	destination := r.URL.Query().Get("destination")
+	var availableTickets []database.GetAvailableTicketsRow
+	if origin != "" && destination != "" {
+		availableTickets, err = appState.DB.GetAvailableTickets(r.Context(), database.GetAvailableTicketsParams{
+			OriginStationID:      origin,
+			DestinationStationID: destination,
+		})
+		if err != nil {
+			log.Println("Error fetching available tickets:", err)
+			http.Error(w, "Failed to fetch available tickets", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Fetched available tickets: %+v", availableTickets) // Debug log
+	}
 
 	err = appState.Templates.ExecuteTemplate(w, "available.html", map[string]interface{}{
-		"Tickets": availableTickets,
+		"Stations":    stations,
+		"Origin":      origin,
+		"Destination": destination,
+		"Tickets":     quoteDepartures(appState.Fares, appState.Location, availableTickets),
 	})
 	if err != nil {
 		log.Println("Error rendering available tickets template:", err)
@@ -241,6 +435,14 @@ func HandleViewAvailableTickets(appState *app.AppState, w http.ResponseWriter, r
 	}
 }
 
+// respondBusy answers a request that could not enter the critical section. The 503
+// tells clients to retry, and lets Idempotent release the request's key so the retry
+// runs rather than replaying the refusal.
+func respondBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Booking system is busy, please try again", http.StatusServiceUnavailable)
+}
+
 // requestCriticalSection requests entry into the critical section for booking
 func requestCriticalSection(appState *app.AppState, userID string) bool {
 	appState.Node.Mutex.Lock()
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/timetable"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxPreviewDays is the longest period a timetable preview covers
+const maxPreviewDays = 366
+
+// timetableRule is a timetable rule as the admin API sends and returns it
+type timetableRule struct {
+	ID            string   `json:"id,omitempty"`
+	TrainID       string   `json:"train_id"`
+	Frequency     string   `json:"frequency"`
+	DaysOfWeek    string   `json:"days_of_week,omitempty"`
+	DepartureTime string   `json:"departure_time"`
+	ValidFrom     string   `json:"valid_from"`
+	ValidUntil    string   `json:"valid_until,omitempty"`
+	Exceptions    []string `json:"exceptions"`
+}
+
+func (t timetableRule) parse() (timetable.Rule, error) {
+	return timetable.Parse(t.Frequency, t.DaysOfWeek, t.DepartureTime, t.ValidFrom, t.ValidUntil, t.Exceptions)
+}
+
+// loadTimetableRule fetches a stored rule with its exception dates
+func loadTimetableRule(ctx context.Context, q database.QueriesInterface, row database.TimetableRule) (timetableRule, error) {
+	exceptions, err := q.ListTimetableExceptions(ctx, row.ID)
+	if err != nil {
+		return timetableRule{}, err
+	}
+	if exceptions == nil {
+		exceptions = []string{}
+	}
+	return timetableRule{
+		ID:            row.ID,
+		TrainID:       row.TrainID,
+		Frequency:     row.Frequency,
+		DaysOfWeek:    row.DaysOfWeek,
+		DepartureTime: row.DepartureTime,
+		ValidFrom:     row.ValidFrom,
+		ValidUntil:    row.ValidUntil.String,
+		Exceptions:    exceptions,
+	}, nil
+}
+
+// HandleTimetableRules lists the timetable rules (GET) or adds one (POST). New rules
+// are picked up by the next run of the departure generator.
+func HandleTimetableRules(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		rows, err := appState.DB.ListTimetableRules(r.Context())
+		if err != nil {
+			log.Println("Error fetching timetable rules:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch timetable rules"})
+			return
+		}
+		rules := []timetableRule{}
+		for _, row := range rows {
+			rule, err := loadTimetableRule(r.Context(), appState.DB, row)
+			if err != nil {
+				log.Println("Error fetching timetable exceptions:", err)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch timetable rules"})
+				return
+			}
+			rules = append(rules, rule)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"rules": rules})
+		return
+	}
+
+	var req timetableRule
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	rule, err := req.parse()
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var created database.TimetableRule
+	err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+		var err error
+		created, err = q.CreateTimetableRule(r.Context(), database.CreateTimetableRuleParams{
+			ID:            uuid.New().String(),
+			TrainID:       req.TrainID,
+			Frequency:     string(rule.Frequency),
+			DaysOfWeek:    timetable.FormatDays(rule.Days),
+			DepartureTime: req.DepartureTime,
+			ValidFrom:     req.ValidFrom,
+			ValidUntil:    sql.NullString{String: req.ValidUntil, Valid: req.ValidUntil != ""},
+		})
+		if err != nil {
+			return err
+		}
+		for _, date := range req.Exceptions {
+			err = q.AddTimetableException(r.Context(), database.AddTimetableExceptionParams{
+				RuleID:      created.ID,
+				ServiceDate: date,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Most likely a train that doesn't exist
+		log.Println("Error creating timetable rule:", err)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "failed to create timetable rule"})
+		return
+	}
+
+	stored, err := loadTimetableRule(r.Context(), appState.DB, created)
+	if err != nil {
+		log.Println("Error fetching timetable exceptions:", err)
+	}
+	writeJSON(w, http.StatusCreated, stored)
+}
+
+// HandleTimetableException stops a rule running on a date, such as a public holiday.
+// Departures already generated for that date are left for the operator to cancel.
+func HandleTimetableException(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RuleID      string `json:"rule_id"`
+		ServiceDate string `json:"service_date"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", req.ServiceDate); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "service_date must be a YYYY-MM-DD date"})
+		return
+	}
+
+	row, err := appState.DB.GetTimetableRule(r.Context(), req.RuleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "timetable rule not found"})
+		return
+	}
+	if err == nil {
+		err = appState.DB.AddTimetableException(r.Context(), database.AddTimetableExceptionParams{
+			RuleID:      row.ID,
+			ServiceDate: req.ServiceDate,
+		})
+	}
+	if err != nil {
+		log.Println("Error adding timetable exception:", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to add exception"})
+		return
+	}
+
+	rule, err := loadTimetableRule(r.Context(), appState.DB, row)
+	if err != nil {
+		log.Println("Error fetching timetable exceptions:", err)
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// HandleTimetablePreview lists the departures a rule would generate, without creating
+// any. The rule is either a stored one, by rule_id, or one described in the query
+// string the same way it would be created, so it can be checked before it is saved.
+func HandleTimetablePreview(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var rule timetableRule
+	if ruleID := query.Get("rule_id"); ruleID != "" {
+		row, err := appState.DB.GetTimetableRule(r.Context(), ruleID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "timetable rule not found"})
+			return
+		}
+		if err == nil {
+			rule, err = loadTimetableRule(r.Context(), appState.DB, row)
+		}
+		if err != nil {
+			log.Println("Error fetching timetable rule:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch timetable rule"})
+			return
+		}
+	} else {
+		rule = timetableRule{
+			TrainID:       query.Get("train_id"),
+			Frequency:     query.Get("frequency"),
+			DaysOfWeek:    query.Get("days_of_week"),
+			DepartureTime: query.Get("departure_time"),
+			ValidFrom:     query.Get("valid_from"),
+			ValidUntil:    query.Get("valid_until"),
+		}
+		if exceptions := query.Get("exceptions"); exceptions != "" {
+			rule.Exceptions = strings.Split(exceptions, ",")
+		}
+	}
+
+	parsed, err := rule.parse()
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+	parsed.Location = appState.Location
+
+	from := time.Now().In(appState.Location)
+	if f := query.Get("from"); f != "" {
+		from, err = time.ParseInLocation("2006-01-02", f, appState.Location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be a YYYY-MM-DD date"})
+			return
+		}
+	}
+	days := timetable.DefaultHorizonDays
+	if d := query.Get("days"); d != "" {
+		days, err = strconv.Atoi(d)
+		if err != nil || days < 1 || days > maxPreviewDays {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "days must be between 1 and " + strconv.Itoa(maxPreviewDays)})
+			return
+		}
+	}
+
+	runMinutes, err := appState.DB.GetTrainRunMinutes(r.Context(), rule.TrainID)
+	if err != nil {
+		log.Println("Error fetching train route:", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch train route"})
+		return
+	}
+
+	departures := parsed.Generate(from, days, time.Duration(runMinutes)*time.Minute)
+	if departures == nil {
+		departures = []timetable.Departure{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rule":       rule,
+		"from":       from.Format("2006-01-02"),
+		"days":       days,
+		"departures": departures,
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+)
+
+// trainRequest creates a train with its coaches, or renames one when ID is set
+type trainRequest struct {
+	ID      string         `json:"id,omitempty"`
+	Name    string         `json:"name"`
+	Coaches []coachRequest `json:"coaches"`
+}
+
+// departureRequest schedules a departure, or reschedules one when ID is set. Times
+// are RFC 3339; without arrives_at the train takes its usual running time.
+type departureRequest struct {
+	ID        string `json:"id,omitempty"`
+	TrainID   string `json:"train_id,omitempty"`
+	DepartsAt string `json:"departs_at"`
+	ArrivesAt string `json:"arrives_at,omitempty"`
+}
+
+// HandleTrains lists trains with their coaches (GET), creates one or renames one
+// (POST), or retires one, cancelling its unsold future departures (DELETE, by id in
+// the query string)
+func HandleTrains(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := appState.DB.ListTrains(r.Context())
+		if err != nil {
+			log.Println("Error fetching trains:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch trains"})
+			return
+		}
+		trains := []trainView{}
+		for _, row := range rows {
+			train, err := loadTrain(r.Context(), appState.DB, row)
+			if err != nil {
+				log.Println("Error fetching coaches:", err)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch trains"})
+				return
+			}
+			trains = append(trains, train)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"trains": trains})
+
+	case http.MethodPost:
+		var req trainRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		name, err := parseTrainName(req.Name)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+
+		status := http.StatusOK
+		var train database.Train
+		if req.ID != "" {
+			err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+				n, err := q.RenameTrain(r.Context(), database.RenameTrainParams{Name: name, ID: req.ID})
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return errTrainNotFound
+				}
+				train, err = q.GetTrain(r.Context(), req.ID)
+				return err
+			})
+		} else {
+			for i := range req.Coaches {
+				req.Coaches[i].ID = ""
+				if err := req.Coaches[i].validate(appState); err != nil {
+					writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+					return
+				}
+			}
+			status = http.StatusCreated
+			err = appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+				var err error
+				train, err = createTrain(r.Context(), q, name, req.Coaches)
+				return err
+			})
+		}
+		if err != nil {
+			status, message := fleetFailure(err, "save train")
+			writeJSON(w, status, map[string]string{"error": message})
+			return
+		}
+		log.Printf("Saved train %s (%s)", train.ID, train.Name)
+
+		view, err := loadTrain(r.Context(), appState.DB, train)
+		if err != nil {
+			log.Println("Error fetching coaches:", err)
+		}
+		writeJSON(w, status, view)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		var cancelled int64
+		err := appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+			var err error
+			cancelled, err = retireTrain(r.Context(), q, id)
+			return err
+		})
+		if err != nil {
+			status, message := fleetFailure(err, "retire train")
+			writeJSON(w, status, map[string]string{"error": message})
+			return
+		}
+		log.Printf("Retired train %s, cancelling %d departures", id, cancelled)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "cancelled_departures": cancelled})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleCoaches adds a coach to a train or resizes one (POST), or takes all of a
+// coach's seats out of service (DELETE, by id in the query string). Seats with a
+// ticket or hold on a departure still to run are never taken out of service, and a
+// change that would need to is refused.
+func HandleCoaches(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	var req coachRequest
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		if err := req.validate(appState); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+	case http.MethodDelete:
+		req.ID = r.URL.Query().Get("id")
+		if req.ID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id is required"})
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var coach database.GetCoachRow
+	err := appState.DB.ExecTx(r.Context(), func(q database.QueriesInterface) error {
+		var err error
+		if req.ID != "" {
+			coach, err = resizeCoach(r.Context(), q, req.ID, req.Seats)
+		} else {
+			coach, err = addCoach(r.Context(), q, req.TrainID, req)
+		}
+		return err
+	})
+	if err != nil {
+		status, message := fleetFailure(err, "save coach")
+		writeJSON(w, status, map[string]string{"error": message})
+		return
+	}
+	log.Printf("Coach %s of train %s now has %d seats", coach.Code, coach.TrainID, coach.Seats)
+
+	status := http.StatusOK
+	if req.ID == "" {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, coachView{
+		ID:        coach.ID,
+		TrainID:   coach.TrainID,
+		Code:      coach.Code,
+		Class:     coach.Class,
+		Position:  coach.Position,
+		Seats:     coach.Seats,
+		SoldSeats: coach.SoldSeats,
+	})
+}
+
+// HandleDepartures lists a train's departures from today on (GET, by train_id in the
+// query string), schedules or reschedules one (POST), or cancels one that has nothing
+// sold on it (DELETE, by id in the query string)
+func HandleDepartures(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		departures, err := listDepartures(r.Context(), appState.DB, appState.Location, r.URL.Query().Get("train_id"))
+		if err != nil {
+			log.Println("Error fetching departures:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to fetch departures"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"departures": departures})
+
+	case http.MethodPost:
+		var req departureRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+		departsAt, err := parseAdminTime(req.DepartsAt, appState.Location)
+		if err != nil || departsAt.IsZero() {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "departs_at must be an RFC 3339 time"})
+			return
+		}
+		arrivesAt, err := parseAdminTime(req.ArrivesAt, appState.Location)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "arrives_at must be an RFC 3339 time"})
+			return
+		}
+
+		status := http.StatusOK
+		var departure database.Departure
+		if req.ID != "" {
+			departure, err = rescheduleDeparture(r.Context(), appState.DB, req.ID, departsAt, arrivesAt)
+		} else {
+			status = http.StatusCreated
+			departure, err = createDeparture(r.Context(), appState.DB, appState.Location, req.TrainID, departsAt, arrivesAt)
+		}
+		if err != nil {
+			status, message := fleetFailure(err, "save departure")
+			writeJSON(w, status, map[string]string{"error": message})
+			return
+		}
+		log.Printf("Scheduled departure %s of train %s at %s", departure.ID, departure.TrainID, departure.DepartsAt)
+		writeJSON(w, status, departureView{
+			ID:          departure.ID,
+			TrainID:     departure.TrainID,
+			ServiceDate: departure.ServiceDate,
+			DepartsAt:   departure.DepartsAt,
+			ArrivesAt:   departure.ArrivesAt,
+			Status:      departure.Status,
+			RuleID:      departure.RuleID.String,
+		})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if err := cancelDeparture(r.Context(), appState.DB, id); err != nil {
+			status, message := fleetFailure(err, "cancel departure")
+			writeJSON(w, status, map[string]string{"error": message})
+			return
+		}
+		log.Printf("Cancelled departure %s", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
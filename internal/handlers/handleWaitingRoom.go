@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/waitingroom"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queueCookie carries the signed ticket for the user's place in the waiting room
+const queueCookie = "queue_ticket"
+
+// queueRefreshSeconds is how often the waiting room page checks the user's place
+const queueRefreshSeconds = 5
+
+// joinAttempts bounds how often joinQueue retries when another user, possibly on
+// another node, took the next position first
+const joinAttempts = 5
+
+// WaitingRoom wraps a booking handler so only users admitted from the waiting room
+// reach it. Everyone else is sent to the queue, and back here once admitted. With
+// the waiting room turned off the handler runs as usual.
+func WaitingRoom(handler func(*app.AppState, http.ResponseWriter, *http.Request)) func(*app.AppState, http.ResponseWriter, *http.Request) {
+	return func(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+		if appState.WaitingRoom == nil {
+			handler(appState, w, r)
+			return
+		}
+
+		userID, ok := currentUserID(appState, w, r)
+		if !ok {
+			return
+		}
+		entry, ok := queueEntry(appState, r, userID.String())
+		if !ok || !admitted(entry) {
+			http.Redirect(w, r, "/queue?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		handler(appState, w, r)
+	}
+}
+
+// HandleWaitingRoom shows the user their place in the queue, joining it if they
+// aren't already waiting, and sends them on to booking once they are admitted
+func HandleWaitingRoom(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	next := queueNext(r.URL.Query().Get("next"))
+	room := appState.WaitingRoom
+	if room == nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	ctx := r.Context()
+	entry, ok := queueEntry(appState, r, userID.String())
+	if ok && admitted(entry) {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	var err error
+	if !ok || entry.Status != "waiting" {
+		entry, err = joinQueue(ctx, appState.DB, userID.String())
+		if err != nil {
+			log.Println("Error joining the waiting room:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     queueCookie,
+			Value:    room.Sign(waitingroom.Ticket{EntryID: entry.ID, UserID: entry.UserID}),
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	} else if err = appState.DB.TouchQueueEntry(ctx, entry.ID); err != nil {
+		log.Println("Error updating queue entry:", err)
+	}
+
+	// Admit here as well as in the background job, so when there is room the head of
+	// the queue goes straight through instead of waiting for the next run
+	if err = admitFromQueue(ctx, appState.DB, room); err != nil {
+		log.Println("Error admitting from the waiting room:", err)
+	}
+	entry, err = appState.DB.GetQueueEntry(ctx, database.GetQueueEntryParams{ID: entry.ID, UserID: entry.UserID})
+	if err != nil {
+		log.Println("Error fetching queue entry:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if admitted(entry) {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	position, err := appState.DB.GetQueuePosition(ctx, database.GetQueuePositionParams{
+		Position:    entry.Position,
+		IdleSeconds: int64(room.IdleTimeout / time.Second),
+	})
+	if err != nil {
+		log.Println("Error fetching queue position:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = appState.Templates.ExecuteTemplate(w, "queue.html", map[string]interface{}{
+		"Position":       position,
+		"Wait":           formatCountdown(room.EstimatedWait(position)),
+		"Next":           next,
+		"RefreshSeconds": queueRefreshSeconds,
+	})
+	if err != nil {
+		log.Println("Error rendering template:", err)
+	}
+}
+
+// queueEntry returns the entry named by the user's queue ticket, if it verifies
+func queueEntry(appState *app.AppState, r *http.Request, userID string) (database.QueueEntry, bool) {
+	cookie, err := r.Cookie(queueCookie)
+	if err != nil {
+		return database.QueueEntry{}, false
+	}
+	ticket, err := appState.WaitingRoom.Verify(cookie.Value)
+	if err != nil || ticket.UserID != userID {
+		return database.QueueEntry{}, false
+	}
+
+	entry, err := appState.DB.GetQueueEntry(r.Context(), database.GetQueueEntryParams{
+		ID:     ticket.EntryID,
+		UserID: userID,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("Error fetching queue entry:", err)
+		}
+		return database.QueueEntry{}, false
+	}
+	return entry, true
+}
+
+// joinQueue puts a user at the back of the waiting room
+func joinQueue(ctx context.Context, db database.QueriesInterface, userID string) (database.QueueEntry, error) {
+	for range joinAttempts {
+		entry, err := db.JoinQueue(ctx, database.JoinQueueParams{
+			ID:     uuid.New().String(),
+			UserID: userID,
+		})
+		if !errors.Is(err, sql.ErrNoRows) {
+			return entry, err
+		}
+	}
+	return database.QueueEntry{}, errors.New("queue position still contended after retries")
+}
+
+// admitted reports whether an entry's admission to booking is still good
+func admitted(entry database.QueueEntry) bool {
+	return entry.Status == "admitted" && entry.ExpiresAt.Valid && entry.ExpiresAt.Time.After(time.Now())
+}
+
+// admitFromQueue admits the next users waiting, up to the room's rate
+func admitFromQueue(ctx context.Context, db database.QueriesInterface, room *waitingroom.Room) error {
+	_, err := db.AdmitFromQueue(ctx, database.AdmitFromQueueParams{
+		AdmissionSeconds: int64(room.AdmissionTTL / time.Second),
+		IdleSeconds:      int64(room.IdleTimeout / time.Second),
+		PerMinute:        int64(room.PerMinute),
+	})
+	return err
+}
+
+// queueNext is where to send a user once admitted, limited to the booking pages so
+// the queue can't be used to redirect elsewhere
+func queueNext(next string) string {
+	if !strings.HasPrefix(next, "/book") {
+		return "/book"
+	}
+	return next
+}
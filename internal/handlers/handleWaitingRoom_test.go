@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/waitingroom"
+
+	"github.com/google/uuid"
+)
+
+// rivalJoin has another user, as if on a second node, take the position each of the
+// first JoinQueue calls read, so those calls find it taken and get no row
+type rivalJoin struct {
+	database.QueriesInterface
+	rival  string
+	rivals int
+}
+
+func (q *rivalJoin) JoinQueue(ctx context.Context, arg database.JoinQueueParams) (database.QueueEntry, error) {
+	if q.rivals > 0 {
+		q.rivals--
+		if _, err := q.QueriesInterface.JoinQueue(ctx, database.JoinQueueParams{
+			ID:     uuid.NewString(),
+			UserID: q.rival,
+		}); err != nil {
+			return database.QueueEntry{}, err
+		}
+		return database.QueueEntry{}, sql.ErrNoRows
+	}
+	return q.QueriesInterface.JoinQueue(ctx, arg)
+}
+
+func TestJoinQueueRetriesTakenPosition(t *testing.T) {
+	pt := newTestApp(t)
+	rival := secondUser(t, pt)
+	ctx := context.Background()
+
+	q := &rivalJoin{QueriesInterface: pt.appState.DB, rival: rival}
+	if _, err := joinQueue(ctx, q, rival); err != nil {
+		t.Fatal(err)
+	}
+	q.rivals = 2
+	entry, err := joinQueue(ctx, q, pt.userID)
+	if err != nil {
+		t.Fatalf("joining behind a rival: %v", err)
+	}
+	if entry.UserID != pt.userID || entry.Position != 4 {
+		t.Errorf("joined at %d as %s, want position 4 as %s", entry.Position, entry.UserID, pt.userID)
+	}
+	if n := pt.count(t, "SELECT COUNT(DISTINCT position) FROM queue_entries"); n != 4 {
+		t.Errorf("%d distinct positions, want 4", n)
+	}
+
+	// Losing every attempt is an error rather than a spin
+	q.rivals = joinAttempts
+	if _, err := joinQueue(ctx, q, pt.userID); err == nil {
+		t.Error("joined although every position was taken first")
+	}
+}
+
+func TestWaitingRoomAdmitsHeadOfQueue(t *testing.T) {
+	pt := newTestApp(t)
+	pt.appState.WaitingRoom = waitingroom.New(1, []byte("test-queue-secret"))
+	other := secondUser(t, pt)
+	otherCookie := sessionCookie(t, pt.appState.Store, other)
+
+	// The first user is alone in the queue and goes straight through
+	first := queueRequest(t, pt.appState, pt.cookie)
+	if first.Code != http.StatusSeeOther || first.Header().Get("Location") != "/book" {
+		t.Fatalf("first user got %d to %q, want a redirect to /book", first.Code, first.Header().Get("Location"))
+	}
+	firstTicket := queueTicket(t, first)
+
+	// The room admits one a minute, so the second waits their turn
+	second := queueRequest(t, pt.appState, otherCookie)
+	if second.Code != http.StatusOK {
+		t.Fatalf("second user got %d, want the queue page", second.Code)
+	}
+	secondTicket := queueTicket(t, second)
+
+	booked := false
+	book := WaitingRoom(func(*app.AppState, http.ResponseWriter, *http.Request) { booked = true })
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/book", nil)
+	r.AddCookie(otherCookie)
+	r.AddCookie(secondTicket)
+	book(pt.appState, w, r)
+	if booked || w.Code != http.StatusSeeOther {
+		t.Fatalf("waiting user reached booking (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/book", nil)
+	r.AddCookie(pt.cookie)
+	r.AddCookie(firstTicket)
+	book(pt.appState, w, r)
+	if !booked {
+		t.Fatalf("admitted user was sent to the queue (status %d)", w.Code)
+	}
+
+	// A ticket is bound to the user it was issued to
+	booked = false
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/book", nil)
+	r.AddCookie(otherCookie)
+	r.AddCookie(firstTicket)
+	book(pt.appState, w, r)
+	if booked {
+		t.Error("another user's queue ticket let them into booking")
+	}
+}
+
+// secondUser adds another passenger to the test app
+func secondUser(t *testing.T, pt *paymentTest) string {
+	t.Helper()
+	user, err := pt.appState.DB.CreateUser(context.Background(), database.CreateUserParams{
+		ID:       uuid.NewString(),
+		Email:    "other@example.com",
+		Password: "not-a-real-hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+// queueRequest visits the waiting room as the user signed in with cookie
+func queueRequest(t *testing.T, appState *app.AppState, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/queue", nil)
+	r.AddCookie(cookie)
+	HandleWaitingRoom(appState, w, r)
+	return w
+}
+
+// queueTicket is the queue ticket cookie a waiting room response set
+func queueTicket(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == queueCookie {
+			return c
+		}
+	}
+	t.Fatal("no queue ticket was set")
+	return nil
+}
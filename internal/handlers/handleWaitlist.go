@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HandleJoinWaitlist adds the user to the waitlist of a fully booked departure
+func HandleJoinWaitlist(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	departureID, err := uuid.Parse(r.FormValue("departure_id"))
+	if err != nil {
+		log.Println("Invalid departure UUID:", err)
+		http.Redirect(w, r, "/book", http.StatusSeeOther)
+		return
+	}
+
+	stops, err := appState.DB.GetJourneyStops(r.Context(), database.GetJourneyStopsParams{
+		DepartureID:          departureID.String(),
+		OriginStationID:      r.FormValue("origin"),
+		DestinationStationID: r.FormValue("destination"),
+	})
+	if err != nil {
+		log.Println("Invalid journey for departure:", err)
+		http.Redirect(w, r, "/book", http.StatusSeeOther)
+		return
+	}
+
+	passenger, err := passengerFromForm(r.Context(), appState.DB, r, userID.String())
+	if err != nil {
+		log.Println("Invalid passenger:", err)
+		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+			"Error": displayText(err.Error()),
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+		}
+		return
+	}
+
+	// Joining is ordered with bookings so that positions match the order seats ran out
+	if !requestCriticalSection(appState, userID.String()) {
+		respondBusy(w)
+		return
+	}
+	defer releaseCriticalSection(appState)
+
+	_, err = appState.DB.JoinWaitlist(r.Context(), database.JoinWaitlistParams{
+		ID:                  uuid.New().String(),
+		DepartureID:         departureID.String(),
+		UserID:              userID.String(),
+		OriginStop:          stops.OriginStop,
+		DestinationStop:     stops.DestinationStop,
+		PassengerName:       passenger.Name,
+		PassengerAge:        passenger.Age,
+		PassengerGender:     passenger.Gender,
+		PassengerIDDocument: passenger.IDDocument,
+	})
+	if err != nil {
+		log.Println("Error joining waitlist:", err)
+		err = appState.Templates.ExecuteTemplate(w, "book.html", map[string]string{
+			"Error": "Departure still has seats available or you are already on its waitlist",
+		})
+		if err != nil {
+			log.Println("Error rendering template:", err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+}
+
+// HandleLeaveWaitlist removes the user from a waitlist they joined
+func HandleLeaveWaitlist(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(appState, w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		log.Println("Error parsing form:", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	entryID, err := uuid.Parse(r.FormValue("entry_id"))
+	if err != nil {
+		log.Println("Invalid waitlist entry UUID:", err)
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+
+	err = appState.DB.LeaveWaitlist(r.Context(), database.LeaveWaitlistParams{
+		ID:     entryID.String(),
+		UserID: userID.String(),
+	})
+	if err != nil {
+		log.Println("Error leaving waitlist:", err)
+		http.Error(w, "Failed to leave waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+}
+
+// promoteWaitlist offers a freed seat to the first waiting user whose journey fits on
+// it, at the fare current at promotion time. The seat is held for them with a pending
+// payment; it only becomes a ticket once they pay, and goes to the next in line if the
+// offer lapses. Seats freed once the train has left, or on a cancelled departure, are
+// not offered. It must run inside the critical section and the transaction that freed
+// the seat.
+func promoteWaitlist(ctx context.Context, appState *app.AppState, q database.QueriesInterface, departureID string, seatNumber int64) error {
+	departure, err := q.GetDeparture(ctx, departureID)
+	if err != nil {
+		return err
+	}
+	if departure.Status == "cancelled" || !departure.DepartsAt.After(time.Now()) {
+		return nil
+	}
+
+	entries, err := q.GetWaitingEntries(ctx, departureID)
+	if err != nil {
+		return err
+	}
+
+	seat, err := q.GetDepartureSeat(ctx, database.GetDepartureSeatParams{
+		DepartureID: departureID,
+		SeatNumber:  seatNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		fare, err := quoteSegment(ctx, q, appState.Fares, departureID, seat.Class, entry.OriginStop, entry.DestinationStop)
+		if err != nil {
+			return err
+		}
+
+		hold, err := q.CreateSeatHold(ctx, database.CreateSeatHoldParams{
+			ID:                  uuid.New().String(),
+			DepartureID:         departureID,
+			UserID:              entry.UserID,
+			SeatNumber:          seatNumber,
+			OriginStop:          entry.OriginStop,
+			DestinationStop:     entry.DestinationStop,
+			FareAmount:          fare.Total,
+			FareCurrency:        fare.Currency,
+			ExpiresAt:           time.Now().UTC().Add(appState.WaitlistOfferTTL),
+			PassengerName:       entry.PassengerName,
+			PassengerAge:        entry.PassengerAge,
+			PassengerGender:     entry.PassengerGender,
+			PassengerIDDocument: entry.PassengerIDDocument,
+			Leg:                 1,
+			Legs:                1,
+			Passenger:           1,
+			Passengers:          1,
+			Quota:               string(quota.General),
+			SalesClock:          database.SalesClock(time.Now(), appState.Location),
+			Channel:             channelWaitlist,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The seat is still taken on part of this entry's journey
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// The user pays this on /book/confirm like any other hold
+		_, err = q.CreatePayment(ctx, database.CreatePaymentParams{
+			ID:       uuid.New().String(),
+			UserID:   entry.UserID,
+			HoldID:   hold.ID,
+			Provider: appState.Payments.Name(),
+			Amount:   hold.FareAmount,
+			Currency: hold.FareCurrency,
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Offered seat %d to waitlist entry %s, held as %s until %s", seatNumber, entry.ID, hold.ID, hold.ExpiresAt.Format(time.RFC3339))
+		return q.MarkWaitlistPromoted(ctx, database.MarkWaitlistPromotedParams{
+			HoldID: sql.NullString{String: hold.ID, Valid: true},
+			ID:     entry.ID,
+		})
+	}
+	return nil
+}
+
+// freeHeldSeat fails the payment still waiting on a deleted seat hold, such as a lapsed
+// waitlist offer, and offers the seat to the waitlist. It must run inside the critical
+// section and the transaction that deleted the hold.
+func freeHeldSeat(ctx context.Context, appState *app.AppState, q database.QueriesInterface, holdID, departureID string, seatNumber int64, reason string) error {
+	_, err := q.FailHoldPayments(ctx, database.FailHoldPaymentsParams{
+		HoldID:        holdID,
+		FailureReason: reason,
+	})
+	if err != nil {
+		return err
+	}
+	return promoteWaitlist(ctx, appState, q, departureID, seatNumber)
+}
+
+// releaseItinerary gives up a seat hold, with the other legs and passengers held with
+// it, fails the payment left waiting on it and offers each freed seat to the waitlist.
+// It must run inside the critical section.
+func releaseItinerary(ctx context.Context, appState *app.AppState, q database.QueriesInterface, holdID, userID string) error {
+	return q.ExecTx(ctx, func(q database.QueriesInterface) error {
+		freed, err := q.ReleaseItineraryHolds(ctx, database.ReleaseItineraryHoldsParams{
+			HoldID: holdID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		for _, seat := range freed {
+			if err := freeHeldSeat(ctx, appState, q, seat.ID, seat.DepartureID, seat.SeatNumber, "seat hold released"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReleaseExpiredHolds deletes expired seat holds, including lapsed waitlist offers, fails
+// the payments left waiting on them and offers each freed seat to the waitlist. It returns how many holds were released. A
+// pass that can't enter the critical section releases nothing and leaves the holds to
+// the next one.
+func ReleaseExpiredHolds(ctx context.Context, appState *app.AppState) (int, error) {
+	if !requestCriticalSection(appState, "") {
+		return 0, nil
+	}
+	defer releaseCriticalSection(appState)
+
+	var released int
+	err := appState.DB.ExecTx(ctx, func(q database.QueriesInterface) error {
+		freed, err := q.DeleteExpiredSeatHolds(ctx)
+		if err != nil {
+			return err
+		}
+		for _, seat := range freed {
+			if err := freeHeldSeat(ctx, appState, q, seat.ID, seat.DepartureID, seat.SeatNumber, "seat hold expired"); err != nil {
+				return err
+			}
+		}
+		released = len(freed)
+		return nil
+	})
+	return released, err
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IdempotencyHeader lets API clients name a request so retries of it are not
+// applied twice. Forms send the same key in the idempotency_key field.
+const IdempotencyHeader = "Idempotency-Key"
+
+const idempotencyField = "idempotency_key"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLease is how long a key is held while its first request runs. It outlasts
+// any request, and if the node handling it dies the key frees up for a retry well
+// before the replay window would end.
+const idempotencyLease = 2 * time.Minute
+
+// recordedResponse buffers a handler's response so it can be stored before it is sent
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recordedResponse) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recordedResponse) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recordedResponse) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+// idempotencyOwner scopes keys to the requester: the logged-in user, or the booking
+// on the public manage booking page
+func idempotencyOwner(appState *app.AppState, r *http.Request) string {
+	if session, err := appState.Store.Get(r, "session-name"); err == nil {
+		if userID, ok := session.Values["userID"].(string); ok && userID != "" {
+			return userID
+		}
+	}
+	return "pnr:" + strings.ToUpper(strings.TrimSpace(r.PostFormValue("pnr")))
+}
+
+// requestHash fingerprints a form, apart from its idempotency key
+func requestHash(r *http.Request) string {
+	keys := make([]string, 0, len(r.PostForm))
+	for k := range r.PostForm {
+		if k != idempotencyField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(r.URL.Path))
+	for _, k := range keys {
+		for _, v := range r.PostForm[k] {
+			h.Write([]byte{0})
+			h.Write([]byte(k))
+			h.Write([]byte{'='})
+			h.Write([]byte(v))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent wraps a POST handler so a request repeated with the same idempotency key,
+// from a double click or a client retry, gets the first response replayed instead of
+// running again. The key is claimed for idempotencyLease while the first request runs,
+// and its response is then kept in the database for appState.IdempotencyWindow so
+// retries are caught whichever node they reach. Requests without a key run as usual.
+func Idempotent(scope string, handler func(*app.AppState, http.ResponseWriter, *http.Request)) func(*app.AppState, http.ResponseWriter, *http.Request) {
+	return func(appState *app.AppState, w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handler(appState, w, r)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			log.Println("Error parsing form:", err)
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			key = r.PostFormValue(idempotencyField)
+		}
+		if key == "" {
+			handler(appState, w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency key too long", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		id := database.GetIdempotencyKeyParams{
+			Owner:          idempotencyOwner(appState, r),
+			Scope:          scope,
+			IdempotencyKey: key,
+		}
+		hash := requestHash(r)
+
+		_, err := appState.DB.ClaimIdempotencyKey(ctx, database.ClaimIdempotencyKeyParams{
+			Owner:          id.Owner,
+			Scope:          id.Scope,
+			IdempotencyKey: id.IdempotencyKey,
+			RequestHash:    hash,
+			ExpiresAt:      time.Now().UTC().Add(idempotencyLease),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			replayIdempotent(appState, w, r, id, hash)
+			return
+		}
+		if err != nil {
+			log.Println("Error claiming idempotency key:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		rec := &recordedResponse{header: make(http.Header)}
+		handler(appState, rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if rec.status >= http.StatusInternalServerError {
+			// Let the client retry failures rather than replaying them
+			err = appState.DB.ReleaseIdempotencyKey(ctx, database.ReleaseIdempotencyKeyParams(id))
+		} else {
+			err = appState.DB.SaveIdempotentResponse(ctx, database.SaveIdempotentResponseParams{
+				Owner:               id.Owner,
+				Scope:               id.Scope,
+				IdempotencyKey:      id.IdempotencyKey,
+				ResponseStatus:      sql.NullInt64{Int64: int64(rec.status), Valid: true},
+				ResponseLocation:    rec.header.Get("Location"),
+				ResponseContentType: rec.header.Get("Content-Type"),
+				ResponseBody:        rec.body.String(),
+				ExpiresAt:           time.Now().UTC().Add(appState.IdempotencyWindow),
+			})
+		}
+		if err != nil {
+			log.Println("Error storing idempotent response:", err)
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	}
+}
+
+// replayIdempotent answers a repeated request with the stored first response
+func replayIdempotent(appState *app.AppState, w http.ResponseWriter, r *http.Request, id database.GetIdempotencyKeyParams, hash string) {
+	stored, err := appState.DB.GetIdempotencyKey(r.Context(), id)
+	if err != nil {
+		log.Println("Error fetching idempotency key:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if stored.RequestHash != hash {
+		http.Error(w, "Idempotency key was already used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if !stored.ResponseStatus.Valid {
+		http.Error(w, "A request with this idempotency key is still being processed", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Replaying %s response for idempotency key %s", id.Scope, id.IdempotencyKey)
+	if stored.ResponseLocation != "" {
+		w.Header().Set("Location", stored.ResponseLocation)
+	}
+	if stored.ResponseContentType != "" {
+		w.Header().Set("Content-Type", stored.ResponseContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.ResponseStatus.Int64))
+	w.Write([]byte(stored.ResponseBody))
+}
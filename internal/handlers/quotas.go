@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+	"strings"
+)
+
+// generalRows keeps the availability of seats outside any quota, for bookings that
+// can't be made under one
+func generalRows(departures []database.GetAvailableTicketsRow) []database.GetAvailableTicketsRow {
+	var general []database.GetAvailableTicketsRow
+	for _, d := range departures {
+		if d.Quota == string(quota.General) {
+			general = append(general, d)
+		}
+	}
+	return general
+}
+
+// bookingQuota reads the quota a booking form asks for and checks the passenger is
+// eligible for it
+func bookingQuota(r *http.Request, requested string, passenger passengerDetails) (quota.Category, error) {
+	category, err := quota.Parse(requested)
+	if err != nil {
+		return "", errors.New("unknown quota")
+	}
+	err = category.Eligible(quota.Passenger{
+		Age:           passenger.Age.Int64,
+		Gender:        passenger.Gender.String,
+		ConcessionRef: r.FormValue("concession_ref"),
+	})
+	return category, err
+}
+
+// concessionRef is the reference kept with a seat sold under a quota
+func concessionRef(r *http.Request, soldUnder quota.Category) sql.NullString {
+	ref := strings.TrimSpace(r.FormValue("concession_ref"))
+	return sql.NullString{String: ref, Valid: soldUnder != quota.General && ref != ""}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"rsvbackend/internal/database"
+	"time"
+)
+
+// salesOpenFormat is how sale opening times are shown to users
+const salesOpenFormat = "Jan 2 15:04 MST"
+
+// SalesOpen is when tickets in the option's class go on sale, or the zero time if
+// they always are
+func (o departureOption) SalesOpen() time.Time {
+	return o.salesOpen
+}
+
+// OnSale reports whether the option's class can be booked yet
+func (o departureOption) OnSale() bool {
+	return !o.SalesOpen().After(time.Now())
+}
+
+// OpensIn is the countdown until the option's class goes on sale
+func (o departureOption) OpensIn() string {
+	return formatCountdown(time.Until(o.SalesOpen()))
+}
+
+// formatCountdown renders a wait in days, hours and minutes
+func formatCountdown(d time.Duration) string {
+	if d <= 0 {
+		return "now"
+	}
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// splitOnSale separates the options that can be booked now from those whose booking
+// window hasn't opened
+func splitOnSale(options []departureOption) (onSale, upcoming []departureOption) {
+	for _, o := range options {
+		if o.OnSale() {
+			onSale = append(onSale, o)
+		} else {
+			upcoming = append(upcoming, o)
+		}
+	}
+	return onSale, upcoming
+}
+
+// onSaleRows keeps the availability rows whose booking window, on the operator's
+// clock in loc, has opened
+func onSaleRows(departures []database.GetAvailableTicketsRow, loc *time.Location) []database.GetAvailableTicketsRow {
+	var open []database.GetAvailableTicketsRow
+	now := time.Now()
+	for _, d := range departures {
+		if !database.ParseSalesOpenAt(d.SalesOpenAt, loc).After(now) {
+			open = append(open, d)
+		}
+	}
+	return open
+}
+
+// salesOpenAt is when a class on a departure goes on sale, or the zero time if it
+// always is. The seat hold insert enforces the same window; this is for explaining
+// a refusal.
+func salesOpenAt(ctx context.Context, q database.QueriesInterface, loc *time.Location, departureID, class string) (time.Time, error) {
+	s, err := q.GetSalesOpenAt(ctx, database.GetSalesOpenAtParams{
+		DepartureID: departureID,
+		Class:       class,
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return database.ParseSalesOpenAt(s, loc), nil
+}
+
+// notOnSaleMessage tells a user when a departure they tried to book goes on sale
+func notOnSaleMessage(opens time.Time) string {
+	return fmt.Sprintf("Tickets for this departure go on sale at %s (in %s)", opens.Format(salesOpenFormat), formatCountdown(time.Until(opens)))
+}
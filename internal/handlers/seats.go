@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/quota"
+	"strconv"
+)
+
+var (
+	errNoSeatInClass = errors.New("no seats left in this class")
+	errInvalidSeat   = errors.New("invalid seat number")
+	errSeatReserved  = errors.New("that seat is reserved for another quota")
+)
+
+// chooseSeat resolves the seat a booking form asks for. With no seat number, the
+// best free seat in the class for the preference is picked; otherwise the requested
+// seat must exist in the class. Whether the seat is free is left to the insert.
+// Seats held back for a quota only go to passengers booking under it, who get a
+// general seat when the quota has none left. It returns the seat and the quota it is
+// sold under.
+func chooseSeat(ctx context.Context, q database.QueriesInterface, departureID, class string, stops database.GetJourneyStopsRow, seatNumber, preference string, category quota.Category) (int64, quota.Category, error) {
+	if seatNumber == "" {
+		seat, err := q.FindAvailableSeat(ctx, database.FindAvailableSeatParams{
+			DepartureID:     departureID,
+			Class:           class,
+			OriginStop:      stops.OriginStop,
+			DestinationStop: stops.DestinationStop,
+			Preference:      preference,
+			Quota:           string(category),
+		})
+		if err == nil {
+			return seat.SeatNumber, category, nil
+		}
+		if category == quota.General {
+			log.Println("No seat available:", err)
+			return 0, "", errNoSeatInClass
+		}
+		log.Printf("No %s quota seat available, trying general: %v", category, err)
+		return chooseSeat(ctx, q, departureID, class, stops, "", preference, quota.General)
+	}
+
+	n, err := strconv.Atoi(seatNumber)
+	if err != nil || n < 1 {
+		log.Println("Invalid seat number:", seatNumber)
+		return 0, "", errInvalidSeat
+	}
+	seat, err := q.GetDepartureSeat(ctx, database.GetDepartureSeatParams{
+		DepartureID: departureID,
+		SeatNumber:  int64(n),
+	})
+	if err != nil || seat.Class != class {
+		log.Println("Seat not in selected class:", seatNumber, err)
+		return 0, "", errInvalidSeat
+	}
+	if seat.Quota != string(quota.General) && seat.Quota != string(category) {
+		log.Printf("Seat %d is in the %s quota", seat.SeatNumber, seat.Quota)
+		return 0, "", errSeatReserved
+	}
+	return seat.SeatNumber, quota.Category(seat.Quota), nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// currentUserID returns the authenticated user's ID from the session. When there is
+// no usable session it writes the appropriate response and returns false.
+func currentUserID(appState *app.AppState, w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	session, err := appState.Store.Get(r, "session-name")
+	if err != nil {
+		log.Println("Error getting session:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+
+	userIDStr, ok := session.Values["userID"].(string)
+	if !ok || userIDStr == "" {
+		log.Println("User not authenticated")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		log.Println("Invalid user UUID:", err)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// UserFromRequest looks up the logged-in user. Roles are read on every request rather
+// than kept in the session, so a change of role applies from the user's next request.
+func UserFromRequest(appState *app.AppState, r *http.Request) (database.User, bool) {
+	session, err := appState.Store.Get(r, "session-name")
+	if err != nil {
+		return database.User{}, false
+	}
+	userID, ok := session.Values["userID"].(string)
+	if !ok || userID == "" {
+		return database.User{}, false
+	}
+
+	user, err := appState.DB.GetUser(r.Context(), userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("Error fetching user:", err)
+		}
+		return database.User{}, false
+	}
+	return user, true
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"rsvbackend/internal/app"
+	"rsvbackend/internal/audit"
 	"rsvbackend/internal/auth"
 	"rsvbackend/internal/database"
 
@@ -49,6 +52,7 @@ func HandleRegister(appState *app.AppState, w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		// Check if it's a duplicate email error (SQLite error code 19 or similar)
 		if err.Error() == "UNIQUE constraint failed: users.email" { // Adjust based on actual error string
+			RecordAudit(appState, r, audit.Entry{Actor: email, Action: audit.Register, Target: email, Result: audit.Failure, Detail: "email already exists"})
 			appState.Templates.ExecuteTemplate(w, "register.html", map[string]string{"Error": "Email already exists"})
 		} else {
 			log.Printf("Error creating user: params=%+v, err=%v", params, err)
@@ -56,6 +60,7 @@ func HandleRegister(appState *app.AppState, w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
+	RecordAudit(appState, r, audit.Entry{Actor: userID, Action: audit.Register, Target: email, Result: audit.Success})
 
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
@@ -75,15 +80,22 @@ func HandleLogin(appState *app.AppState, w http.ResponseWriter, r *http.Request)
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
+	// Failed logins are recorded against the email given, as there may be no such user
 	user, err := appState.DB.GetUserByEmail(r.Context(), email)
 	if err != nil {
 		log.Println("Error fetching user:", err)
+		detail := "unknown email"
+		if !errors.Is(err, sql.ErrNoRows) {
+			detail = "user lookup failed"
+		}
+		RecordAudit(appState, r, audit.Entry{Actor: email, Action: audit.Login, Target: email, Result: audit.Failure, Detail: detail})
 		appState.Templates.ExecuteTemplate(w, "login.html", map[string]string{"Error": "Invalid email or password"})
 		return
 	}
 
 	err = auth.CheckPassword(user.Password, password)
 	if err != nil {
+		RecordAudit(appState, r, audit.Entry{Actor: email, Action: audit.Login, Target: email, Result: audit.Failure, Detail: "wrong password"})
 		appState.Templates.ExecuteTemplate(w, "login.html", map[string]string{"Error": "Invalid email or password"})
 		return
 	}
@@ -103,6 +115,7 @@ func HandleLogin(appState *app.AppState, w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	RecordAudit(appState, r, audit.Entry{Actor: user.ID, Action: audit.Login, Target: email, Result: audit.Success})
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
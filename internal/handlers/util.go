@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+)
+
+// displayText turns an error message into text for a page. Error strings are lower
+// case, so the first letter is capitalized here; JSON responses use them as they are.
+func displayText(message string) string {
+	if message == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(message)
+	return string(unicode.ToUpper(r)) + message[size:]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error writing JSON response:", err)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SweepExpiredHolds calls release every interval until ctx is cancelled to delete
+// expired seat holds, returning seats from abandoned checkouts to sale. release also
+// offers the freed seats to the waitlist, which needs the booking handlers' critical
+// section, so the caller supplies it.
+func SweepExpiredHolds(ctx context.Context, release func(context.Context) (int, error), interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := release(ctx)
+			if err != nil {
+				log.Println("Error sweeping expired seat holds:", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("Released %d expired seat holds", released)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"rsvbackend/internal/database"
+	"time"
+)
+
+// SweepExpiredIdempotencyKeys deletes stored responses whose replay window has passed
+// every interval until ctx is cancelled. Expired keys are reclaimed on reuse anyway;
+// this only keeps the table small.
+func SweepExpiredIdempotencyKeys(ctx context.Context, db database.QueriesInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := db.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				log.Println("Error sweeping expired idempotency keys:", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired idempotency keys", deleted)
+			}
+		}
+	}
+}
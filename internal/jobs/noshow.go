@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/ticketstate"
+	"time"
+)
+
+// noShowBatch caps how many tickets one pass marks
+const noShowBatch = 500
+
+// MarkNoShows moves confirmed tickets to no-show once their train has left the
+// passenger's origin without them checking in, every interval until ctx is cancelled
+func MarkNoShows(ctx context.Context, db database.QueriesInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			marked, err := markNoShows(ctx, db)
+			if err != nil {
+				log.Println("Error marking no-shows:", err)
+			}
+			if marked > 0 {
+				log.Printf("Marked %d tickets as no-shows", marked)
+			}
+		}
+	}
+}
+
+// markNoShows runs one pass. A ticket checked in by another request in the meantime
+// is left as it is.
+func markNoShows(ctx context.Context, db database.QueriesInterface) (int, error) {
+	ticketIDs, err := db.ListMissedTickets(ctx, noShowBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	marked := 0
+	for _, id := range ticketIDs {
+		err := db.ExecTx(ctx, func(q database.QueriesInterface) error {
+			_, err := ticketstate.Transition(ctx, q, ticketstate.Change{
+				TicketID: id,
+				From:     ticketstate.Confirmed,
+				To:       ticketstate.NoShow,
+				Actor:    "system",
+				Reason:   "not checked in before departure",
+			})
+			return err
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return marked, err
+		}
+		marked++
+	}
+	return marked, nil
+}
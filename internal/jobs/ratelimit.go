@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"rsvbackend/internal/ratelimit"
+	"time"
+)
+
+// SweepRateLimiter forgets the limiter's quiet keys every interval until ctx is
+// cancelled, so attempts from clients that never come back don't pile up in memory
+func SweepRateLimiter(ctx context.Context, limiter *ratelimit.Limiter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			limiter.Sweep()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/payment"
+	"time"
+)
+
+// refundBatch caps how many queued refunds one pass retries
+const refundBatch = 100
+
+// RefundLease is how long a node has to make a refund it claimed before another node
+// may take the claim over
+const RefundLease = 2 * time.Minute
+
+// RetryRefunds makes refunds that are still queued: ones the provider refused, and
+// ones whose node stopped before making them. It runs every interval until ctx is
+// cancelled.
+func RetryRefunds(ctx context.Context, db database.QueriesInterface, provider payment.Provider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			settled, err := retryRefunds(ctx, db, provider)
+			if err != nil {
+				log.Println("Error retrying refunds:", err)
+			}
+			if settled > 0 {
+				log.Printf("Made %d queued refunds", settled)
+			}
+		}
+	}
+}
+
+// retryRefunds runs one pass. A refund the provider still refuses stays claimed with
+// its latest error, and is tried again once the claim lapses.
+func retryRefunds(ctx context.Context, db database.QueriesInterface, provider payment.Provider) (int, error) {
+	due, err := db.ListDueRefunds(ctx, refundBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, p := range due {
+		made, err := MakeRefund(ctx, db, provider, p.ID)
+		if err != nil {
+			log.Printf("Refund on payment %s failed again: %v", p.ID, err)
+			continue
+		}
+		if made {
+			settled++
+		}
+	}
+	return settled, nil
+}
+
+// MakeRefund claims the refund queued on a payment and asks the provider for it. The
+// claim means only one node makes a queued refund, and it reports false if there was
+// nothing to claim. The provider is given a key for the claimed refund, so a claim
+// taken over from a node that stopped mid-refund is not paid out twice.
+func MakeRefund(ctx context.Context, db database.QueriesInterface, provider payment.Provider, paymentID string) (bool, error) {
+	p, err := db.ClaimRefund(ctx, database.ClaimRefundParams{
+		ID:           paymentID,
+		LeaseSeconds: int64(RefundLease / time.Second),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	res, err := provider.Refund(ctx, p.ProviderRef.String, refundKey(p), p.RefundClaimed)
+	if err == nil && res.Status != payment.Refunded {
+		err = errors.New("refund failed: " + res.Reason)
+	}
+	if err != nil {
+		setErr := db.SetRefundError(ctx, database.SetRefundErrorParams{
+			ID:          p.ID,
+			RefundError: err.Error(),
+		})
+		if setErr != nil {
+			log.Printf("Error recording refund failure on payment %s: %v", p.ID, setErr)
+		}
+		return false, err
+	}
+
+	_, err = db.SettleClaimedRefund(ctx, database.SettleClaimedRefundParams{
+		ID:            p.ID,
+		RefundClaimed: p.RefundClaimed,
+	})
+	if err != nil {
+		return false, err
+	}
+	log.Printf("Refunded %d of payment %s", p.RefundClaimed, p.ID)
+	return true, nil
+}
+
+// refundKey names a claimed refund to the provider. It stays the same while the claim
+// is retried, and changes once the refund is settled.
+func refundKey(p database.Payment) string {
+	return fmt.Sprintf("%s-%d-%d", p.ID, p.RefundedAmount, p.RefundClaimed)
+}
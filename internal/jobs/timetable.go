@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/timetable"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GenerateDepartures materializes departures from the timetable rules for the next
+// horizonDays days of the operator's calendar in loc, on start and then every
+// interval until ctx is cancelled. A rule makes at most one departure a day, so runs
+// that overlap, on this node or another, only add the days that are missing.
+func GenerateDepartures(ctx context.Context, db database.QueriesInterface, loc *time.Location, horizonDays int, interval time.Duration) {
+	generate := func() {
+		created, err := MaterializeTimetable(ctx, db, loc, time.Now(), horizonDays)
+		if err != nil {
+			log.Println("Error generating departures:", err)
+		}
+		if created > 0 {
+			log.Printf("Generated %d departures from timetable rules", created)
+		}
+	}
+	generate()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			generate()
+		}
+	}
+}
+
+// MaterializeTimetable creates the departures every timetable rule makes over days
+// days from from, reading the rules in the operator's time zone loc, skipping those
+// already gone or that already exist, and returns how many it created.
+// A rule that can't be read is logged and skipped so it doesn't hold up the others.
+func MaterializeTimetable(ctx context.Context, db database.QueriesInterface, loc *time.Location, from time.Time, days int) (int64, error) {
+	rules, err := db.ListTimetableRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var created int64
+	for _, row := range rules {
+		exceptions, err := db.ListTimetableExceptions(ctx, row.ID)
+		if err != nil {
+			return created, err
+		}
+		rule, err := timetable.Parse(row.Frequency, row.DaysOfWeek, row.DepartureTime, row.ValidFrom, row.ValidUntil.String, exceptions)
+		if err != nil {
+			log.Printf("Skipping invalid timetable rule %s: %v", row.ID, err)
+			continue
+		}
+		rule.Location = loc
+		runMinutes, err := db.GetTrainRunMinutes(ctx, row.TrainID)
+		if err != nil {
+			return created, err
+		}
+
+		for _, d := range rule.Generate(from, days, time.Duration(runMinutes)*time.Minute) {
+			if !d.DepartsAt.After(from) {
+				continue
+			}
+			n, err := db.CreateScheduledDeparture(ctx, database.CreateScheduledDepartureParams{
+				ID:          uuid.New().String(),
+				TrainID:     row.TrainID,
+				ServiceDate: d.ServiceDate,
+				DepartsAt:   d.DepartsAt,
+				ArrivesAt:   d.ArrivesAt,
+				RuleID:      sql.NullString{String: row.ID, Valid: true},
+			})
+			if err != nil {
+				return created, err
+			}
+			created += n
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"rsvbackend/internal/database"
+	"rsvbackend/internal/waitingroom"
+	"time"
+)
+
+// AdmitFromWaitingRoom lets the next users in the waiting room through to booking
+// every interval until ctx is cancelled. Every node runs it against the same queue;
+// the admission query caps admissions over the last minute at the room's rate, so
+// together they admit no faster than one node would.
+func AdmitFromWaitingRoom(ctx context.Context, db database.QueriesInterface, room *waitingroom.Room, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	idleSeconds := int64(room.IdleTimeout / time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := db.AbandonIdleQueueEntries(ctx, idleSeconds); err != nil {
+				log.Println("Error abandoning idle queue entries:", err)
+			}
+			admitted, err := db.AdmitFromQueue(ctx, database.AdmitFromQueueParams{
+				AdmissionSeconds: int64(room.AdmissionTTL / time.Second),
+				IdleSeconds:      idleSeconds,
+				PerMinute:        int64(room.PerMinute),
+			})
+			if err != nil {
+				log.Println("Error admitting from the waiting room:", err)
+				continue
+			}
+			if admitted > 0 {
+				log.Printf("Admitted %d users from the waiting room", admitted)
+			}
+			if _, err := db.DeleteFinishedQueueEntries(ctx); err != nil {
+				log.Println("Error deleting finished queue entries:", err)
+			}
+		}
+	}
+}
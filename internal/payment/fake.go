@@ -0,0 +1,198 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Test card numbers the fake gateway understands. Any other non-empty source is
+// authorized and captured straight away.
+const (
+	FakeCardDeclined      = "4000000000000002" // declined at authorization
+	FakeCardCaptureFails  = "4000000000000341" // authorized, but capture fails
+	FakeCardAsyncCaptured = "4000000000003220" // capture completes later by webhook
+)
+
+// Fake is an in-process gateway for development and tests. It keeps payments in
+// memory and signs its webhooks like a real provider would.
+type Fake struct {
+	secret []byte
+	// WebhookURL receives the outcome of asynchronous captures. If empty they are
+	// captured synchronously instead.
+	WebhookURL string
+	// AsyncDelay is how long an asynchronous capture takes
+	AsyncDelay time.Duration
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	paymentID string
+	source    string
+	amount    int64
+	refunded  int64
+	status    Status
+	// refunds made, by the caller's refund key
+	refunds map[string]int64
+}
+
+// NewFake returns a fake gateway signing webhooks with secret
+func NewFake(secret []byte) *Fake {
+	return &Fake{
+		secret:     secret,
+		AsyncDelay: 2 * time.Second,
+		payments:   make(map[string]*fakePayment),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Authorize(ctx context.Context, req Request) (Result, error) {
+	if req.Source == "" {
+		return Result{Status: Failed, Reason: "missing payment details"}, nil
+	}
+	if req.Amount < 0 {
+		return Result{Status: Failed, Reason: "invalid amount"}, nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return Result{}, err
+	}
+	ref := "fake_" + hex.EncodeToString(buf)
+
+	p := &fakePayment{paymentID: req.PaymentID, source: req.Source, amount: req.Amount, status: Authorized}
+	if req.Source == FakeCardDeclined {
+		p.status = Failed
+	}
+
+	f.mu.Lock()
+	f.payments[ref] = p
+	f.mu.Unlock()
+
+	if p.status == Failed {
+		return Result{Ref: ref, Status: Failed, Reason: "card declined"}, nil
+	}
+	return Result{Ref: ref, Status: Authorized}, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, ref string, amount int64) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[ref]
+	if !ok || p.status != Authorized {
+		return Result{Ref: ref, Status: Failed, Reason: "payment is not authorized"}, nil
+	}
+	if amount > p.amount {
+		return Result{Ref: ref, Status: Failed, Reason: "capture exceeds authorized amount"}, nil
+	}
+
+	switch {
+	case p.source == FakeCardCaptureFails:
+		p.status = Failed
+		return Result{Ref: ref, Status: Failed, Reason: "capture failed"}, nil
+	case p.source == FakeCardAsyncCaptured && f.WebhookURL != "":
+		p.status = Pending
+		go f.completeLater(ref, p.paymentID)
+		return Result{Ref: ref, Status: Pending}, nil
+	}
+	p.amount = amount
+	p.status = Captured
+	return Result{Ref: ref, Status: Captured}, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, ref, key string, amount int64) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[ref]
+	if !ok || p.status != Captured {
+		return Result{Ref: ref, Status: Failed, Reason: "payment is not captured"}, nil
+	}
+	if made, ok := p.refunds[key]; ok {
+		if made != amount {
+			return Result{Ref: ref, Status: Failed, Reason: "refund key reused for a different amount"}, nil
+		}
+		return Result{Ref: ref, Status: Refunded}, nil
+	}
+	if p.refunded+amount > p.amount {
+		return Result{Ref: ref, Status: Failed, Reason: "refund exceeds captured amount"}, nil
+	}
+	if p.refunds == nil {
+		p.refunds = make(map[string]int64)
+	}
+	p.refunds[key] = amount
+	p.refunded += amount
+	return Result{Ref: ref, Status: Refunded}, nil
+}
+
+// Refunded is how much of a payment the fake has given back
+func (f *Fake) Refunded(ref string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if p, ok := f.payments[ref]; ok {
+		return p.refunded
+	}
+	return 0
+}
+
+func (f *Fake) ParseWebhook(body []byte, signature string) (Event, error) {
+	if err := VerifyWebhook(f.secret, body, signature, time.Now()); err != nil {
+		return Event{}, err
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, fmt.Errorf("decoding webhook: %w", err)
+	}
+	return event, nil
+}
+
+// Webhook builds a signed webhook for an event, as the gateway would send it
+func (f *Fake) Webhook(event Event) (body []byte, signature string, err error) {
+	body, err = json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, SignWebhook(f.secret, body, time.Now()), nil
+}
+
+// completeLater captures an asynchronous payment and posts the outcome to WebhookURL
+func (f *Fake) completeLater(ref, paymentID string) {
+	time.Sleep(f.AsyncDelay)
+
+	f.mu.Lock()
+	f.payments[ref].status = Captured
+	f.mu.Unlock()
+
+	body, signature, err := f.Webhook(Event{PaymentID: paymentID, Ref: ref, Status: Captured})
+	if err != nil {
+		log.Println("Fake gateway: error building webhook:", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, f.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Println("Fake gateway: error building webhook request:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Println("Fake gateway: error delivering webhook:", err)
+		return
+	}
+	resp.Body.Close()
+}
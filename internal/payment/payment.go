@@ -0,0 +1,127 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is where a payment is in its lifecycle
+type Status string
+
+const (
+	Pending    Status = "pending"
+	Authorized Status = "authorized"
+	Captured   Status = "captured"
+	Failed     Status = "failed"
+	Refunded   Status = "refunded"
+)
+
+// transitions lists the statuses a payment may move to from each status
+var transitions = map[Status][]Status{
+	Pending:    {Authorized, Captured, Failed},
+	Authorized: {Captured, Failed},
+	Captured:   {Refunded},
+}
+
+// CanTransition reports whether a payment may move from one status to another
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Request asks a provider to authorize a charge. Source is the provider's opaque
+// payment method, such as a card token; it is never stored.
+type Request struct {
+	PaymentID string
+	Amount    int64
+	Currency  string
+	Source    string
+}
+
+// Result is a provider's answer to a request. A declined payment is a Failed result
+// with a Reason rather than an error; errors mean the provider couldn't be reached.
+type Result struct {
+	Ref    string
+	Status Status
+	Reason string
+}
+
+// Event is a provider's webhook notification that a payment changed status
+type Event struct {
+	PaymentID string `json:"payment_id"`
+	Ref       string `json:"ref"`
+	Status    Status `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Provider is a payment gateway. Capture may return Pending, in which case the
+// outcome arrives later as a webhook. Refund is idempotent on key: asking again with a
+// key the provider has already refunded returns that refund rather than making another.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req Request) (Result, error)
+	Capture(ctx context.Context, ref string, amount int64) (Result, error)
+	Refund(ctx context.Context, ref, key string, amount int64) (Result, error)
+	// ParseWebhook verifies a webhook's signature header and decodes its event
+	ParseWebhook(body []byte, signature string) (Event, error)
+}
+
+// SignatureHeader carries a webhook's signature
+const SignatureHeader = "X-Payment-Signature"
+
+// WebhookTolerance is how old a webhook signature may be before it is refused as a replay
+const WebhookTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// SignWebhook signs a webhook body, returning the header value "t=<unix>,v1=<hex hmac>".
+// The timestamp is covered by the signature so old webhooks can't be replayed.
+func SignWebhook(secret, body []byte, now time.Time) string {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(webhookMAC(secret, ts, body))
+}
+
+// VerifyWebhook checks a signature made by SignWebhook
+func VerifyWebhook(secret, body []byte, signature string, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, webhookMAC(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	return nil
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
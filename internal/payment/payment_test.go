@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("whsec")
+	body := []byte(`{"payment_id":"p1","ref":"r1","status":"captured"}`)
+	now := time.Unix(1790000000, 0)
+	sig := SignWebhook(secret, body, now)
+
+	if err := VerifyWebhook(secret, body, sig, now.Add(time.Minute)); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		body   []byte
+		sig    string
+		now    time.Time
+	}{
+		{"wrong secret", []byte("other"), body, sig, now},
+		{"tampered body", secret, []byte(`{"payment_id":"p1","ref":"r1","status":"failed"}`), sig, now},
+		{"replayed", secret, body, sig, now.Add(WebhookTolerance + time.Second)},
+		{"garbage", secret, body, "nonsense", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(tt.secret, tt.body, tt.sig, tt.now)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("got %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestFakeGateway(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake([]byte("whsec"))
+
+	res, err := fake.Authorize(ctx, Request{PaymentID: "p1", Amount: 5000, Currency: "INR", Source: "4242424242424242"})
+	if err != nil || res.Status != Authorized {
+		t.Fatalf("authorize = %+v, %v", res, err)
+	}
+	if res, _ := fake.Capture(ctx, res.Ref, 5000); res.Status != Captured {
+		t.Fatalf("capture = %+v", res)
+	}
+	if res, _ := fake.Refund(ctx, res.Ref, "p1-big", 6000); res.Status != Failed {
+		t.Errorf("refund above captured amount = %+v", res)
+	}
+	if res, _ := fake.Refund(ctx, res.Ref, "p1-0", 3000); res.Status != Refunded {
+		t.Errorf("refund = %+v", res)
+	}
+	if res, _ := fake.Refund(ctx, res.Ref, "p1-0", 3000); res.Status != Refunded || fake.Refunded(res.Ref) != 3000 {
+		t.Errorf("repeated refund = %+v, refunded %d, want the first refund only", res, fake.Refunded(res.Ref))
+	}
+
+	if res, _ := fake.Authorize(ctx, Request{PaymentID: "p2", Amount: 5000, Source: FakeCardDeclined}); res.Status != Failed {
+		t.Errorf("declined card authorized: %+v", res)
+	}
+
+	res, _ = fake.Authorize(ctx, Request{PaymentID: "p3", Amount: 5000, Source: FakeCardCaptureFails})
+	if res, _ := fake.Capture(ctx, res.Ref, 5000); res.Status != Failed {
+		t.Errorf("capture should fail: %+v", res)
+	}
+
+	body, sig, err := fake.Webhook(Event{PaymentID: "p1", Ref: "r1", Status: Captured})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := fake.ParseWebhook(body, sig)
+	if err != nil || event.PaymentID != "p1" || event.Status != Captured {
+		t.Errorf("ParseWebhook = %+v, %v", event, err)
+	}
+	if _, err := fake.ParseWebhook(body, SignWebhook([]byte("forged"), body, time.Now())); err == nil {
+		t.Error("forged webhook accepted")
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	if !CanTransition(Authorized, Captured) || !CanTransition(Captured, Refunded) {
+		t.Error("expected capture and refund to be allowed")
+	}
+	if CanTransition(Failed, Captured) || CanTransition(Refunded, Captured) || CanTransition(Pending, Refunded) {
+		t.Error("expected settled payments to stay settled")
+	}
+}
@@ -0,0 +1,184 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultClass is the travel class priced when a seat carries no class of its own
+const DefaultClass = "second"
+
+// Rules configures how fares are calculated. All amounts are in the currency's
+// minor unit (paise for INR).
+type Rules struct {
+	Currency    string               `json:"currency"`
+	MinimumFare int64                `json:"minimum_fare"`
+	Classes     map[string]ClassFare `json:"classes"`
+	Peak        []PeakWindow         `json:"peak"`
+	// OffPeakDiscountPercent applies to departures outside every peak window
+	OffPeakDiscountPercent int64          `json:"off_peak_discount_percent"`
+	Dynamic                DynamicPricing `json:"dynamic"`
+	// Location is the time zone peak hours and weekdays are read in; nil means UTC
+	Location *time.Location `json:"-"`
+}
+
+// ClassFare is the base fare and per-kilometre rate of a travel class
+type ClassFare struct {
+	Base  int64 `json:"base"`
+	PerKm int64 `json:"per_km"`
+}
+
+// PeakWindow marks departure hours [StartHour, EndHour) on the given weekdays as peak.
+// An empty Weekdays list matches every day.
+type PeakWindow struct {
+	Weekdays         []time.Weekday `json:"weekdays"`
+	StartHour        int            `json:"start_hour"`
+	EndHour          int            `json:"end_hour"`
+	SurchargePercent int64          `json:"surcharge_percent"`
+}
+
+// DynamicPricing raises fares as a departure fills up
+type DynamicPricing struct {
+	Enabled bool            `json:"enabled"`
+	Steps   []OccupancyStep `json:"steps"`
+}
+
+// OccupancyStep applies SurchargePercent once occupancy reaches MinOccupancyPercent
+type OccupancyStep struct {
+	MinOccupancyPercent int64 `json:"min_occupancy_percent"`
+	SurchargePercent    int64 `json:"surcharge_percent"`
+}
+
+// Input describes the journey being priced
+type Input struct {
+	Class          string
+	DistanceKm     int64
+	DepartsAt      time.Time
+	TotalSeats     int64
+	AvailableSeats int64
+}
+
+// Quote is a priced journey broken down by component
+type Quote struct {
+	Class    string
+	Currency string
+	Base     int64
+	Distance int64
+	Peak     int64 // negative for an off-peak discount
+	Dynamic  int64
+	Total    int64
+}
+
+// DefaultRules returns the fares used when FARE_RULES_FILE is not configured
+func DefaultRules() *Rules {
+	return &Rules{
+		Currency:    "INR",
+		MinimumFare: 5000,
+		Classes: map[string]ClassFare{
+			"second":   {Base: 3000, PerKm: 50},
+			"ac_chair": {Base: 10000, PerKm: 150},
+			"sleeper":  {Base: 8000, PerKm: 100},
+		},
+		Peak: []PeakWindow{
+			{Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, StartHour: 7, EndHour: 10, SurchargePercent: 20},
+			{Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, StartHour: 17, EndHour: 20, SurchargePercent: 20},
+		},
+		OffPeakDiscountPercent: 10,
+		Dynamic: DynamicPricing{
+			Enabled: false,
+			Steps: []OccupancyStep{
+				{MinOccupancyPercent: 50, SurchargePercent: 10},
+				{MinOccupancyPercent: 80, SurchargePercent: 25},
+			},
+		},
+	}
+}
+
+// LoadRules reads fare rules from a JSON file
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing fare rules: %w", err)
+	}
+	if rules.Currency == "" {
+		rules.Currency = "INR"
+	}
+	return &rules, nil
+}
+
+// Quote prices a journey. Unknown classes are priced as DefaultClass.
+func (r *Rules) Quote(in Input) Quote {
+	class, ok := r.Classes[in.Class]
+	if !ok {
+		class = r.Classes[DefaultClass]
+		in.Class = DefaultClass
+	}
+
+	q := Quote{
+		Class:    in.Class,
+		Currency: r.Currency,
+		Base:     class.Base,
+		Distance: class.PerKm * in.DistanceKm,
+	}
+	subtotal := q.Base + q.Distance
+
+	if window, ok := r.peakWindow(in.DepartsAt); ok {
+		q.Peak = subtotal * window.SurchargePercent / 100
+	} else {
+		q.Peak = -subtotal * r.OffPeakDiscountPercent / 100
+	}
+
+	if r.Dynamic.Enabled && in.TotalSeats > 0 {
+		occupancy := (in.TotalSeats - in.AvailableSeats) * 100 / in.TotalSeats
+		var surcharge int64
+		for _, step := range r.Dynamic.Steps {
+			if occupancy >= step.MinOccupancyPercent && step.SurchargePercent > surcharge {
+				surcharge = step.SurchargePercent
+			}
+		}
+		q.Dynamic = subtotal * surcharge / 100
+	}
+
+	q.Total = subtotal + q.Peak + q.Dynamic
+	if q.Total < r.MinimumFare {
+		q.Total = r.MinimumFare
+	}
+	return q
+}
+
+func (r *Rules) peakWindow(departsAt time.Time) (PeakWindow, bool) {
+	if r.Location != nil {
+		departsAt = departsAt.In(r.Location)
+	}
+	hour := departsAt.Hour()
+	for _, window := range r.Peak {
+		if hour < window.StartHour || hour >= window.EndHour {
+			continue
+		}
+		if len(window.Weekdays) == 0 {
+			return window, true
+		}
+		for _, day := range window.Weekdays {
+			if day == departsAt.Weekday() {
+				return window, true
+			}
+		}
+	}
+	return PeakWindow{}, false
+}
+
+// FormatAmount renders a minor-unit amount for display, e.g. "INR 123.45"
+func FormatAmount(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s %s%d.%02d", currency, sign, amount/100, amount%100)
+}
@@ -0,0 +1,74 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	rules := DefaultRules()
+	// 2026-10-19 is a Monday
+	peak := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	offPeak := time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rules func(*Rules)
+		in    Input
+		want  Quote
+	}{
+		{
+			name: "peak second class",
+			in:   Input{Class: "second", DistanceKm: 100, DepartsAt: peak, TotalSeats: 50, AvailableSeats: 50},
+			want: Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 5000, Peak: 1600, Total: 9600},
+		},
+		{
+			name: "off-peak discount",
+			in:   Input{Class: "second", DistanceKm: 100, DepartsAt: offPeak, TotalSeats: 50, AvailableSeats: 50},
+			want: Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 5000, Peak: -800, Total: 7200},
+		},
+		{
+			name: "unknown class falls back to default",
+			in:   Input{Class: "first", DistanceKm: 100, DepartsAt: peak, TotalSeats: 50, AvailableSeats: 50},
+			want: Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 5000, Peak: 1600, Total: 9600},
+		},
+		{
+			name: "minimum fare",
+			in:   Input{Class: "second", DistanceKm: 10, DepartsAt: offPeak, TotalSeats: 50, AvailableSeats: 50},
+			want: Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 500, Peak: -350, Total: 5000},
+		},
+		{
+			name:  "dynamic surcharge uses highest reached step",
+			rules: func(r *Rules) { r.Dynamic.Enabled = true },
+			in:    Input{Class: "second", DistanceKm: 100, DepartsAt: offPeak, TotalSeats: 50, AvailableSeats: 5},
+			want:  Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 5000, Peak: -800, Dynamic: 2000, Total: 9200},
+		},
+		{
+			name:  "peak hours follow the operator's clock",
+			rules: func(r *Rules) { r.Location = time.FixedZone("IST", 5*3600+1800) },
+			in:    Input{Class: "second", DistanceKm: 100, DepartsAt: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC), TotalSeats: 50, AvailableSeats: 50},
+			want:  Quote{Class: "second", Currency: "INR", Base: 3000, Distance: 5000, Peak: 1600, Total: 9600},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *rules
+			if tt.rules != nil {
+				tt.rules(&r)
+			}
+			if got := r.Quote(tt.in); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	if got := FormatAmount(12345, "INR"); got != "INR 123.45" {
+		t.Errorf("expected 'INR 123.45', got %s", got)
+	}
+	if got := FormatAmount(-5, "INR"); got != "INR -0.05" {
+		t.Errorf("expected 'INR -0.05', got %s", got)
+	}
+}
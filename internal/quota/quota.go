@@ -0,0 +1,81 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Category is a quota seats can be reserved for. Seats in no quota are General.
+type Category string
+
+const (
+	General   Category = "general"
+	Senior    Category = "senior"
+	Women     Category = "women"
+	Disabled  Category = "disabled"
+	Emergency Category = "emergency"
+)
+
+// Reserved are the categories a departure can hold seats back for
+var Reserved = []Category{Senior, Women, Disabled, Emergency}
+
+// SeniorAge is the youngest a passenger can be to book in the senior quota
+const SeniorAge = 60
+
+// DefaultReleaseHours is how long before departure a quota's unsold seats return to
+// general sale when no cutoff is given
+const DefaultReleaseHours = 24
+
+// Passenger is what eligibility is judged on
+type Passenger struct {
+	Age    int64
+	Gender string
+	// ConcessionRef is the certificate or authorisation reference some categories need
+	ConcessionRef string
+}
+
+// Parse reads a category from a form or request; empty is General
+func Parse(s string) (Category, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == string(General) {
+		return General, nil
+	}
+	for _, c := range Reserved {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown quota %q", s)
+}
+
+// Eligible checks a passenger may book a seat in the quota. Disabled passengers give
+// their disability certificate number and emergency bookings the reference of the
+// authorisation for them.
+func (c Category) Eligible(p Passenger) error {
+	switch c {
+	case General:
+		return nil
+	case Senior:
+		if p.Age < SeniorAge {
+			return fmt.Errorf("the senior quota is for passengers aged %d or over", SeniorAge)
+		}
+		return nil
+	case Women:
+		if p.Gender != "female" {
+			return errors.New("the women's quota is for female passengers")
+		}
+		return nil
+	case Disabled:
+		if strings.TrimSpace(p.ConcessionRef) == "" {
+			return errors.New("the disabled quota needs a disability certificate number")
+		}
+		return nil
+	case Emergency:
+		if strings.TrimSpace(p.ConcessionRef) == "" {
+			return errors.New("the emergency quota needs an authorisation reference")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown quota %q", string(c))
+}
@@ -0,0 +1,44 @@
+package quota
+
+import "testing"
+
+func TestEligible(t *testing.T) {
+	tests := []struct {
+		name      string
+		category  Category
+		passenger Passenger
+		eligible  bool
+	}{
+		{"anyone books general", General, Passenger{Age: 30, Gender: "male"}, true},
+		{"senior at the age limit", Senior, Passenger{Age: SeniorAge, Gender: "male"}, true},
+		{"senior too young", Senior, Passenger{Age: SeniorAge - 1, Gender: "female"}, false},
+		{"women's quota", Women, Passenger{Age: 30, Gender: "female"}, true},
+		{"women's quota for a man", Women, Passenger{Age: 30, Gender: "male"}, false},
+		{"disabled with certificate", Disabled, Passenger{Age: 30, ConcessionRef: "DC-123"}, true},
+		{"disabled without certificate", Disabled, Passenger{Age: 30, ConcessionRef: "  "}, false},
+		{"emergency with authorisation", Emergency, Passenger{Age: 40, ConcessionRef: "EQ-9"}, true},
+		{"emergency without authorisation", Emergency, Passenger{Age: 40}, false},
+		{"unknown category", Category("vip"), Passenger{Age: 40}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.category.Eligible(tt.passenger)
+			if (err == nil) != tt.eligible {
+				t.Errorf("Eligible() = %v, want eligible %v", err, tt.eligible)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Category{"": General, "general": General, "senior": Senior, " women ": Women} {
+		got, err := Parse(in)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := Parse("vip"); err == nil {
+		t.Error("Parse accepted an unknown quota")
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter allows each key a fixed number of attempts per sliding window. It is
+// in-memory, so the limit is per node: behind a load balancer spreading a client
+// over n nodes, that client gets up to n times the limit. Keys that have gone quiet
+// stay in memory until Sweep forgets them.
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// New returns a limiter allowing limit attempts per key in any window
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow records an attempt for key and reports whether it is within the limit
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+	recent := l.hits[key][:0]
+	for _, t := range l.hits[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.limit {
+		l.hits[key] = recent
+		return false
+	}
+	l.hits[key] = append(recent, now)
+	return true
+}
+
+// Sweep forgets keys with no attempts left in the window, so the map doesn't grow
+// without bound, and returns how many it forgot
+func (l *Limiter) Sweep() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := l.now().Add(-l.window)
+	forgotten := 0
+	for k, ts := range l.hits {
+		if len(ts) == 0 || !ts[len(ts)-1].After(cutoff) {
+			delete(l.hits, k)
+			forgotten++
+		}
+	}
+	return forgotten
+}
+
+// ClientIP is the key used to rate limit a request by its remote address
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
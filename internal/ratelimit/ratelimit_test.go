@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("first two attempts should be allowed")
+	}
+	if l.Allow("a") {
+		t.Fatal("third attempt within the window should be refused")
+	}
+	if !l.Allow("b") {
+		t.Fatal("other keys should have their own budget")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if !l.Allow("a") {
+		t.Fatal("attempts should be allowed again once the window has passed")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(30 * time.Second)
+	l.Allow("b")
+
+	now = now.Add(45 * time.Second)
+	if n := l.Sweep(); n != 1 {
+		t.Fatalf("swept %d keys, want only the quiet one", n)
+	}
+	if _, ok := l.hits["a"]; ok {
+		t.Error("quiet key is still held")
+	}
+	if _, ok := l.hits["b"]; !ok {
+		t.Error("key with a recent attempt was forgotten")
+	}
+}
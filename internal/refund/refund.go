@@ -0,0 +1,115 @@
+package refund
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Policy configures how much of a fare is returned on cancellation. All amounts
+// are in the currency's minor unit, like fares.
+type Policy struct {
+	// Tiers refund a percentage of the fare by how long before departure the
+	// ticket is cancelled. The tier with the largest MinHoursBefore not exceeding
+	// the notice given applies; with no matching tier nothing is refunded.
+	Tiers []Tier `json:"tiers"`
+	// CancellationFee is deducted from every refund
+	CancellationFee int64 `json:"cancellation_fee"`
+	// ClassFees override CancellationFee for particular travel classes
+	ClassFees map[string]int64 `json:"class_fees"`
+	// NonRefundableClasses are never refunded
+	NonRefundableClasses []string `json:"non_refundable_classes"`
+}
+
+// Tier refunds RefundPercent of the fare when cancelling at least MinHoursBefore
+// hours before departure
+type Tier struct {
+	MinHoursBefore int64 `json:"min_hours_before"`
+	RefundPercent  int64 `json:"refund_percent"`
+}
+
+// Input describes the ticket being cancelled
+type Input struct {
+	Class       string
+	FareAmount  int64
+	DepartsAt   time.Time
+	CancelledAt time.Time
+}
+
+// Quote is a refund broken down by component
+type Quote struct {
+	Fare          int64
+	HoursBefore   int64
+	RefundPercent int64
+	Fee           int64
+	Amount        int64 // what is paid back, never negative
+}
+
+// DefaultPolicy returns the policy used when REFUND_POLICY_FILE is not configured
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Tiers: []Tier{
+			{MinHoursBefore: 48, RefundPercent: 100},
+			{MinHoursBefore: 12, RefundPercent: 50},
+			{MinHoursBefore: 4, RefundPercent: 25},
+		},
+		CancellationFee: 2000,
+		ClassFees: map[string]int64{
+			"ac_chair": 4000,
+			"sleeper":  3000,
+		},
+	}
+}
+
+// LoadPolicy reads a refund policy from a JSON file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parsing refund policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Quote works out the refund for cancelling a ticket
+func (p *Policy) Quote(in Input) Quote {
+	q := Quote{Fare: in.FareAmount}
+	notice := in.DepartsAt.Sub(in.CancelledAt)
+	if notice <= 0 {
+		return q
+	}
+	q.HoursBefore = int64(notice / time.Hour)
+
+	for _, class := range p.NonRefundableClasses {
+		if class == in.Class {
+			return q
+		}
+	}
+
+	tiers := append([]Tier(nil), p.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinHoursBefore > tiers[j].MinHoursBefore })
+	for _, tier := range tiers {
+		if q.HoursBefore >= tier.MinHoursBefore {
+			q.RefundPercent = tier.RefundPercent
+			break
+		}
+	}
+	if q.RefundPercent == 0 {
+		return q
+	}
+
+	q.Fee = p.CancellationFee
+	if fee, ok := p.ClassFees[in.Class]; ok {
+		q.Fee = fee
+	}
+	q.Amount = in.FareAmount*q.RefundPercent/100 - q.Fee
+	if q.Amount < 0 {
+		q.Amount = 0
+	}
+	return q
+}
@@ -0,0 +1,61 @@
+package refund
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	departs := time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy func(*Policy)
+		in     Input
+		want   Quote
+	}{
+		{
+			name: "full refund less fee",
+			in:   Input{Class: "second", FareAmount: 10000, DepartsAt: departs, CancelledAt: departs.Add(-72 * time.Hour)},
+			want: Quote{Fare: 10000, HoursBefore: 72, RefundPercent: 100, Fee: 2000, Amount: 8000},
+		},
+		{
+			name: "partial refund with class fee",
+			in:   Input{Class: "ac_chair", FareAmount: 20000, DepartsAt: departs, CancelledAt: departs.Add(-13 * time.Hour)},
+			want: Quote{Fare: 20000, HoursBefore: 13, RefundPercent: 50, Fee: 4000, Amount: 6000},
+		},
+		{
+			name: "fee larger than refund",
+			in:   Input{Class: "second", FareAmount: 5000, DepartsAt: departs, CancelledAt: departs.Add(-5 * time.Hour)},
+			want: Quote{Fare: 5000, HoursBefore: 5, RefundPercent: 25, Fee: 2000, Amount: 0},
+		},
+		{
+			name: "too close to departure",
+			in:   Input{Class: "second", FareAmount: 10000, DepartsAt: departs, CancelledAt: departs.Add(-time.Hour)},
+			want: Quote{Fare: 10000, HoursBefore: 1},
+		},
+		{
+			name: "after departure",
+			in:   Input{Class: "second", FareAmount: 10000, DepartsAt: departs, CancelledAt: departs.Add(time.Hour)},
+			want: Quote{Fare: 10000},
+		},
+		{
+			name:   "non-refundable class",
+			policy: func(p *Policy) { p.NonRefundableClasses = []string{"sleeper"} },
+			in:     Input{Class: "sleeper", FareAmount: 10000, DepartsAt: departs, CancelledAt: departs.Add(-72 * time.Hour)},
+			want:   Quote{Fare: 10000, HoursBefore: 72},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultPolicy()
+			if tt.policy != nil {
+				tt.policy(policy)
+			}
+			if got := policy.Quote(tt.in); got != tt.want {
+				t.Errorf("Quote() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package reports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// MaxRangeDays is the longest date range a report can cover
+const MaxRangeDays = 366
+
+// DefaultRangeDays is how far back a report looks when no range is given
+const DefaultRangeDays = 30
+
+const dateLayout = "2006-01-02"
+
+// instantLayout is how instants are passed to report queries, in UTC
+const instantLayout = "2006-01-02 15:04:05"
+
+// minuteLayout is how report queries write the UTC minute events fell in
+const minuteLayout = "2006-01-02 15:04"
+
+// Table is a report as named columns and rows of plain values, so that the same
+// report renders as a page, a CSV file or JSON
+type Table struct {
+	Name    string
+	Title   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// WriteCSV writes the table with its column names as the header row
+func (t Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) && row[i] != nil {
+				record[i] = fmt.Sprint(row[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Records returns each row as an object keyed by column name, for JSON
+func (t Table) Records() []map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		record := make(map[string]interface{}, len(t.Columns))
+		for i, column := range t.Columns {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ParseRange reads an inclusive range of dates in YYYY-MM-DD form. A missing end is
+// today, the date of now in its location, and a missing start is DefaultRangeDays
+// before the end.
+func ParseRange(from, to string, now time.Time) (string, string, error) {
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if to != "" {
+		t, err := time.Parse(dateLayout, to)
+		if err != nil {
+			return "", "", errors.New("end date must be in YYYY-MM-DD form")
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -DefaultRangeDays+1)
+	if from != "" {
+		t, err := time.Parse(dateLayout, from)
+		if err != nil {
+			return "", "", errors.New("start date must be in YYYY-MM-DD form")
+		}
+		start = t
+	}
+	if end.Before(start) {
+		return "", "", errors.New("end date must not be before the start date")
+	}
+	if end.Sub(start) >= MaxRangeDays*24*time.Hour {
+		return "", "", fmt.Errorf("reports cover at most %d days", MaxRangeDays)
+	}
+	return start.Format(dateLayout), end.Format(dateLayout), nil
+}
+
+// Span is the UTC instants at which the range of dates from ParseRange starts and
+// ends on the operator's calendar in loc, in the form report queries take them
+func Span(from, to string, loc *time.Location) (string, string, error) {
+	start, err := time.ParseInLocation(dateLayout, from, loc)
+	if err != nil {
+		return "", "", err
+	}
+	end, err := time.ParseInLocation(dateLayout, to, loc)
+	if err != nil {
+		return "", "", err
+	}
+	return start.UTC().Format(instantLayout), end.AddDate(0, 0, 1).UTC().Format(instantLayout), nil
+}
+
+// LocalMinute reads a UTC minute written by a report query as the operator's time
+// in loc
+func LocalMinute(minute string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(minuteLayout, minute)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// Load is what was offered and sold on one or more departures
+type Load struct {
+	Departures  int64
+	Seats       int64
+	SeatKm      int64
+	TicketsSold int64
+	PassengerKm int64
+}
+
+// Add counts one more departure of seats over routeKm, with its tickets sold and the
+// kilometres its passengers travel between them
+func (l *Load) Add(seats, routeKm, ticketsSold, passengerKm int64) {
+	l.Departures++
+	l.Seats += seats
+	l.SeatKm += seats * routeKm
+	l.TicketsSold += ticketsSold
+	l.PassengerKm += passengerKm
+}
+
+// Factor is the load factor in percent, rounded to one decimal: passenger kilometres
+// over seat kilometres, or tickets over seats when there is no route length to go by
+func (l Load) Factor() float64 {
+	var f float64
+	switch {
+	case l.SeatKm > 0:
+		f = float64(l.PassengerKm) / float64(l.SeatKm)
+	case l.Seats > 0:
+		f = float64(l.TicketsSold) / float64(l.Seats)
+	}
+	return math.Round(f*1000) / 10
+}
@@ -0,0 +1,108 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2024, 3, 15, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from, to string
+		wantFrom string
+		wantTo   string
+		wantErr  bool
+	}{
+		{"defaults to the last 30 days", "", "", "2024-02-15", "2024-03-15", false},
+		{"given range", "2024-01-01", "2024-01-31", "2024-01-01", "2024-01-31", false},
+		{"single day", "2024-01-01", "2024-01-01", "2024-01-01", "2024-01-01", false},
+		{"end before start", "2024-02-01", "2024-01-31", "", "", true},
+		{"a full leap year", "2024-01-01", "2024-12-31", "2024-01-01", "2024-12-31", false},
+		{"too long", "2023-01-01", "2024-01-02", "", "", true},
+		{"bad date", "01/02/2024", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := ParseRange(tt.from, tt.to, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if from != tt.wantFrom || to != tt.wantTo {
+				t.Errorf("ParseRange() = %s, %s, want %s, %s", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestSpanInOperatorTimeZone(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	start, end, err := Span("2024-03-01", "2024-03-02", ist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != "2024-02-29 18:30:00" || end != "2024-03-02 18:30:00" {
+		t.Errorf("Span() = %s, %s", start, end)
+	}
+
+	// 18:45 UTC is a quarter past midnight the next day in India
+	at, err := LocalMinute("2024-03-01 18:45", ist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at.Format("2006-01-02 15:04") != "2024-03-02 00:15" {
+		t.Errorf("LocalMinute() = %s", at)
+	}
+}
+
+func TestLoadFactor(t *testing.T) {
+	var l Load
+	if got := l.Factor(); got != 0 {
+		t.Errorf("Factor() with nothing offered = %v, want 0", got)
+	}
+
+	// 100 seats over 300 km, half the train travelling the whole way
+	l.Add(100, 300, 50, 15000)
+	if got := l.Factor(); got != 50 {
+		t.Errorf("Factor() = %v, want 50", got)
+	}
+
+	// A second run with a third of the seats sold for a third of the way
+	l.Add(100, 300, 100, 10000)
+	if got := l.Factor(); got != 41.7 {
+		t.Errorf("Factor() over two departures = %v, want 41.7", got)
+	}
+
+	// Without stop distances the factor falls back to tickets over seats
+	noRoute := Load{}
+	noRoute.Add(80, 0, 20, 0)
+	if got := noRoute.Factor(); got != 25 {
+		t.Errorf("Factor() without a route length = %v, want 25", got)
+	}
+}
+
+func TestTableExport(t *testing.T) {
+	table := Table{
+		Columns: []string{"channel", "bookings"},
+		Rows: [][]interface{}{
+			{"web", int64(12)},
+			{"agent, counter", nil},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := table.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "channel,bookings\nweb,12\n\"agent, counter\",\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() = %q, want %q", buf.String(), want)
+	}
+
+	records := table.Records()
+	if len(records) != 2 || records[0]["channel"] != "web" || records[0]["bookings"] != int64(12) {
+		t.Errorf("Records() = %v", records)
+	}
+}
@@ -0,0 +1,101 @@
+package ticketstate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"rsvbackend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// State is where a ticket is in its lifecycle. The values are stored in tickets.status.
+type State string
+
+const (
+	Confirmed State = "confirmed"
+	Cancelled State = "cancelled"
+	CheckedIn State = "checked_in"
+	Boarded   State = "boarded"
+	NoShow    State = "no_show"
+	Refunded  State = "refunded"
+)
+
+// All lists every state in lifecycle order
+var All = []State{Confirmed, CheckedIn, Boarded, NoShow, Cancelled, Refunded}
+
+// transitions lists the states each state may move to
+var transitions = map[State][]State{
+	Confirmed: {CheckedIn, NoShow, Cancelled},
+	CheckedIn: {Boarded, NoShow},
+	Cancelled: {Refunded},
+}
+
+// ErrInvalidTransition is returned for a move the lifecycle doesn't allow
+var ErrInvalidTransition = errors.New("invalid ticket state transition")
+
+// Valid reports whether s is a known state
+func Valid(s State) bool {
+	for _, state := range All {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a ticket may move from one state to another
+func CanTransition(from, to State) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is a requested move of a ticket between states
+type Change struct {
+	TicketID string
+	From     State
+	To       State
+	Actor    string // user or staff ID, or "system"
+	Reason   string
+}
+
+// Transition moves a ticket from one state to another and records it in the
+// ticket's history. sql.ErrNoRows means the ticket was not in the From state.
+func Transition(ctx context.Context, q database.QueriesInterface, c Change) (database.TransitionTicketRow, error) {
+	if !CanTransition(c.From, c.To) {
+		return database.TransitionTicketRow{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, c.From, c.To)
+	}
+
+	row, err := q.TransitionTicket(ctx, database.TransitionTicketParams{
+		ID:         c.TicketID,
+		FromStatus: string(c.From),
+		ToStatus:   string(c.To),
+	})
+	if err != nil {
+		return database.TransitionTicketRow{}, err
+	}
+
+	err = record(ctx, q, c.TicketID, sql.NullString{String: string(c.From), Valid: true}, c.To, c.Actor, c.Reason)
+	return row, err
+}
+
+// Issue records the state a newly created ticket starts in
+func Issue(ctx context.Context, q database.QueriesInterface, ticketID string, state State, actor, reason string) error {
+	return record(ctx, q, ticketID, sql.NullString{}, state, actor, reason)
+}
+
+func record(ctx context.Context, q database.QueriesInterface, ticketID string, from sql.NullString, to State, actor, reason string) error {
+	return q.CreateTicketTransition(ctx, database.CreateTicketTransitionParams{
+		ID:        uuid.New().String(),
+		TicketID:  ticketID,
+		FromState: from,
+		ToState:   string(to),
+		Actor:     actor,
+		Reason:    reason,
+	})
+}
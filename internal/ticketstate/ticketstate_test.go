@@ -0,0 +1,29 @@
+package ticketstate
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		want     bool
+	}{
+		{Confirmed, CheckedIn, true},
+		{Confirmed, Cancelled, true},
+		{Confirmed, NoShow, true},
+		{CheckedIn, Boarded, true},
+		{Cancelled, Refunded, true},
+		{Confirmed, Boarded, false},
+		{Confirmed, Refunded, false},
+		{CheckedIn, Cancelled, false},
+		{Boarded, Cancelled, false},
+		{Cancelled, Confirmed, false},
+		{Refunded, Cancelled, false},
+		{NoShow, CheckedIn, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
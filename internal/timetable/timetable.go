@@ -0,0 +1,190 @@
+package timetable
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHorizonDays is how many days ahead departures are generated when
+// TIMETABLE_HORIZON_DAYS is not configured
+const DefaultHorizonDays = 60
+
+const dateLayout = "2006-01-02"
+
+// Frequency is how often a rule runs
+type Frequency string
+
+const (
+	Daily    Frequency = "daily"
+	Weekdays Frequency = "weekdays"
+	// Days runs on the weekdays listed in the rule
+	Days Frequency = "days"
+)
+
+// Rule is a recurring timetable entry: a train leaving its first stop at the same
+// time of day on the days the rule runs. Dates, weekdays and the departure time are
+// the operator's wall clock in Location.
+type Rule struct {
+	Frequency Frequency
+	Days      []time.Weekday
+	// DepartureTime is how long after midnight the train leaves
+	DepartureTime time.Duration
+	ValidFrom     time.Time
+	// ValidUntil is the last day the rule runs; zero means it runs indefinitely
+	ValidUntil time.Time
+	// Exceptions are dates the rule doesn't run, keyed by YYYY-MM-DD
+	Exceptions map[string]bool
+	// Location is the operator's time zone; nil means UTC
+	Location *time.Location
+}
+
+// Departure is one run a rule generates
+type Departure struct {
+	ServiceDate string    `json:"service_date"`
+	DepartsAt   time.Time `json:"departs_at"`
+	ArrivesAt   time.Time `json:"arrives_at"`
+}
+
+// Parse builds a rule from its stored form: days of week as a comma-separated list
+// with 0 for Sunday, the departure time as HH:MM and dates as YYYY-MM-DD. An empty
+// validUntil leaves the rule open-ended.
+func Parse(frequency, daysOfWeek, departureTime, validFrom, validUntil string, exceptions []string) (Rule, error) {
+	r := Rule{Frequency: Frequency(frequency), Exceptions: make(map[string]bool)}
+
+	switch r.Frequency {
+	case Daily, Weekdays:
+	case Days:
+		days, err := ParseDays(daysOfWeek)
+		if err != nil {
+			return r, err
+		}
+		if len(days) == 0 {
+			return r, errors.New("days of week are required for a days rule")
+		}
+		r.Days = days
+	default:
+		return r, fmt.Errorf("frequency must be daily, weekdays or days, not %q", frequency)
+	}
+
+	t, err := time.Parse("15:04", departureTime)
+	if err != nil {
+		return r, errors.New("departure time must be HH:MM")
+	}
+	r.DepartureTime = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	r.ValidFrom, err = time.Parse(dateLayout, validFrom)
+	if err != nil {
+		return r, errors.New("valid from must be a YYYY-MM-DD date")
+	}
+	if validUntil != "" {
+		r.ValidUntil, err = time.Parse(dateLayout, validUntil)
+		if err != nil {
+			return r, errors.New("valid until must be a YYYY-MM-DD date")
+		}
+		if r.ValidUntil.Before(r.ValidFrom) {
+			return r, errors.New("valid until is before valid from")
+		}
+	}
+
+	for _, date := range exceptions {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return r, fmt.Errorf("invalid exception date %q", date)
+		}
+		r.Exceptions[date] = true
+	}
+	return r, nil
+}
+
+// ParseDays reads a comma-separated list of weekdays, 0 being Sunday
+func ParseDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 6 {
+			return nil, fmt.Errorf("invalid day of week %q", part)
+		}
+		if !seen[time.Weekday(n)] {
+			seen[time.Weekday(n)] = true
+			days = append(days, time.Weekday(n))
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days, nil
+}
+
+// FormatDays is the stored form of a list of weekdays
+func FormatDays(days []time.Weekday) string {
+	parts := make([]string, len(days))
+	for i, d := range days {
+		parts[i] = strconv.Itoa(int(d))
+	}
+	return strings.Join(parts, ",")
+}
+
+// RunsOn reports whether the rule has a departure on the operator's calendar date
+// that date falls on
+func (r Rule) RunsOn(date time.Time) bool {
+	local := date.In(r.location())
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(r.ValidFrom) || (!r.ValidUntil.IsZero() && day.After(r.ValidUntil)) {
+		return false
+	}
+	if r.Exceptions[day.Format(dateLayout)] {
+		return false
+	}
+
+	switch r.Frequency {
+	case Daily:
+		return true
+	case Weekdays:
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case Days:
+		for _, d := range r.Days {
+			if d == day.Weekday() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Generate lists the departures the rule makes over the given number of days starting
+// on from's date, for a train that takes runTime from its first stop to its last.
+// Departure times are returned in UTC.
+func (r Rule) Generate(from time.Time, days int, runTime time.Duration) []Departure {
+	loc := r.location()
+	start := from.In(loc)
+	hour, minute := int(r.DepartureTime/time.Hour), int(r.DepartureTime%time.Hour/time.Minute)
+	var departures []Departure
+	for i := 0; i < days; i++ {
+		day := time.Date(start.Year(), start.Month(), start.Day()+i, 0, 0, 0, 0, loc)
+		if !r.RunsOn(day) {
+			continue
+		}
+		// Built from the wall clock rather than by adding to midnight so a change of
+		// clocks that day doesn't move the departure
+		departs := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc).UTC()
+		departures = append(departures, Departure{
+			ServiceDate: day.Format(dateLayout),
+			DepartsAt:   departs,
+			ArrivesAt:   departs.Add(runTime),
+		})
+	}
+	return departures
+}
+
+func (r Rule) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+	return r.Location
+}
@@ -0,0 +1,118 @@
+package timetable
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	// 2026-10-19 is a Monday
+	from := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		frequency  string
+		days       string
+		validFrom  string
+		validUntil string
+		exceptions []string
+		want       []string
+	}{
+		{
+			name:      "daily",
+			frequency: "daily",
+			validFrom: "2026-01-01",
+			want:      []string{"2026-10-19", "2026-10-20", "2026-10-21", "2026-10-22", "2026-10-23", "2026-10-24", "2026-10-25"},
+		},
+		{
+			name:      "weekdays",
+			frequency: "weekdays",
+			validFrom: "2026-01-01",
+			want:      []string{"2026-10-19", "2026-10-20", "2026-10-21", "2026-10-22", "2026-10-23"},
+		},
+		{
+			name:      "specific days",
+			frequency: "days",
+			days:      "0,3",
+			validFrom: "2026-01-01",
+			want:      []string{"2026-10-21", "2026-10-25"},
+		},
+		{
+			name:       "exceptions and validity",
+			frequency:  "daily",
+			validFrom:  "2026-10-20",
+			validUntil: "2026-10-23",
+			exceptions: []string{"2026-10-21"},
+			want:       []string{"2026-10-20", "2026-10-22", "2026-10-23"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.frequency, tt.days, "08:15", tt.validFrom, tt.validUntil, tt.exceptions)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := rule.Generate(from, 7, 6*time.Hour)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Generate() made %d departures, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, d := range got {
+				if d.ServiceDate != tt.want[i] {
+					t.Errorf("departure %d on %s, want %s", i, d.ServiceDate, tt.want[i])
+				}
+				if d.DepartsAt.Format("2006-01-02 15:04") != tt.want[i]+" 08:15" {
+					t.Errorf("departure %d leaves at %s", i, d.DepartsAt)
+				}
+				if d.ArrivesAt.Sub(d.DepartsAt) != 6*time.Hour {
+					t.Errorf("departure %d arrives at %s", i, d.ArrivesAt)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name                               string
+		frequency, days, time, from, until string
+	}{
+		{"unknown frequency", "hourly", "", "08:00", "2026-01-01", ""},
+		{"days without days", "days", "", "08:00", "2026-01-01", ""},
+		{"bad day", "days", "7", "08:00", "2026-01-01", ""},
+		{"bad time", "daily", "", "8am", "2026-01-01", ""},
+		{"bad date", "daily", "", "08:00", "01/01/2026", ""},
+		{"ends before it starts", "daily", "", "08:00", "2026-02-01", "2026-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.frequency, tt.days, tt.time, tt.from, tt.until, nil); err == nil {
+				t.Error("Parse() accepted an invalid rule")
+			}
+		})
+	}
+}
+
+func TestGenerateInOperatorTimeZone(t *testing.T) {
+	// Just after midnight on a Monday in India is still Sunday in UTC
+	rule, err := Parse("weekdays", "", "00:30", "2026-10-19", "", []string{"2026-10-20"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	rule.Location = time.FixedZone("IST", 5*3600+1800)
+
+	got := rule.Generate(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), 4, time.Hour)
+	want := []Departure{
+		{ServiceDate: "2026-10-19", DepartsAt: time.Date(2026, 10, 18, 19, 0, 0, 0, time.UTC)},
+		{ServiceDate: "2026-10-21", DepartsAt: time.Date(2026, 10, 20, 19, 0, 0, 0, time.UTC)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Generate() made %d departures, want %d: %+v", len(got), len(want), got)
+	}
+	for i, d := range got {
+		if d.ServiceDate != want[i].ServiceDate || !d.DepartsAt.Equal(want[i].DepartsAt) {
+			t.Errorf("departure %d = %s at %s, want %s at %s", i, d.ServiceDate, d.DepartsAt, want[i].ServiceDate, want[i].DepartsAt)
+		}
+	}
+}
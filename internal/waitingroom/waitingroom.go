@@ -0,0 +1,97 @@
+package waitingroom
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Defaults used when WAITING_ROOM_ADMISSION_TTL and WAITING_ROOM_IDLE_TIMEOUT are
+// not configured
+const (
+	DefaultAdmissionTTL = 15 * time.Minute
+	DefaultIdleTimeout  = 2 * time.Minute
+)
+
+var (
+	ErrMalformed        = errors.New("malformed queue ticket")
+	ErrInvalidSignature = errors.New("queue ticket signature does not verify")
+)
+
+// Room holds the waiting room's settings and signs the tickets users queue with.
+// Who is waiting and who has been admitted is kept in the shared database, so every
+// node admits from the same queue.
+type Room struct {
+	// PerMinute is how many users the whole cluster admits to booking each minute
+	PerMinute int
+	// AdmissionTTL is how long an admitted user may book before queueing again
+	AdmissionTTL time.Duration
+	// IdleTimeout gives up a place in the queue when its page stops checking in
+	IdleTimeout time.Duration
+
+	secret []byte
+}
+
+// Ticket identifies a user's entry in the queue
+type Ticket struct {
+	EntryID string
+	UserID  string
+}
+
+// New returns a waiting room admitting perMinute users a minute, signing tickets with
+// secret. Every node must share the secret for tickets to verify wherever they land.
+func New(perMinute int, secret []byte) *Room {
+	return &Room{
+		PerMinute:    perMinute,
+		AdmissionTTL: DefaultAdmissionTTL,
+		IdleTimeout:  DefaultIdleTimeout,
+		secret:       secret,
+	}
+}
+
+// Sign encodes a ticket as "<payload>.<hmac>", both base64url
+func (r *Room) Sign(t Ticket) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(t.EntryID + ":" + t.UserID))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(r.mac(payload))
+}
+
+// Verify checks a ticket's signature and returns what it carries
+func (r *Room) Verify(token string) (Ticket, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Ticket{}, ErrMalformed
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, r.mac(payload)) {
+		return Ticket{}, ErrInvalidSignature
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Ticket{}, ErrMalformed
+	}
+	entryID, userID, ok := strings.Cut(string(raw), ":")
+	if !ok || entryID == "" || userID == "" {
+		return Ticket{}, ErrMalformed
+	}
+	return Ticket{EntryID: entryID, UserID: userID}, nil
+}
+
+func (r *Room) mac(payload string) []byte {
+	h := hmac.New(sha256.New, r.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// EstimatedWait is roughly how long until the user at position in the queue is
+// admitted, rounded up to the minute
+func (r *Room) EstimatedWait(position int64) time.Duration {
+	if position <= 0 || r.PerMinute <= 0 {
+		return 0
+	}
+	perMinute := int64(r.PerMinute)
+	return time.Duration((position+perMinute-1)/perMinute) * time.Minute
+}
@@ -0,0 +1,64 @@
+package waitingroom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	room := New(100, []byte("queue-secret"))
+	ticket := Ticket{EntryID: "e1", UserID: "u1"}
+	token := room.Sign(ticket)
+
+	got, err := room.Verify(token)
+	if err != nil {
+		t.Fatalf("valid ticket rejected: %v", err)
+	}
+	if got != ticket {
+		t.Errorf("Verify() = %+v, want %+v", got, ticket)
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	forged := room.Sign(Ticket{EntryID: "e2", UserID: "u1"})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name  string
+		room  *Room
+		token string
+		want  error
+	}{
+		{"wrong secret", New(100, []byte("other")), token, ErrInvalidSignature},
+		{"swapped payload", room, forgedPayload + "." + sig, ErrInvalidSignature},
+		{"missing signature", room, payload, ErrMalformed},
+		{"garbage", room, "nonsense.!!", ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.room.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimatedWait(t *testing.T) {
+	room := New(50, nil)
+	tests := []struct {
+		position int64
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Minute},
+		{50, time.Minute},
+		{51, 2 * time.Minute},
+		{1000, 20 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := room.EstimatedWait(tt.position); got != tt.want {
+			t.Errorf("EstimatedWait(%d) = %v, want %v", tt.position, got, tt.want)
+		}
+	}
+}
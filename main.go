@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"html/template"
@@ -8,10 +10,22 @@ import (
 	"net/http"
 	"os"
 	"rsvbackend/internal/app"
+	"rsvbackend/internal/auth"
 	"rsvbackend/internal/database"
+	"rsvbackend/internal/eticket"
 	"rsvbackend/internal/handlers"
+	"rsvbackend/internal/jobs"
+	"rsvbackend/internal/payment"
+	"rsvbackend/internal/pricing"
+	"rsvbackend/internal/refund"
+	"rsvbackend/internal/timetable"
+	"rsvbackend/internal/waitingroom"
+	"strconv"
 	"strings"
+	"time"
+	_ "time/tzdata" // OPERATOR_TIMEZONE resolves without the image shipping zoneinfo
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
@@ -29,6 +43,40 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// StaffMiddleware admits API requests carrying a staff token, and logged-in users
+// whose role can check passengers in
+func StaffMiddleware(appState *app.AppState) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := handlers.StaffFromRequest(appState, r); !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission admits logged-in users whose role grants the permission. It goes
+// after AuthMiddleware on a subrouter.
+func RequirePermission(appState *app.AppState, permission auth.Permission) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := handlers.UserFromRequest(appState, r)
+			if !ok {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			if !auth.Role(user.Role).Can(permission) {
+				log.Printf("User %s (%s) denied %s on %s", user.ID, user.Role, permission, r.URL.Path)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 var store *sessions.CookieStore
 
 func main() {
@@ -79,31 +127,242 @@ func main() {
 		log.Println("DATABASE_URL not set, running without DB endpoints")
 	}
 
-	templates, err := template.ParseGlob("templates/*.html")
+	// OPERATOR_TIMEZONE is an IANA zone name such as Asia/Kolkata; unset, the operator
+	// runs on UTC
+	location := time.UTC
+	if zone := os.Getenv("OPERATOR_TIMEZONE"); zone != "" {
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			log.Fatalf("Invalid OPERATOR_TIMEZONE: %v", err)
+		}
+	}
+
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"money": pricing.FormatAmount,
+		// A fresh key for each rendered form, so resubmitting the form is recognised
+		"idempotencyKey": uuid.NewString,
+		// Passengers see times on the operator's clock
+		"local": func(t time.Time) time.Time { return t.In(location) },
+	}).ParseGlob("templates/*.html")
 	if err != nil {
 		log.Fatalf("Failed to load templates: %v", err)
 	}
 
 	appState := app.NewAppState(queries, store, templates, nodeID, peers)
+	if holdTTL := os.Getenv("SEAT_HOLD_TTL"); holdTTL != "" {
+		ttl, err := time.ParseDuration(holdTTL)
+		if err != nil {
+			log.Fatalf("Invalid SEAT_HOLD_TTL: %v", err)
+		}
+		appState.HoldTTL = ttl
+	}
+	if offerTTL := os.Getenv("WAITLIST_OFFER_TTL"); offerTTL != "" {
+		ttl, err := time.ParseDuration(offerTTL)
+		if err != nil {
+			log.Fatalf("Invalid WAITLIST_OFFER_TTL: %v", err)
+		}
+		appState.WaitlistOfferTTL = ttl
+	}
+	if window := os.Getenv("IDEMPOTENCY_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			log.Fatalf("Invalid IDEMPOTENCY_WINDOW: %v", err)
+		}
+		appState.IdempotencyWindow = d
+	}
+	appState.Location = location
+	if fareRulesFile := os.Getenv("FARE_RULES_FILE"); fareRulesFile != "" {
+		rules, err := pricing.LoadRules(fareRulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load fare rules: %v", err)
+		}
+		appState.Fares = rules
+	}
+	appState.Fares.Location = appState.Location
+	if signingKey := os.Getenv("ETICKET_SIGNING_KEY"); signingKey != "" {
+		key, err := eticket.ParsePrivateKey(signingKey)
+		if err != nil {
+			log.Fatalf("Invalid ETICKET_SIGNING_KEY: %v", err)
+		}
+		appState.ETickets = eticket.NewSigner(key)
+	} else {
+		// Every node must share one key for e-tickets to verify, so this is only for development
+		log.Println("ETICKET_SIGNING_KEY not set, signing e-tickets with a temporary key")
+		appState.ETickets, err = eticket.GenerateSigner()
+		if err != nil {
+			log.Fatalf("Failed to generate e-ticket key: %v", err)
+		}
+	}
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "", "fake":
+		secret := []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+		if len(secret) == 0 {
+			log.Println("PAYMENT_WEBHOOK_SECRET not set, signing fake gateway webhooks with a temporary secret")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.Fatalf("Failed to generate webhook secret: %v", err)
+			}
+		}
+		fake := payment.NewFake(secret)
+		fake.WebhookURL = "http://localhost:" + port + "/payments/webhook"
+		appState.Payments = fake
+	default:
+		log.Fatalf("Unknown PAYMENT_PROVIDER %q", provider)
+	}
+	// STAFF_API_TOKENS is a comma-separated list of name:token pairs
+	appState.StaffTokens = make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("STAFF_API_TOKENS"), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || token == "" {
+			continue
+		}
+		appState.StaffTokens[token] = name
+	}
+	if refundPolicyFile := os.Getenv("REFUND_POLICY_FILE"); refundPolicyFile != "" {
+		policy, err := refund.LoadPolicy(refundPolicyFile)
+		if err != nil {
+			log.Fatalf("Failed to load refund policy: %v", err)
+		}
+		appState.Refunds = policy
+	}
+	// WAITING_ROOM_RATE is how many users a minute the cluster lets through to booking;
+	// unset, there is no waiting room
+	if rate := os.Getenv("WAITING_ROOM_RATE"); rate != "" {
+		perMinute, err := strconv.Atoi(rate)
+		if err != nil || perMinute < 1 {
+			log.Fatalf("Invalid WAITING_ROOM_RATE: %q", rate)
+		}
+		secret := []byte(os.Getenv("WAITING_ROOM_SECRET"))
+		if len(secret) == 0 {
+			// Queue tickets only verify on the node that signed them, so this is only for development
+			log.Println("WAITING_ROOM_SECRET not set, signing queue tickets with a temporary secret")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				log.Fatalf("Failed to generate queue ticket secret: %v", err)
+			}
+		}
+		appState.WaitingRoom = waitingroom.New(perMinute, secret)
+		if ttl := os.Getenv("WAITING_ROOM_ADMISSION_TTL"); ttl != "" {
+			appState.WaitingRoom.AdmissionTTL, err = time.ParseDuration(ttl)
+			if err != nil {
+				log.Fatalf("Invalid WAITING_ROOM_ADMISSION_TTL: %v", err)
+			}
+		}
+		if idle := os.Getenv("WAITING_ROOM_IDLE_TIMEOUT"); idle != "" {
+			appState.WaitingRoom.IdleTimeout, err = time.ParseDuration(idle)
+			if err != nil {
+				log.Fatalf("Invalid WAITING_ROOM_IDLE_TIMEOUT: %v", err)
+			}
+		}
+	}
+
+	go jobs.SweepRateLimiter(context.Background(), appState.ManageLimiter, time.Minute)
+
+	if queries != nil {
+		sweepInterval := time.Minute
+		if interval := os.Getenv("HOLD_SWEEP_INTERVAL"); interval != "" {
+			sweepInterval, err = time.ParseDuration(interval)
+			if err != nil {
+				log.Fatalf("Invalid HOLD_SWEEP_INTERVAL: %v", err)
+			}
+		}
+		releaseHolds := func(ctx context.Context) (int, error) {
+			return handlers.ReleaseExpiredHolds(ctx, appState)
+		}
+		go jobs.SweepExpiredHolds(context.Background(), releaseHolds, sweepInterval)
+		go jobs.SweepExpiredIdempotencyKeys(context.Background(), queries, time.Hour)
+		go jobs.MarkNoShows(context.Background(), queries, 5*time.Minute)
+		go jobs.RetryRefunds(context.Background(), queries, appState.Payments, 5*time.Minute)
+
+		horizonDays := timetable.DefaultHorizonDays
+		if days := os.Getenv("TIMETABLE_HORIZON_DAYS"); days != "" {
+			horizonDays, err = strconv.Atoi(days)
+			if err != nil || horizonDays < 1 {
+				log.Fatalf("Invalid TIMETABLE_HORIZON_DAYS: %q", days)
+			}
+		}
+		go jobs.GenerateDepartures(context.Background(), queries, appState.Location, horizonDays, time.Hour)
+
+		if appState.WaitingRoom != nil {
+			go jobs.AdmitFromWaitingRoom(context.Background(), queries, appState.WaitingRoom, 5*time.Second)
+		}
+	}
 
+	router := newRouter(appState)
+
+	fmt.Printf("Node %s running on port %s with peers %v\n", nodeID, port, peers)
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
+
+// newRouter maps every route to its handler behind the authentication and permission
+// its callers need
+func newRouter(appState *app.AppState) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/register", wrapHandler(appState, handlers.HandleRegister)).Methods("GET", "POST")
 	router.HandleFunc("/login", wrapHandler(appState, handlers.HandleLogin)).Methods("GET", "POST")
+	// Manage booking by PNR, open to anyone with the reference and surname
+	router.HandleFunc("/manage", wrapHandler(appState, handlers.HandleManageBooking)).Methods("GET", "POST")
+	router.HandleFunc("/manage/cancel", wrapHandler(appState, handlers.Idempotent("cancel", handlers.HandleManageCancel))).Methods("POST")
+	// Signed notifications from the payment provider
+	router.HandleFunc("/payments/webhook", wrapHandler(appState, handlers.HandlePaymentWebhook)).Methods("POST")
+	// Public key for verifying e-tickets offline
+	router.HandleFunc("/eticket/public-key", wrapHandler(appState, handlers.HandleETicketPublicKey)).Methods("GET")
 	// Distributed system endpoints
 	router.HandleFunc("/request", wrapHandler(appState, handlers.HandleRequest)).Methods("POST")
 	router.HandleFunc("/reply", wrapHandler(appState, handlers.HandleReply)).Methods("POST")
 
+	// Staff devices: check-in, boarding, manifests and offline sync
+	staff := router.PathPrefix("/staff").Subrouter()
+	staff.Use(StaffMiddleware(appState))
+	staff.HandleFunc("/checkin", wrapHandler(appState, handlers.HandleCheckin)).Methods("POST")
+	staff.HandleFunc("/checkin/sync", wrapHandler(appState, handlers.HandleCheckinSync)).Methods("POST")
+	staff.HandleFunc("/board", wrapHandler(appState, handlers.HandleBoard)).Methods("POST")
+	staff.HandleFunc("/manifest", wrapHandler(appState, handlers.HandleManifest)).Methods("GET")
+
+	// Fleet, timetable, sales and quota administration, reports and the audit log, for
+	// admin accounts
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(AuthMiddleware, handlers.AuditChanges(appState), RequirePermission(appState, auth.Administer))
+	admin.HandleFunc("/timetable/rules", wrapHandler(appState, handlers.HandleTimetableRules)).Methods("GET", "POST")
+	admin.HandleFunc("/timetable/exceptions", wrapHandler(appState, handlers.HandleTimetableException)).Methods("POST")
+	admin.HandleFunc("/timetable/preview", wrapHandler(appState, handlers.HandleTimetablePreview)).Methods("GET")
+	admin.HandleFunc("/sale-windows", wrapHandler(appState, handlers.HandleSaleWindows)).Methods("GET", "POST", "DELETE")
+	admin.HandleFunc("/quotas", wrapHandler(appState, handlers.HandleSeatQuotas)).Methods("GET", "POST", "DELETE")
+	admin.HandleFunc("/trains", wrapHandler(appState, handlers.HandleTrains)).Methods("GET", "POST", "DELETE")
+	admin.HandleFunc("/coaches", wrapHandler(appState, handlers.HandleCoaches)).Methods("POST", "DELETE")
+	admin.HandleFunc("/departures", wrapHandler(appState, handlers.HandleDepartures)).Methods("GET", "POST", "DELETE")
+	admin.HandleFunc("/fleet", wrapHandler(appState, handlers.HandleFleet)).Methods("GET", "POST")
+	admin.HandleFunc("/reports", wrapHandler(appState, handlers.HandleReports)).Methods("GET")
+	for _, name := range handlers.ReportNames() {
+		admin.HandleFunc("/reports/"+name, wrapHandler(appState, handlers.HandleReport(name))).Methods("GET")
+	}
+	admin.HandleFunc("/audit", wrapHandler(appState, handlers.HandleAuditLog)).Methods("GET")
+
+	// Booking, for every role that may book: agents do so for customers, on the agent
+	// channel
 	protected := router.PathPrefix("/").Subrouter()
-	protected.Use(AuthMiddleware)
+	protected.Use(AuthMiddleware, RequirePermission(appState, auth.Book))
 	protected.HandleFunc("/", wrapHandler(appState, handlers.HandleHome))
 	protected.HandleFunc("/logout", wrapHandler(appState, handlers.HandleLogout)).Methods("GET")
-	protected.HandleFunc("/book", wrapHandler(appState, handlers.HandleBookTicket)).Methods("GET", "POST")
-	protected.HandleFunc("/cancel", wrapHandler(appState, handlers.HandleCancelTicket)).Methods("GET")
+	protected.HandleFunc("/queue", wrapHandler(appState, handlers.HandleWaitingRoom)).Methods("GET")
+	protected.HandleFunc("/book", wrapHandler(appState, handlers.WaitingRoom(handlers.Idempotent("book", handlers.HandleBookTicket)))).Methods("GET", "POST")
+	protected.HandleFunc("/book/connection", wrapHandler(appState, handlers.WaitingRoom(handlers.Idempotent("book", handlers.HandleBookConnection)))).Methods("GET", "POST")
+	protected.HandleFunc("/book/confirm", wrapHandler(appState, handlers.Idempotent("confirm", handlers.HandleConfirmBooking))).Methods("GET", "POST")
+	protected.HandleFunc("/payments/status", wrapHandler(appState, handlers.HandlePaymentStatus)).Methods("GET")
+	protected.HandleFunc("/book/release", wrapHandler(appState, handlers.HandleReleaseHold)).Methods("POST")
+	protected.HandleFunc("/cancel", wrapHandler(appState, handlers.Idempotent("cancel", handlers.HandleCancelTicket))).Methods("GET", "POST")
+	protected.HandleFunc("/modify", wrapHandler(appState, handlers.Idempotent("modify", handlers.HandleModifyTicket))).Methods("GET", "POST")
+	protected.HandleFunc("/waitlist/join", wrapHandler(appState, handlers.HandleJoinWaitlist)).Methods("POST")
+	protected.HandleFunc("/waitlist/leave", wrapHandler(appState, handlers.HandleLeaveWaitlist)).Methods("POST")
+	protected.HandleFunc("/passengers", wrapHandler(appState, handlers.HandlePassengers)).Methods("GET", "POST")
+	protected.HandleFunc("/passengers/delete", wrapHandler(appState, handlers.HandleDeletePassenger)).Methods("POST")
 	protected.HandleFunc("/tickets", wrapHandler(appState, handlers.HandleViewTickets)).Methods("GET")
+	protected.HandleFunc("/tickets/eticket.png", wrapHandler(appState, handlers.HandleETicketQR)).Methods("GET")
+	protected.HandleFunc("/tickets/eticket.pdf", wrapHandler(appState, handlers.HandleETicketPDF)).Methods("GET")
+	protected.HandleFunc("/search", wrapHandler(appState, handlers.HandleSearch)).Methods("GET")
+	protected.HandleFunc("/api/search", wrapHandler(appState, handlers.HandleSearchAPI)).Methods("GET")
 	protected.HandleFunc("/available", wrapHandler(appState, handlers.HandleViewAvailableTickets)).Methods("GET")
-
-	fmt.Printf("Node %s running on port %s with peers %v\n", nodeID, port, peers)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	return router
 }
 
 func wrapHandler(appState *app.AppState, handlerFunc func(*app.AppState, http.ResponseWriter, *http.Request)) http.HandlerFunc {
@@ -3,10 +3,19 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"rsvbackend/internal/app"
 	"testing"
+
+	"github.com/gorilla/sessions"
 )
 
+func TestMain(m *testing.M) {
+	// main sets up the session store before serving
+	store = sessions.NewCookieStore([]byte("test-session-key-0123456789abcdef"))
+	os.Exit(m.Run())
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	appState := &app.AppState{
 		Store: store,
@@ -36,6 +45,10 @@ func TestAuthMiddleware(t *testing.T) {
 	session.Values["authenticated"] = true
 	session.Values["userID"] = "test-user-id"
 	session.Save(req, rr)
+	for _, cookie := range rr.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 	if rr.Code != http.StatusOK {
 		t.Errorf("expected status 200, got %d", rr.Code)
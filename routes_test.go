@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rsvbackend/internal/app"
+	"rsvbackend/internal/auth"
+	"rsvbackend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
+)
+
+// routeTest is the app's router over a migrated in-memory database
+type routeTest struct {
+	appState *app.AppState
+	db       *sql.DB
+	router   http.Handler
+}
+
+func newRouteTest(t *testing.T) *routeTest {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file::memory:?_time_format=sqlite&_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "sql/schema"); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	appState := app.NewAppState(database.New(db), store, nil, "node-1", nil)
+	return &routeTest{appState: appState, db: db, router: newRouter(appState)}
+}
+
+// signIn creates a user with the role and returns their session cookie
+func (rt *routeTest) signIn(t *testing.T, role auth.Role) *http.Cookie {
+	t.Helper()
+	ctx := context.Background()
+	user, err := rt.appState.DB.CreateUser(ctx, database.CreateUserParams{
+		ID:       uuid.NewString(),
+		Email:    string(role) + "@example.com",
+		Password: "not-a-real-hash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.appState.DB.SetUserRole(ctx, database.SetUserRoleParams{Role: string(role), Email: user.Email}); err != nil {
+		t.Fatal(err)
+	}
+	return sessionFor(t, user.ID)
+}
+
+// sessionFor signs a user ID in the way HandleLogin does
+func sessionFor(t *testing.T, userID string) *http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	session, _ := store.Get(r, "session-name")
+	session.Values["authenticated"] = true
+	session.Values["userID"] = userID
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies()[0]
+}
+
+func (rt *routeTest) do(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	rt.router.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminRoutesNeedAdminRole(t *testing.T) {
+	rt := newRouteTest(t)
+
+	for _, role := range []auth.Role{auth.Passenger, auth.Agent, auth.Staff} {
+		cookie := rt.signIn(t, role)
+		for _, path := range []string{"/admin/quotas", "/admin/trains", "/admin/audit", "/admin/reports"} {
+			if w := rt.do(http.MethodGet, path, cookie); w.Code != http.StatusForbidden {
+				t.Errorf("%s GET %s got %d, want 403", role, path, w.Code)
+			}
+		}
+		if w := rt.do(http.MethodPost, "/admin/quotas", cookie); w.Code != http.StatusForbidden {
+			t.Errorf("%s POST /admin/quotas got %d, want 403", role, w.Code)
+		}
+	}
+	if n := countRows(t, rt.db, "SELECT COUNT(*) FROM seat_quotas"); n != 0 {
+		t.Errorf("%d quotas created by users without the admin role", n)
+	}
+	// Refused changes are still in the audit log
+	if n := countRows(t, rt.db, "SELECT COUNT(*) FROM audit_events WHERE result = 'failure' AND detail LIKE 'POST 403%'"); n != 3 {
+		t.Errorf("%d refused admin changes audited, want 3", n)
+	}
+
+	admin := rt.signIn(t, auth.Admin)
+	if w := rt.do(http.MethodGet, "/admin/quotas", admin); w.Code != http.StatusOK {
+		t.Errorf("admin GET /admin/quotas got %d, want 200", w.Code)
+	}
+}
+
+func TestStaffRoutesNeedCheckInPermission(t *testing.T) {
+	rt := newRouteTest(t)
+
+	for _, role := range []auth.Role{auth.Passenger, auth.Agent} {
+		if w := rt.do(http.MethodGet, "/staff/manifest", rt.signIn(t, role)); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s GET /staff/manifest got %d, want 401", role, w.Code)
+		}
+	}
+	if w := rt.do(http.MethodGet, "/staff/manifest", rt.signIn(t, auth.Staff)); w.Code == http.StatusUnauthorized {
+		t.Error("staff refused the manifest")
+	}
+}
+
+func TestRoutesNeedSignedInUser(t *testing.T) {
+	rt := newRouteTest(t)
+
+	// A session for a user who no longer exists is no better than none
+	stale := sessionFor(t, uuid.NewString())
+	for _, cookie := range []*http.Cookie{nil, stale} {
+		for _, path := range []string{"/book", "/book/confirm", "/cancel", "/modify", "/tickets", "/admin/quotas"} {
+			w := rt.do(http.MethodGet, path, cookie)
+			if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+				t.Errorf("GET %s (session %v) got %d to %q, want a redirect to /login", path, cookie != nil, w.Code, w.Header().Get("Location"))
+			}
+		}
+	}
+
+	// Every role books: agents for their customers, staff and admins for themselves
+	for _, role := range auth.Roles {
+		if !role.Can(auth.Book) {
+			t.Errorf("%s can't book", role)
+		}
+	}
+}
+
+func countRows(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
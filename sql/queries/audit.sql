@@ -0,0 +1,31 @@
+-- name: AppendAuditEvent :one
+INSERT INTO audit_events (
+    seq, occurred_at, actor, action, target, node_id, lamport_clock, ip, result, detail, prev_hash, hash
+)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)
+RETURNING *;
+
+-- name: GetLastAuditEvent :one
+SELECT * FROM audit_events
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: ListAuditChain :many
+SELECT * FROM audit_events
+WHERE seq > ?1
+ORDER BY seq
+LIMIT ?2;
+
+-- name: ListAuditEvents :many
+-- ?6 and ?7 are UTC instants: the start of the first day searched and the end of the last
+SELECT * FROM audit_events
+WHERE (?1 = '' OR actor = ?1)
+AND (?2 = '' OR action = ?2)
+AND (?3 = '' OR target = ?3)
+AND (?4 = '' OR result = ?4)
+AND (?5 = '' OR node_id = ?5)
+AND (?6 = '' OR datetime(occurred_at) >= ?6)
+AND (?7 = '' OR datetime(occurred_at) < ?7)
+AND (?8 = 0 OR seq < ?8)
+ORDER BY seq DESC
+LIMIT ?9;
@@ -0,0 +1,37 @@
+-- name: CreateBooking :one
+INSERT INTO bookings (id, pnr, user_id, channel)
+VALUES (?, ?, ?, ?)
+RETURNING id, pnr, user_id, created_at, channel;
+
+-- name: DeleteBooking :exec
+DELETE FROM bookings
+WHERE id = ?;
+
+-- name: GetBookingByPNR :one
+SELECT b.id, b.pnr, b.user_id, b.created_at, b.channel
+FROM bookings b
+WHERE b.pnr = ?1
+AND EXISTS (
+    SELECT 1 FROM tickets tk
+    WHERE tk.booking_id = b.id
+    AND (LOWER(tk.passenger_name) = ?2
+         OR SUBSTR(LOWER(tk.passenger_name), -LENGTH(?2) - 1) = ' ' || ?2)
+);
+
+-- name: GetBookingTickets :many
+SELECT tk.id, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, o.departure_offset_minutes,
+       sd.name AS destination_name, ds.arrival_offset_minutes,
+       s.label AS seat_label, c.class,
+       tk.fare_amount, tk.fare_currency, tk.passenger_name, tk.status
+FROM tickets tk
+JOIN departures d ON tk.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.booking_id = ?
+ORDER BY d.departs_at, tk.seat_number;
@@ -0,0 +1,4 @@
+-- name: CreateCancellation :one
+INSERT INTO cancellations (id, ticket_id, channel, hours_before_departure, fare_amount, refund_percent, fee_amount, refund_amount, currency)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, ticket_id, channel, hours_before_departure, fare_amount, refund_percent, fee_amount, refund_amount, currency, cancelled_at;
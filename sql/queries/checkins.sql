@@ -0,0 +1,33 @@
+-- name: CreateCheckin :one
+INSERT INTO checkins (id, ticket_id, departure_id, method, staff, device_id, scanned_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetCheckin :one
+SELECT * FROM checkins
+WHERE ticket_id = ?;
+
+-- name: GetDepartureManifest :many
+SELECT tk.id, b.pnr, tk.passenger_name, tk.seat_number, s.label AS seat_label, c.class,
+       so.name AS origin_name, sd.name AS destination_name, tk.status,
+       ck.scanned_at AS checked_in_at
+FROM tickets tk
+LEFT JOIN bookings b ON tk.booking_id = b.id
+LEFT JOIN checkins ck ON ck.ticket_id = tk.id
+JOIN departures d ON tk.departure_id = d.id
+JOIN seats s ON s.train_id = d.train_id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = d.train_id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = d.train_id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.departure_id = ?
+AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+ORDER BY tk.seat_number;
+
+-- name: GetPNRTickets :many
+SELECT tk.id, tk.departure_id, tk.status, tk.passenger_name
+FROM tickets tk
+JOIN bookings b ON tk.booking_id = b.id
+WHERE b.pnr = ?
+ORDER BY tk.seat_number;
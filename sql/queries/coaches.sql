@@ -0,0 +1,62 @@
+-- name: CreateCoach :one
+INSERT INTO coaches (id, train_id, code, class, position)
+SELECT ?1, ?2, ?3, ?4, (SELECT COALESCE(MAX(c.position), 0) + 1 FROM coaches c WHERE c.train_id = ?2)
+WHERE EXISTS (SELECT 1 FROM trains t WHERE t.id = ?2 AND t.status = 'active')
+RETURNING *;
+
+-- name: GetCoach :one
+SELECT c.id, c.train_id, c.code, c.class, c.position,
+       CAST(COALESCE(SUM(s.retired = 0), 0) AS INTEGER) AS seats,
+       -- Retired seats keep their numbers, so new seats are numbered after them
+       CAST(COUNT(s.seat_number) AS INTEGER) AS all_seats,
+       -- A seat is sold while a live ticket or hold for it is on a departure that
+       -- hasn't arrived yet
+       CAST(COALESCE(SUM(s.retired = 0 AND (
+           EXISTS (
+               SELECT 1 FROM tickets tk
+               JOIN departures d ON tk.departure_id = d.id
+               WHERE d.train_id = c.train_id
+               AND tk.seat_number = s.seat_number
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               AND d.arrives_at > CURRENT_TIMESTAMP
+           )
+           OR EXISTS (
+               SELECT 1 FROM seat_holds sh
+               WHERE sh.departure_id IN (SELECT d.id FROM departures d WHERE d.train_id = c.train_id)
+               AND sh.seat_number = s.seat_number
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       )), 0) AS INTEGER) AS sold_seats
+FROM coaches c
+LEFT JOIN seats s ON s.coach_id = c.id
+WHERE c.id = ?
+GROUP BY c.id;
+
+-- name: ListCoaches :many
+SELECT c.id, c.train_id, c.code, c.class, c.position,
+       CAST(COALESCE(SUM(s.retired = 0), 0) AS INTEGER) AS seats,
+       -- Retired seats keep their numbers, so new seats are numbered after them
+       CAST(COUNT(s.seat_number) AS INTEGER) AS all_seats,
+       -- A seat is sold while a live ticket or hold for it is on a departure that
+       -- hasn't arrived yet
+       CAST(COALESCE(SUM(s.retired = 0 AND (
+           EXISTS (
+               SELECT 1 FROM tickets tk
+               JOIN departures d ON tk.departure_id = d.id
+               WHERE d.train_id = c.train_id
+               AND tk.seat_number = s.seat_number
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               AND d.arrives_at > CURRENT_TIMESTAMP
+           )
+           OR EXISTS (
+               SELECT 1 FROM seat_holds sh
+               WHERE sh.departure_id IN (SELECT d.id FROM departures d WHERE d.train_id = c.train_id)
+               AND sh.seat_number = s.seat_number
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       )), 0) AS INTEGER) AS sold_seats
+FROM coaches c
+LEFT JOIN seats s ON s.coach_id = c.id
+WHERE c.train_id = ?
+GROUP BY c.id
+ORDER BY c.position;
@@ -0,0 +1,102 @@
+-- name: GetDeparture :one
+SELECT d.id, d.train_id, t.name, d.service_date, d.departs_at, d.arrives_at, d.status
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+WHERE d.id = ?;
+
+-- name: CreateScheduledDeparture :execrows
+INSERT INTO departures (id, train_id, service_date, departs_at, arrives_at, rule_id, total_seats)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, (SELECT COUNT(*) FROM seats s WHERE s.train_id = ?2 AND s.retired = 0)
+WHERE EXISTS (SELECT 1 FROM trains t WHERE t.id = ?2 AND t.status = 'active')
+ON CONFLICT DO NOTHING;
+
+-- name: CreateDeparture :one
+INSERT INTO departures (id, train_id, service_date, departs_at, arrives_at, total_seats)
+SELECT ?1, ?2, ?3, ?4, ?5, (SELECT COUNT(*) FROM seats s WHERE s.train_id = ?2 AND s.retired = 0)
+WHERE EXISTS (SELECT 1 FROM trains t WHERE t.id = ?2 AND t.status = 'active')
+RETURNING *;
+
+-- name: ListTrainDepartures :many
+SELECT d.id, d.train_id, d.service_date, d.departs_at, d.arrives_at, d.status, d.rule_id,
+       CAST((
+           SELECT COUNT(*) FROM (
+               SELECT tk.seat_number FROM tickets tk
+               WHERE tk.departure_id = d.id
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               UNION
+               SELECT sh.seat_number FROM seat_holds sh
+               WHERE sh.departure_id = d.id
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       ) AS INTEGER) AS sold_seats
+FROM departures d
+WHERE d.train_id = ?1
+AND d.service_date >= ?2
+ORDER BY d.departs_at
+LIMIT ?3;
+
+-- name: RescheduleDeparture :one
+UPDATE departures
+SET departs_at = ?, arrives_at = ?
+WHERE id = ?
+AND status = 'scheduled'
+AND departs_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: CancelDeparture :execrows
+UPDATE departures
+SET status = 'cancelled'
+WHERE id = ?
+AND status = 'scheduled'
+AND NOT EXISTS (
+    SELECT 1 FROM tickets tk
+    WHERE tk.departure_id = departures.id
+    AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+)
+AND NOT EXISTS (
+    SELECT 1 FROM seat_holds sh
+    WHERE sh.departure_id = departures.id
+    AND sh.expires_at > CURRENT_TIMESTAMP
+);
+
+-- name: CountBookedDepartures :one
+SELECT COUNT(*) FROM departures d
+WHERE d.train_id = ?
+AND d.status = 'scheduled'
+AND d.arrives_at > CURRENT_TIMESTAMP
+AND (
+    EXISTS (
+        SELECT 1 FROM tickets tk
+        WHERE tk.departure_id = d.id
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+    )
+    OR EXISTS (
+        SELECT 1 FROM seat_holds sh
+        WHERE sh.departure_id = d.id
+        AND sh.expires_at > CURRENT_TIMESTAMP
+    )
+);
+
+-- name: CancelTrainDepartures :execrows
+UPDATE departures
+SET status = 'cancelled'
+WHERE train_id = ?
+AND status = 'scheduled'
+AND departs_at > CURRENT_TIMESTAMP;
+
+-- name: GetSalesOpenAt :one
+SELECT CAST(COALESCE((
+    SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+    FROM sale_windows sw
+    WHERE sw.train_id = d.train_id AND sw.class IN (?2, '')
+    ORDER BY sw.class DESC
+    LIMIT 1
+), '') AS TEXT) AS sales_open_at
+FROM departures d
+WHERE d.id = ?1;
+
+-- name: UpdateDepartureCapacity :exec
+UPDATE departures
+SET total_seats = (SELECT COUNT(*) FROM seats s WHERE s.train_id = departures.train_id AND s.retired = 0)
+WHERE train_id = ?
+AND departs_at > CURRENT_TIMESTAMP;
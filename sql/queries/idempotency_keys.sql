@@ -0,0 +1,31 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (owner, scope, idempotency_key, request_hash, expires_at)
+VALUES (?1, ?2, ?3, ?4, ?5)
+ON CONFLICT (owner, scope, idempotency_key) DO UPDATE
+SET request_hash = excluded.request_hash,
+    response_status = NULL,
+    response_location = '',
+    response_content_type = '',
+    response_body = '',
+    created_at = CURRENT_TIMESTAMP,
+    expires_at = excluded.expires_at
+WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE owner = ? AND scope = ? AND idempotency_key = ?;
+
+-- name: SaveIdempotentResponse :exec
+UPDATE idempotency_keys
+SET response_status = ?4, response_location = ?5, response_content_type = ?6, response_body = ?7,
+    expires_at = ?8
+WHERE owner = ?1 AND scope = ?2 AND idempotency_key = ?3;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE owner = ? AND scope = ? AND idempotency_key = ?;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= CURRENT_TIMESTAMP;
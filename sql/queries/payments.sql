@@ -0,0 +1,95 @@
+-- name: CreatePayment :one
+INSERT INTO payments (id, user_id, hold_id, provider, amount, currency)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetPayment :one
+SELECT * FROM payments
+WHERE id = ?;
+
+-- name: GetHoldPayment :one
+SELECT * FROM payments
+WHERE hold_id = ? AND user_id = ? AND status = 'pending'
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ListTicketPayments :many
+-- The captured payments of a ticket's booking with money left to refund, oldest first
+SELECT p.id, p.user_id, p.hold_id, p.ticket_id, p.provider, p.provider_ref, p.amount, p.currency,
+       p.status, p.failure_reason, p.refunded_amount, p.created_at, p.updated_at, p.booking_id,
+       p.refund_due, p.refund_error, p.client_ip, p.refund_claimed, p.refund_claimed_at
+FROM payments p
+JOIN tickets tk ON tk.booking_id = p.booking_id
+WHERE tk.id = ? AND p.status = 'captured'
+  AND p.refunded_amount + p.refund_due + p.refund_claimed < p.amount
+ORDER BY p.created_at, p.id;
+
+-- name: SetPaymentRef :exec
+UPDATE payments
+SET provider_ref = ?2, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1;
+
+-- name: SetPaymentClientIP :exec
+UPDATE payments
+SET client_ip = ?2, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1;
+
+-- name: SetPaymentTicket :exec
+UPDATE payments
+SET ticket_id = ?2, booking_id = ?3, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1;
+
+-- name: FailHoldPayments :execrows
+-- Fails the payments still waiting on a seat hold that was released or lapsed. Payments
+-- already with the provider are left to settle, and refunded if they capture.
+UPDATE payments
+SET status = 'failed', failure_reason = ?2, updated_at = CURRENT_TIMESTAMP
+WHERE hold_id = ?1 AND status = 'pending' AND provider_ref IS NULL;
+
+-- name: UpdatePaymentStatus :one
+UPDATE payments
+SET status = ?3, failure_reason = ?4, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1 AND status = ?2
+RETURNING *;
+
+-- name: QueueRefund :execrows
+-- Queues a refund to be claimed and made once the caller's transaction commits
+UPDATE payments
+SET refund_due = refund_due + ?2, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1 AND status = 'captured'
+  AND refunded_amount + refund_due + refund_claimed + ?2 <= amount;
+
+-- name: ListDueRefunds :many
+SELECT * FROM payments
+WHERE refund_due > 0 OR refund_claimed > 0
+ORDER BY updated_at
+LIMIT ?;
+
+-- name: ClaimRefund :one
+-- Claims a payment's queued refund for this node to make. A claim still in flight is
+-- left alone; one older than the lease was left by a node that died and is taken over
+-- for the same amount, so the provider sees the same refund again.
+UPDATE payments
+SET refund_claimed = CASE WHEN refund_claimed > 0 THEN refund_claimed ELSE refund_due END,
+    refund_due = CASE WHEN refund_claimed > 0 THEN refund_due ELSE 0 END,
+    refund_claimed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND (
+    (refund_claimed = 0 AND refund_due > 0)
+    OR (refund_claimed > 0 AND refund_claimed_at <= datetime('now', '-' || sqlc.arg(lease_seconds) || ' seconds'))
+)
+RETURNING *;
+
+-- name: SettleClaimedRefund :execrows
+-- The provider has made a claimed refund
+UPDATE payments
+SET refunded_amount = refunded_amount + refund_claimed,
+    status = CASE WHEN refunded_amount + refund_claimed >= amount THEN 'refunded' ELSE status END,
+    refund_claimed = 0, refund_claimed_at = NULL, refund_error = '', updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1 AND refund_claimed = ?2;
+
+-- name: SetRefundError :exec
+-- The provider refused a claimed refund. The claim is kept, so it is retried for the
+-- same amount once it has lapsed.
+UPDATE payments
+SET refund_error = ?2, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?1;
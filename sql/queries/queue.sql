@@ -0,0 +1,53 @@
+-- name: JoinQueue :one
+-- Takes the next position. Two users joining at once through different nodes can read
+-- the same last position; the second finds it taken and gets no row, and tries again.
+INSERT INTO queue_entries (id, user_id, position)
+SELECT ?1, ?2, COALESCE(MAX(position), 0) + 1
+FROM queue_entries
+WHERE true
+ON CONFLICT (position) DO NOTHING
+RETURNING *;
+
+-- name: GetQueueEntry :one
+SELECT * FROM queue_entries
+WHERE id = ? AND user_id = ?;
+
+-- name: TouchQueueEntry :exec
+UPDATE queue_entries
+SET last_seen_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = 'waiting';
+
+-- name: GetQueuePosition :one
+SELECT COUNT(*) FROM queue_entries q
+WHERE q.status = 'waiting'
+AND q.position <= sqlc.arg(position)
+AND q.last_seen_at > datetime('now', '-' || sqlc.arg(idle_seconds) || ' seconds');
+
+-- name: AdmitFromQueue :execrows
+UPDATE queue_entries
+SET status = 'admitted',
+    admitted_at = CURRENT_TIMESTAMP,
+    expires_at = datetime('now', '+' || sqlc.arg(admission_seconds) || ' seconds')
+WHERE id IN (
+    SELECT q.id FROM queue_entries q
+    WHERE q.status = 'waiting'
+    AND q.last_seen_at > datetime('now', '-' || sqlc.arg(idle_seconds) || ' seconds')
+    ORDER BY q.position
+    LIMIT MAX(0, sqlc.arg(per_minute) - (
+        SELECT COUNT(*) FROM queue_entries a
+        WHERE a.admitted_at > datetime('now', '-60 seconds')
+    ))
+);
+
+-- name: AbandonIdleQueueEntries :execrows
+UPDATE queue_entries
+SET status = 'abandoned'
+WHERE status = 'waiting'
+AND last_seen_at <= datetime('now', '-' || sqlc.arg(idle_seconds) || ' seconds');
+
+-- name: DeleteFinishedQueueEntries :execrows
+DELETE FROM queue_entries
+WHERE status = 'abandoned'
+OR (status = 'admitted'
+    AND expires_at <= CURRENT_TIMESTAMP
+    AND admitted_at <= datetime('now', '-60 seconds'));
@@ -0,0 +1,77 @@
+-- name: CreateSeatQuota :one
+INSERT INTO seat_quotas (id, departure_id, category, class, release_hours_before)
+SELECT ?1, d.id, ?3, ?4, ?5
+FROM departures d
+WHERE d.id = ?2
+AND EXISTS (SELECT 1 FROM coaches c WHERE c.train_id = d.train_id AND c.class = ?4)
+RETURNING *;
+
+-- name: AllocateQuotaSeats :execrows
+INSERT INTO quota_seats (quota_id, departure_id, seat_number)
+SELECT sq.id, sq.departure_id, s.seat_number
+FROM seat_quotas sq
+JOIN departures d ON sq.departure_id = d.id
+JOIN coaches c ON c.train_id = d.train_id AND c.class = sq.class
+JOIN seats s ON s.coach_id = c.id AND s.retired = 0
+WHERE sq.id = ?1
+AND NOT EXISTS (
+    SELECT 1 FROM quota_seats qs
+    WHERE qs.departure_id = d.id AND qs.seat_number = s.seat_number
+)
+AND NOT EXISTS (
+    SELECT 1 FROM tickets tk
+    WHERE tk.departure_id = d.id
+    AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+    AND tk.seat_number = s.seat_number
+)
+AND NOT EXISTS (
+    SELECT 1 FROM seat_holds sh
+    WHERE sh.departure_id = d.id
+    AND sh.seat_number = s.seat_number
+    AND sh.expires_at > CURRENT_TIMESTAMP
+)
+ORDER BY
+    -- Give each category the seats that suit it best
+    CASE sq.category
+        WHEN 'disabled' THEN s.wheelchair_space
+        WHEN 'senior' THEN s.berth_type IN ('lower', 'side_lower')
+        ELSE 0
+    END DESC,
+    c.position,
+    s.seat_number
+LIMIT ?2;
+
+-- name: ListSeatQuotas :many
+SELECT sq.id, sq.departure_id, sq.category, sq.class, sq.release_hours_before,
+       CAST(datetime(d.departs_at, '-' || sq.release_hours_before || ' hours') AS TEXT) AS releases_at,
+       COUNT(qs.seat_number) AS seats,
+       CAST(COALESCE(SUM(EXISTS (
+           SELECT 1 FROM tickets tk
+           WHERE tk.departure_id = qs.departure_id
+           AND tk.seat_number = qs.seat_number
+           AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+       )), 0) AS INTEGER) AS sold_seats
+FROM seat_quotas sq
+JOIN departures d ON sq.departure_id = d.id
+LEFT JOIN quota_seats qs ON qs.quota_id = sq.id
+WHERE sq.departure_id = ?
+GROUP BY sq.id
+ORDER BY sq.class, sq.category;
+
+-- name: DeleteQuotaSeats :exec
+DELETE FROM quota_seats
+WHERE quota_id = ?;
+
+-- name: DeleteSeatQuota :execrows
+DELETE FROM seat_quotas
+WHERE id = ?;
+
+-- name: DeleteRetiredQuotaSeats :exec
+DELETE FROM quota_seats
+WHERE EXISTS (
+    SELECT 1 FROM departures d
+    JOIN seats s ON s.train_id = d.train_id
+    WHERE d.id = quota_seats.departure_id
+    AND s.seat_number = quota_seats.seat_number
+    AND s.retired = 1
+);
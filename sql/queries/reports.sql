@@ -0,0 +1,64 @@
+-- name: ReportDepartureLoad :many
+SELECT d.id, d.train_id, t.name, d.service_date, d.departs_at, d.total_seats,
+       CAST(COALESCE((
+           SELECT st.name FROM route_stops rs JOIN stations st ON rs.station_id = st.id
+           WHERE rs.train_id = d.train_id ORDER BY rs.stop_sequence LIMIT 1
+       ), '') AS TEXT) AS origin_name,
+       CAST(COALESCE((
+           SELECT st.name FROM route_stops rs JOIN stations st ON rs.station_id = st.id
+           WHERE rs.train_id = d.train_id ORDER BY rs.stop_sequence DESC LIMIT 1
+       ), '') AS TEXT) AS destination_name,
+       CAST(COALESCE((
+           SELECT MAX(rs.distance_km) - MIN(rs.distance_km) FROM route_stops rs
+           WHERE rs.train_id = d.train_id
+       ), 0) AS INTEGER) AS route_km,
+       CAST(COUNT(tk.id) AS INTEGER) AS tickets_sold,
+       CAST(COALESCE(SUM(ds.distance_km - o.distance_km), 0) AS INTEGER) AS passenger_km
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+LEFT JOIN tickets tk ON tk.departure_id = d.id
+    AND tk.status IN ('confirmed', 'checked_in', 'boarded', 'no_show')
+LEFT JOIN route_stops o ON o.train_id = d.train_id AND o.stop_sequence = tk.origin_stop
+LEFT JOIN route_stops ds ON ds.train_id = d.train_id AND ds.stop_sequence = tk.destination_stop
+WHERE d.service_date BETWEEN ?1 AND ?2
+AND d.status <> 'cancelled'
+GROUP BY d.id
+ORDER BY d.service_date, d.departs_at;
+
+-- name: ReportCancellations :many
+-- Takes the UTC instants the range starts and ends at and groups by UTC minute, for
+-- the caller to total by the operator's local day
+SELECT CAST(strftime('%Y-%m-%d %H:%M', cn.cancelled_at) AS TEXT) AS minute, cn.channel, cn.currency,
+       COUNT(*) AS cancellations,
+       CAST(SUM(cn.fare_amount) AS INTEGER) AS fare_amount,
+       CAST(SUM(cn.fee_amount) AS INTEGER) AS fee_amount,
+       CAST(SUM(cn.refund_amount) AS INTEGER) AS refund_amount
+FROM cancellations cn
+WHERE datetime(cn.cancelled_at) >= ?1 AND datetime(cn.cancelled_at) < ?2
+GROUP BY minute, cn.channel, cn.currency
+ORDER BY minute, cn.channel, cn.currency;
+
+-- name: ReportBookingChannels :many
+SELECT b.channel, tk.fare_currency,
+       COUNT(DISTINCT b.id) AS bookings,
+       COUNT(tk.id) AS tickets,
+       CAST(COALESCE(SUM(tk.fare_amount), 0) AS INTEGER) AS fare_amount
+FROM bookings b
+-- Only fares still held count: cancelled tickets and those replaced by a change of
+-- booking are left out
+JOIN tickets tk ON tk.booking_id = b.id
+    AND tk.status IN ('confirmed', 'checked_in', 'boarded', 'no_show')
+WHERE datetime(b.created_at) >= ?1 AND datetime(b.created_at) < ?2
+GROUP BY b.channel, tk.fare_currency
+ORDER BY b.channel, tk.fare_currency;
+
+-- name: ReportBookingHours :many
+-- Grouped by UTC minute, for the caller to total by the operator's local hour
+SELECT CAST(strftime('%Y-%m-%d %H:%M', b.created_at) AS TEXT) AS minute,
+       COUNT(DISTINCT b.id) AS bookings,
+       COUNT(tk.id) AS tickets
+FROM bookings b
+JOIN tickets tk ON tk.booking_id = b.id
+WHERE datetime(b.created_at) >= ?1 AND datetime(b.created_at) < ?2
+GROUP BY minute
+ORDER BY minute;
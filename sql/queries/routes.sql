@@ -0,0 +1,65 @@
+-- name: GetTrainRunMinutes :one
+SELECT CAST(COALESCE(MAX(arrival_offset_minutes), 0) AS INTEGER) AS run_minutes
+FROM route_stops
+WHERE train_id = ?;
+
+-- name: ListStations :many
+SELECT id, code, name, min_transfer_minutes
+FROM stations
+ORDER BY name;
+
+-- name: GetJourneyStops :one
+SELECT o.stop_sequence AS origin_stop, ds.stop_sequence AS destination_stop,
+       d.departs_at, o.departure_offset_minutes, ds.arrival_offset_minutes
+FROM departures d
+JOIN route_stops o ON o.train_id = d.train_id AND o.station_id = ?2
+JOIN route_stops ds ON ds.train_id = d.train_id AND ds.station_id = ?3
+WHERE d.id = ?1
+AND o.stop_sequence < ds.stop_sequence;
+
+-- name: ListTransferStations :many
+SELECT st.id, st.code, st.name, st.min_transfer_minutes
+FROM stations st
+WHERE st.id <> ?1 AND st.id <> ?2
+AND EXISTS (
+    SELECT 1 FROM route_stops o
+    JOIN route_stops v ON v.train_id = o.train_id AND o.stop_sequence < v.stop_sequence
+    WHERE o.station_id = ?1 AND v.station_id = st.id
+)
+AND EXISTS (
+    SELECT 1 FROM route_stops v
+    JOIN route_stops ds ON ds.train_id = v.train_id AND v.stop_sequence < ds.stop_sequence
+    WHERE v.station_id = st.id AND ds.station_id = ?2
+)
+ORDER BY st.name;
+
+-- name: GetSegmentFareInputs :one
+SELECT d.departs_at, o.departure_offset_minutes,
+       CAST(ds.distance_km - o.distance_km AS INTEGER) AS distance_km,
+       COUNT(s.seat_number) AS total_seats,
+       CAST(SUM(
+           NOT EXISTS (
+               SELECT 1 FROM tickets tk
+               WHERE tk.departure_id = d.id
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               AND tk.seat_number = s.seat_number
+               AND tk.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < tk.destination_stop
+           )
+           AND NOT EXISTS (
+               SELECT 1 FROM seat_holds sh
+               WHERE sh.departure_id = d.id
+               AND sh.seat_number = s.seat_number
+               AND sh.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < sh.destination_stop
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       ) AS INTEGER) AS available_seats
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = ?2
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = ?3
+JOIN coaches c ON c.train_id = t.id AND c.class = ?4
+JOIN seats s ON s.coach_id = c.id AND s.retired = 0
+WHERE d.id = ?1
+GROUP BY d.id;
@@ -0,0 +1,15 @@
+-- name: ListSaleWindows :many
+SELECT * FROM sale_windows
+ORDER BY train_id, class;
+
+-- name: UpsertSaleWindow :one
+INSERT INTO sale_windows (train_id, class, opens_days_before, opens_time)
+VALUES (?1, ?2, ?3, ?4)
+ON CONFLICT (train_id, class) DO UPDATE
+SET opens_days_before = excluded.opens_days_before,
+    opens_time = excluded.opens_time
+RETURNING *;
+
+-- name: DeleteSaleWindow :exec
+DELETE FROM sale_windows
+WHERE train_id = ? AND class = ?;
@@ -0,0 +1,19 @@
+-- name: CreateSavedPassenger :one
+INSERT INTO saved_passengers (id, user_id, name, age, gender, id_document)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, user_id, name, age, gender, id_document, created_at;
+
+-- name: DeleteSavedPassenger :exec
+DELETE FROM saved_passengers
+WHERE id = ? AND user_id = ?;
+
+-- name: GetSavedPassenger :one
+SELECT id, user_id, name, age, gender, id_document, created_at
+FROM saved_passengers
+WHERE id = ? AND user_id = ?;
+
+-- name: ListSavedPassengers :many
+SELECT id, user_id, name, age, gender, id_document, created_at
+FROM saved_passengers
+WHERE user_id = ?
+ORDER BY name;
@@ -0,0 +1,102 @@
+-- name: CreateSeatHold :one
+INSERT INTO seat_holds (id, departure_id, user_id, seat_number, origin_stop, destination_stop, fare_amount, fare_currency, expires_at,
+                        passenger_name, passenger_age, passenger_gender, passenger_id_document, itinerary_id, leg, legs,
+                        quota, concession_ref, passenger, passengers, channel)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18, ?20, ?21, ?22
+WHERE EXISTS (
+    SELECT 1
+    FROM departures d
+    JOIN trains t ON d.train_id = t.id
+    WHERE d.id = ?2
+    AND d.status = 'scheduled'
+    AND d.departs_at > CURRENT_TIMESTAMP
+    AND EXISTS (SELECT 1 FROM seats s WHERE s.train_id = t.id AND s.seat_number = ?4 AND s.retired = 0)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?5)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?6)
+    AND ?5 < ?6
+    AND COALESCE((
+        SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+        FROM sale_windows sw
+        JOIN seats s ON s.train_id = t.id AND s.seat_number = ?4
+        JOIN coaches c ON s.coach_id = c.id
+        WHERE sw.train_id = t.id AND sw.class IN (c.class, '')
+        ORDER BY sw.class DESC
+        LIMIT 1
+    ), '') <= ?19
+    -- Seats held back for a quota only go to passengers booking under it
+    AND COALESCE((
+        SELECT aq.category FROM active_quota_seats aq
+        WHERE aq.departure_id = d.id AND aq.seat_number = ?4
+    ), 'general') = ?17
+    AND NOT EXISTS (
+        SELECT 1
+        FROM tickets tk
+        WHERE tk.departure_id = d.id
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+        AND tk.seat_number = ?4
+        AND tk.origin_stop < ?6
+        AND ?5 < tk.destination_stop
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM seat_holds sh
+        WHERE sh.departure_id = d.id
+        AND sh.seat_number = ?4
+        AND sh.origin_stop < ?6
+        AND ?5 < sh.destination_stop
+        AND sh.expires_at > CURRENT_TIMESTAMP
+    )
+)
+RETURNING id, departure_id, user_id, seat_number, created_at, expires_at, origin_stop, destination_stop, fare_amount, fare_currency, passenger_name, passenger_age, passenger_gender, passenger_id_document, itinerary_id, leg, legs, quota, concession_ref, passenger, passengers, channel;
+
+-- name: GetItineraryHolds :many
+SELECT sh.id, sh.departure_id, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, sd.name AS destination_name,
+       sh.seat_number, s.label AS seat_label, c.class,
+       sh.fare_amount, sh.fare_currency, sh.expires_at,
+       sh.passenger_name, sh.passenger_age, sh.leg, sh.legs, sh.passenger, sh.passengers, sh.channel
+FROM seat_holds sh
+JOIN departures d ON sh.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = sh.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = sh.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = sh.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE sh.user_id = ?2
+AND (sh.id = ?1 OR sh.itinerary_id = (SELECT h.itinerary_id FROM seat_holds h WHERE h.id = ?1))
+ORDER BY sh.passenger, sh.leg;
+
+-- name: ConfirmSeatHold :one
+INSERT INTO tickets (id, departure_id, user_id, seat_number, origin_stop, destination_stop, fare_amount, fare_currency,
+                     passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id,
+                     quota, concession_ref)
+SELECT ?1, sh.departure_id, sh.user_id, sh.seat_number, sh.origin_stop, sh.destination_stop, sh.fare_amount, sh.fare_currency,
+       sh.passenger_name, sh.passenger_age, sh.passenger_gender, sh.passenger_id_document, ?4,
+       sh.quota, sh.concession_ref
+FROM seat_holds sh
+WHERE sh.id = ?2
+AND sh.user_id = ?3
+AND sh.expires_at > CURRENT_TIMESTAMP
+RETURNING id, departure_id, user_id, seat_number, origin_stop, destination_stop, booked_at, fare_amount, fare_currency, passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id, status, quota, concession_ref;
+
+-- name: DeleteSeatHold :exec
+DELETE FROM seat_holds
+WHERE id = ? AND user_id = ?;
+
+-- name: DeleteItineraryHolds :exec
+DELETE FROM seat_holds
+WHERE user_id = ?2
+AND (id = ?1 OR itinerary_id = (SELECT h.itinerary_id FROM seat_holds h WHERE h.id = ?1));
+
+-- name: DeleteExpiredSeatHolds :many
+DELETE FROM seat_holds
+WHERE expires_at <= CURRENT_TIMESTAMP
+RETURNING id, departure_id, seat_number;
+
+-- name: ReleaseItineraryHolds :many
+DELETE FROM seat_holds
+WHERE user_id = ?2
+AND (id = ?1 OR itinerary_id = (SELECT h.itinerary_id FROM seat_holds h WHERE h.id = ?1))
+RETURNING id, departure_id, seat_number;
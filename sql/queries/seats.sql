@@ -0,0 +1,97 @@
+-- name: FindAvailableSeat :one
+SELECT s.seat_number, s.label, c.class
+FROM departures d
+JOIN coaches c ON c.train_id = d.train_id AND c.class = ?2
+JOIN seats s ON s.coach_id = c.id AND s.retired = 0
+WHERE d.id = ?1
+AND NOT EXISTS (
+    SELECT 1 FROM tickets tk
+    WHERE tk.departure_id = d.id
+    AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+    AND tk.seat_number = s.seat_number
+    AND tk.origin_stop < ?4
+    AND ?3 < tk.destination_stop
+)
+AND NOT EXISTS (
+    SELECT 1 FROM seat_holds sh
+    WHERE sh.departure_id = d.id
+    AND sh.seat_number = s.seat_number
+    AND sh.origin_stop < ?4
+    AND ?3 < sh.destination_stop
+    AND sh.expires_at > CURRENT_TIMESTAMP
+)
+AND COALESCE((
+    SELECT aq.category FROM active_quota_seats aq
+    WHERE aq.departure_id = d.id AND aq.seat_number = s.seat_number
+), 'general') = ?6
+ORDER BY
+    CASE ?5
+        WHEN 'window' THEN s.is_window
+        WHEN 'aisle' THEN s.is_aisle
+        WHEN 'lower' THEN s.berth_type IN ('lower', 'side_lower')
+        WHEN 'upper' THEN s.berth_type IN ('upper', 'side_upper')
+        WHEN 'wheelchair' THEN s.wheelchair_space
+        ELSE 0
+    END DESC,
+    -- Keep wheelchair spaces for passengers who ask for them
+    s.wheelchair_space,
+    c.position,
+    s.seat_number
+LIMIT 1;
+
+-- name: GetDepartureSeat :one
+SELECT s.seat_number, s.label, c.code AS coach_code, c.class,
+       s.is_window, s.is_aisle, s.berth_type, s.wheelchair_space,
+       CAST(COALESCE((
+           SELECT aq.category FROM active_quota_seats aq
+           WHERE aq.departure_id = d.id AND aq.seat_number = s.seat_number
+       ), 'general') AS TEXT) AS quota
+FROM departures d
+JOIN seats s ON s.train_id = d.train_id
+JOIN coaches c ON s.coach_id = c.id
+WHERE d.id = ? AND s.seat_number = ?;
+
+-- name: AddCoachSeat :exec
+INSERT INTO seats (train_id, seat_number, coach_id, label, is_window, is_aisle, berth_type, wheelchair_space)
+SELECT c.train_id,
+       (SELECT COALESCE(MAX(s.seat_number), 0) + 1 FROM seats s WHERE s.train_id = c.train_id),
+       c.id, ?2, ?3, ?4, ?5, ?6
+FROM coaches c
+WHERE c.id = ?1;
+
+-- name: RestoreCoachSeats :execrows
+UPDATE seats
+SET retired = 0
+WHERE coach_id = ?1
+AND seat_number IN (
+    SELECT s.seat_number FROM seats s
+    WHERE s.coach_id = ?1 AND s.retired = 1
+    ORDER BY s.seat_number
+    LIMIT ?2
+);
+
+-- name: RetireCoachSeats :execrows
+UPDATE seats
+SET retired = 1
+WHERE coach_id = ?1
+AND seat_number IN (
+    SELECT s.seat_number FROM seats s
+    JOIN coaches c ON s.coach_id = c.id
+    WHERE s.coach_id = ?1 AND s.retired = 0
+    AND NOT EXISTS (
+        SELECT 1 FROM tickets tk
+        JOIN departures d ON tk.departure_id = d.id
+        WHERE d.train_id = c.train_id
+        AND tk.seat_number = s.seat_number
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+        AND d.arrives_at > CURRENT_TIMESTAMP
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM seat_holds sh
+        WHERE sh.departure_id IN (SELECT d.id FROM departures d WHERE d.train_id = c.train_id)
+        AND sh.seat_number = s.seat_number
+        AND sh.expires_at > CURRENT_TIMESTAMP
+    )
+    ORDER BY s.seat_number DESC
+    LIMIT ?2
+);
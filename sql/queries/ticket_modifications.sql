@@ -0,0 +1,4 @@
+-- name: CreateTicketModification :one
+INSERT INTO ticket_modifications (id, old_ticket_id, new_ticket_id, old_fare_amount, new_fare_amount, fare_difference, currency)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, old_ticket_id, new_ticket_id, old_fare_amount, new_fare_amount, fare_difference, currency, modified_at;
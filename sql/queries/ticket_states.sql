@@ -0,0 +1,20 @@
+-- name: CreateTicketTransition :exec
+INSERT INTO ticket_state_transitions (id, ticket_id, from_state, to_state, actor, reason)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListMissedTickets :many
+-- Confirmed tickets whose train has already left the passenger's origin
+SELECT tk.id
+FROM tickets tk
+JOIN departures d ON tk.departure_id = d.id
+JOIN route_stops o ON o.train_id = d.train_id AND o.stop_sequence = tk.origin_stop
+WHERE tk.status = 'confirmed'
+AND datetime(d.departs_at, '+' || o.departure_offset_minutes || ' minutes') < CURRENT_TIMESTAMP
+ORDER BY d.departs_at
+LIMIT ?;
+
+-- name: TransitionTicket :one
+UPDATE tickets
+SET status = ?3
+WHERE id = ?1 AND status = ?2
+RETURNING departure_id, seat_number;
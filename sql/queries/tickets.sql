@@ -1,33 +1,144 @@
 -- name: CreateTicket :one
-INSERT INTO tickets (id, train_id, user_id, seat_number)
-SELECT ?1, ?2, ?3, ?4
+INSERT INTO tickets (id, departure_id, user_id, seat_number, origin_stop, destination_stop, fare_amount, fare_currency,
+                     passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id)
+SELECT ?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13
 WHERE EXISTS (
     SELECT 1 
-    FROM trains t
-    WHERE t.id = ?2 
-    AND ?4 BETWEEN 1 AND t.total_seats
+    FROM departures d
+    JOIN trains t ON d.train_id = t.id
+    WHERE d.id = ?2 
+    AND d.status = 'scheduled'
+    AND d.departs_at > CURRENT_TIMESTAMP
+    AND EXISTS (SELECT 1 FROM seats s WHERE s.train_id = t.id AND s.seat_number = ?4 AND s.retired = 0)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?5)
+    AND EXISTS (SELECT 1 FROM route_stops rs WHERE rs.train_id = t.id AND rs.stop_sequence = ?6)
+    AND ?5 < ?6
+    AND COALESCE((
+        SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+        FROM sale_windows sw
+        JOIN seats s ON s.train_id = t.id AND s.seat_number = ?4
+        JOIN coaches c ON s.coach_id = c.id
+        WHERE sw.train_id = t.id AND sw.class IN (c.class, '')
+        ORDER BY sw.class DESC
+        LIMIT 1
+    ), '') <= ?14
+    -- Tickets issued directly, on modification or from the waitlist, are general
+    AND NOT EXISTS (
+        SELECT 1 FROM active_quota_seats aq
+        WHERE aq.departure_id = d.id AND aq.seat_number = ?4
+    )
     AND NOT EXISTS (
         SELECT 1 
         FROM tickets tk 
-        WHERE tk.train_id = t.id 
+        WHERE tk.departure_id = d.id 
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
         AND tk.seat_number = ?4
+        AND tk.origin_stop < ?6
+        AND ?5 < tk.destination_stop
+    )
+    AND NOT EXISTS (
+        SELECT 1
+        FROM seat_holds sh
+        WHERE sh.departure_id = d.id
+        AND sh.seat_number = ?4
+        AND sh.origin_stop < ?6
+        AND ?5 < sh.destination_stop
+        AND sh.expires_at > CURRENT_TIMESTAMP
     )
 )
-RETURNING id, train_id, user_id, seat_number, booked_at;
-
--- name: DeleteTicket :exec
-DELETE FROM tickets
-WHERE id = ? AND user_id = ?;
+RETURNING id, departure_id, user_id, seat_number, origin_stop, destination_stop, booked_at, fare_amount, fare_currency, passenger_name, passenger_age, passenger_gender, passenger_id_document, booking_id, status, quota, concession_ref;
 
 -- name: GetAvailableTickets :many
-SELECT t.id, t.name, t.total_seats, 
-       (t.total_seats - COUNT(tk.id)) AS available_seats
-FROM trains t
-LEFT JOIN tickets tk ON t.id = tk.train_id
-GROUP BY t.id, t.name, t.total_seats;
+SELECT d.id, t.name, d.service_date, d.departs_at, d.arrives_at,
+       o.stop_sequence AS origin_stop, o.departure_offset_minutes,
+       ds.stop_sequence AS destination_stop, ds.arrival_offset_minutes,
+       CAST(ds.distance_km - o.distance_km AS INTEGER) AS distance_km,
+       c.class,
+       CAST(COALESCE((
+           SELECT aq.category FROM active_quota_seats aq
+           WHERE aq.departure_id = d.id AND aq.seat_number = s.seat_number
+       ), 'general') AS TEXT) AS quota,
+       CAST(COALESCE((
+           SELECT datetime(date(d.service_date, '-' || sw.opens_days_before || ' days') || ' ' || sw.opens_time)
+           FROM sale_windows sw
+           WHERE sw.train_id = d.train_id AND sw.class IN (c.class, '')
+           ORDER BY sw.class DESC
+           LIMIT 1
+       ), '') AS TEXT) AS sales_open_at,
+       COUNT(s.seat_number) AS total_seats, 
+       CAST(SUM(
+           NOT EXISTS (
+               SELECT 1 FROM tickets tk
+               WHERE tk.departure_id = d.id
+               AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+               AND tk.seat_number = s.seat_number
+               AND tk.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < tk.destination_stop
+           )
+           AND NOT EXISTS (
+               SELECT 1 FROM seat_holds sh
+               WHERE sh.departure_id = d.id
+               AND sh.seat_number = s.seat_number
+               AND sh.origin_stop < ds.stop_sequence
+               AND o.stop_sequence < sh.destination_stop
+               AND sh.expires_at > CURRENT_TIMESTAMP
+           )
+       ) AS INTEGER) AS available_seats
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+JOIN route_stops o ON o.train_id = t.id AND o.station_id = ?1
+JOIN route_stops ds ON ds.train_id = t.id AND ds.station_id = ?2
+JOIN coaches c ON c.train_id = t.id
+JOIN seats s ON s.coach_id = c.id AND s.retired = 0
+WHERE o.stop_sequence < ds.stop_sequence
+AND d.status = 'scheduled'
+AND d.departs_at > CURRENT_TIMESTAMP
+-- Optionally only trains leaving the origin from the first UTC time and before the second
+AND (?3 = '' OR datetime(d.departs_at, '+' || o.departure_offset_minutes || ' minutes') >= ?3)
+AND (?4 = '' OR datetime(d.departs_at, '+' || o.departure_offset_minutes || ' minutes') < ?4)
+GROUP BY d.id, c.class, quota
+HAVING available_seats >= ?5
+ORDER BY d.departs_at, c.class, quota;
+
+-- name: GetTicket :one
+SELECT tk.id, tk.user_id, tk.booking_id, tk.status, tk.departure_id, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, o.departure_offset_minutes,
+       sd.name AS destination_name, ds.arrival_offset_minutes,
+       tk.seat_number, s.label AS seat_label, c.class,
+       tk.fare_amount, tk.fare_currency, tk.passenger_name,
+       tk.passenger_age, tk.passenger_gender, tk.passenger_id_document,
+       o.station_id AS origin_station_id, ds.station_id AS destination_station_id,
+       b.pnr
+FROM tickets tk
+LEFT JOIN bookings b ON tk.booking_id = b.id
+JOIN departures d ON tk.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.id = ?;
 
 -- name: GetUserTickets :many
-SELECT tk.id, t.name, tk.seat_number, tk.booked_at
+SELECT tk.id, b.pnr, t.name, d.service_date, d.departs_at,
+       so.name AS origin_name, o.departure_offset_minutes,
+       sd.name AS destination_name, ds.arrival_offset_minutes,
+       tk.seat_number, s.label AS seat_label, c.class,
+       tk.fare_amount, tk.fare_currency, tk.booked_at,
+       tk.passenger_name, tk.passenger_age, tk.status, cn.refund_amount
 FROM tickets tk
-JOIN trains t ON tk.train_id = t.id
-WHERE tk.user_id = ?;
\ No newline at end of file
+JOIN bookings b ON tk.booking_id = b.id
+LEFT JOIN cancellations cn ON cn.ticket_id = tk.id
+JOIN departures d ON tk.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN seats s ON s.train_id = t.id AND s.seat_number = tk.seat_number
+JOIN coaches c ON s.coach_id = c.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = tk.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = tk.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE tk.user_id = ?1
+AND (?2 = '' OR tk.status = ?2)
+ORDER BY d.departs_at;
@@ -0,0 +1,22 @@
+-- name: CreateTimetableRule :one
+INSERT INTO timetable_rules (id, train_id, frequency, days_of_week, departure_time, valid_from, valid_until)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetTimetableRule :one
+SELECT * FROM timetable_rules
+WHERE id = ?;
+
+-- name: ListTimetableRules :many
+SELECT * FROM timetable_rules
+ORDER BY train_id, departure_time;
+
+-- name: AddTimetableException :exec
+INSERT INTO timetable_exceptions (rule_id, service_date)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: ListTimetableExceptions :many
+SELECT service_date FROM timetable_exceptions
+WHERE rule_id = ?
+ORDER BY service_date;
@@ -0,0 +1,27 @@
+-- name: CreateTrain :one
+INSERT INTO trains (id, name, total_seats)
+VALUES (?, ?, 0)
+RETURNING *;
+
+-- name: GetTrain :one
+SELECT * FROM trains
+WHERE id = ?;
+
+-- name: ListTrains :many
+SELECT * FROM trains
+ORDER BY status, name;
+
+-- name: RenameTrain :execrows
+UPDATE trains
+SET name = ?
+WHERE id = ?;
+
+-- name: RetireTrain :execrows
+UPDATE trains
+SET status = 'retired'
+WHERE id = ? AND status = 'active';
+
+-- name: UpdateTrainCapacity :exec
+UPDATE trains
+SET total_seats = (SELECT COUNT(*) FROM seats s WHERE s.train_id = trains.id AND s.retired = 0)
+WHERE id = ?;
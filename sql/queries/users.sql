@@ -10,4 +10,19 @@ SELECT
 FROM
     users
 WHERE
-    email = ?;
\ No newline at end of file
+    email = ?;
+
+-- name: GetUser :one
+SELECT
+    *
+FROM
+    users
+WHERE
+    id = ?;
+
+-- name: SetUserRole :execrows
+UPDATE users
+SET
+    role = ?
+WHERE
+    email = ?;
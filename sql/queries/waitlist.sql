@@ -0,0 +1,92 @@
+-- name: JoinWaitlist :one
+INSERT INTO waitlist_entries (id, departure_id, user_id, seq, origin_stop, destination_stop,
+                              passenger_name, passenger_age, passenger_gender, passenger_id_document)
+SELECT ?1, d.id, ?3,
+       (SELECT COALESCE(MAX(w.seq), 0) + 1 FROM waitlist_entries w WHERE w.departure_id = d.id),
+       ?4, ?5, ?6, ?7, ?8, ?9
+FROM departures d
+JOIN trains t ON d.train_id = t.id
+WHERE d.id = ?2
+AND d.status = 'scheduled'
+AND d.departs_at > CURRENT_TIMESTAMP
+AND ?4 < ?5
+AND NOT EXISTS (
+    SELECT 1 FROM seats s
+    WHERE s.train_id = t.id
+    AND s.retired = 0
+    AND NOT EXISTS (
+        SELECT 1 FROM active_quota_seats aq
+        WHERE aq.departure_id = d.id AND aq.seat_number = s.seat_number
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM tickets tk
+        WHERE tk.departure_id = d.id
+        AND tk.status IN ('confirmed', 'checked_in', 'boarded')
+        AND tk.seat_number = s.seat_number
+        AND tk.origin_stop < ?5
+        AND ?4 < tk.destination_stop
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM seat_holds sh
+        WHERE sh.departure_id = d.id
+        AND sh.seat_number = s.seat_number
+        AND sh.origin_stop < ?5
+        AND ?4 < sh.destination_stop
+        AND sh.expires_at > CURRENT_TIMESTAMP
+    )
+)
+RETURNING id, departure_id, user_id, seq, status, ticket_id, created_at, origin_stop, destination_stop, passenger_name, passenger_age, passenger_gender, passenger_id_document, hold_id;
+
+-- name: LeaveWaitlist :exec
+UPDATE waitlist_entries
+SET status = 'left'
+WHERE id = ? AND user_id = ? AND status = 'waiting';
+
+-- name: GetWaitingEntries :many
+SELECT id, departure_id, user_id, seq, status, ticket_id, created_at, origin_stop, destination_stop,
+       passenger_name, passenger_age, passenger_gender, passenger_id_document, hold_id
+FROM waitlist_entries
+WHERE departure_id = ? AND status = 'waiting'
+ORDER BY seq;
+
+-- name: MarkWaitlistPromoted :exec
+UPDATE waitlist_entries
+SET status = 'promoted', hold_id = ?
+WHERE id = ?;
+
+-- name: SetWaitlistTicket :exec
+UPDATE waitlist_entries
+SET ticket_id = ?2
+WHERE hold_id = ?1;
+
+-- name: GetUserWaitlist :many
+SELECT w.id, t.name, d.service_date, so.name AS origin_name, sd.name AS destination_name,
+       w.passenger_name,
+       (SELECT COUNT(*) FROM waitlist_entries w2
+        WHERE w2.departure_id = w.departure_id
+        AND w2.status = 'waiting'
+        AND w2.seq <= w.seq) AS position
+FROM waitlist_entries w
+JOIN departures d ON w.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = w.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = w.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE w.user_id = ? AND w.status = 'waiting'
+ORDER BY d.departs_at;
+
+-- name: GetUserWaitlistOffers :many
+SELECT w.id, sh.id AS hold_id, t.name, d.service_date, so.name AS origin_name, sd.name AS destination_name,
+       w.passenger_name, sh.fare_amount, sh.fare_currency, sh.expires_at
+FROM waitlist_entries w
+JOIN seat_holds sh ON sh.id = w.hold_id
+JOIN departures d ON w.departure_id = d.id
+JOIN trains t ON d.train_id = t.id
+JOIN route_stops o ON o.train_id = t.id AND o.stop_sequence = w.origin_stop
+JOIN stations so ON o.station_id = so.id
+JOIN route_stops ds ON ds.train_id = t.id AND ds.stop_sequence = w.destination_stop
+JOIN stations sd ON ds.station_id = sd.id
+WHERE w.user_id = ? AND w.status = 'promoted' AND w.ticket_id IS NULL
+AND sh.expires_at > CURRENT_TIMESTAMP
+ORDER BY sh.expires_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE
+    seat_holds (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+CREATE INDEX idx_seat_holds_train_seat ON seat_holds (train_id, seat_number);
+
+CREATE INDEX idx_seat_holds_expires_at ON seat_holds (expires_at);
+
+-- +goose Down
+DROP TABLE seat_holds;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE
+    waitlist_entries (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seq INTEGER NOT NULL,
+        status TEXT NOT NULL DEFAULT 'waiting',
+        ticket_id TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (train_id, seq)
+    );
+
+CREATE UNIQUE INDEX idx_waitlist_entries_waiting ON waitlist_entries (train_id, user_id)
+WHERE
+    status = 'waiting';
+
+-- +goose Down
+DROP TABLE waitlist_entries;
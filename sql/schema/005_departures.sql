@@ -0,0 +1,243 @@
+-- +goose Up
+CREATE TABLE
+    departures (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        service_date TEXT NOT NULL,
+        departs_at TIMESTAMP NOT NULL,
+        arrives_at TIMESTAMP NOT NULL,
+        status TEXT NOT NULL DEFAULT 'scheduled',
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        UNIQUE (train_id, departs_at)
+    );
+
+-- Existing tickets have no date. Each train gets one legacy departure, dated the day
+-- of the migration and reusing the train's ID, that its existing bookings move onto.
+INSERT INTO
+    departures (id, train_id, service_date, departs_at, arrives_at, status)
+SELECT
+    id,
+    id,
+    date('now'),
+    datetime('now'),
+    datetime('now'),
+    'scheduled'
+FROM
+    trains;
+
+-- Seed a week of departures for the default trains
+WITH RECURSIVE
+    days (n) AS (
+        SELECT 1
+        UNION ALL
+        SELECT n + 1 FROM days WHERE n < 7
+    )
+INSERT INTO
+    departures (id, train_id, service_date, departs_at, arrives_at)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    s.train_id,
+    date('now', '+' || days.n || ' days'),
+    datetime(date('now', '+' || days.n || ' days'), s.departs_offset),
+    datetime(date('now', '+' || days.n || ' days'), s.arrives_offset)
+FROM
+    days,
+    (
+        SELECT '550e8400-e29b-41d4-a716-446655440000' AS train_id, '+8 hours' AS departs_offset, '+14 hours' AS arrives_offset
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440001', '+22 hours', '+30 hours'
+    ) s
+WHERE
+    s.train_id IN (SELECT id FROM trains);
+
+CREATE TABLE
+    tickets_new (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        booked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (departure_id) REFERENCES departures (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (departure_id, seat_number)
+    );
+
+INSERT INTO
+    tickets_new (id, departure_id, user_id, seat_number, booked_at)
+SELECT
+    id,
+    train_id,
+    user_id,
+    seat_number,
+    booked_at
+FROM
+    tickets;
+
+DROP TABLE tickets;
+
+ALTER TABLE tickets_new
+RENAME TO tickets;
+
+CREATE TABLE
+    seat_holds_new (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        FOREIGN KEY (departure_id) REFERENCES departures (id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+INSERT INTO
+    seat_holds_new (id, departure_id, user_id, seat_number, created_at, expires_at)
+SELECT
+    id,
+    train_id,
+    user_id,
+    seat_number,
+    created_at,
+    expires_at
+FROM
+    seat_holds;
+
+DROP TABLE seat_holds;
+
+ALTER TABLE seat_holds_new
+RENAME TO seat_holds;
+
+CREATE INDEX idx_seat_holds_departure_seat ON seat_holds (departure_id, seat_number);
+
+CREATE INDEX idx_seat_holds_expires_at ON seat_holds (expires_at);
+
+CREATE TABLE
+    waitlist_entries_new (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seq INTEGER NOT NULL,
+        status TEXT NOT NULL DEFAULT 'waiting',
+        ticket_id TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (departure_id) REFERENCES departures (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (departure_id, seq)
+    );
+
+INSERT INTO
+    waitlist_entries_new (id, departure_id, user_id, seq, status, ticket_id, created_at)
+SELECT
+    id,
+    train_id,
+    user_id,
+    seq,
+    status,
+    ticket_id,
+    created_at
+FROM
+    waitlist_entries;
+
+DROP TABLE waitlist_entries;
+
+ALTER TABLE waitlist_entries_new
+RENAME TO waitlist_entries;
+
+CREATE UNIQUE INDEX idx_waitlist_entries_waiting ON waitlist_entries (departure_id, user_id)
+WHERE
+    status = 'waiting';
+
+-- +goose Down
+CREATE TABLE
+    waitlist_entries_old (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seq INTEGER NOT NULL,
+        status TEXT NOT NULL DEFAULT 'waiting',
+        ticket_id TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (train_id, seq)
+    );
+
+-- Waitlists of different departures of one train collapse into one queue
+INSERT INTO
+    waitlist_entries_old (id, train_id, user_id, seq, status, ticket_id, created_at)
+SELECT
+    w.id,
+    d.train_id,
+    w.user_id,
+    ROW_NUMBER() OVER (PARTITION BY d.train_id ORDER BY w.created_at, w.seq),
+    w.status,
+    w.ticket_id,
+    w.created_at
+FROM
+    waitlist_entries w
+    JOIN departures d ON w.departure_id = d.id;
+
+DROP TABLE waitlist_entries;
+
+ALTER TABLE waitlist_entries_old
+RENAME TO waitlist_entries;
+
+CREATE UNIQUE INDEX idx_waitlist_entries_waiting ON waitlist_entries (train_id, user_id)
+WHERE
+    status = 'waiting';
+
+CREATE TABLE
+    seat_holds_old (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+-- Holds are short-lived; dropping them on rollback only releases seats early
+DROP TABLE seat_holds;
+
+ALTER TABLE seat_holds_old
+RENAME TO seat_holds;
+
+CREATE INDEX idx_seat_holds_train_seat ON seat_holds (train_id, seat_number);
+
+CREATE INDEX idx_seat_holds_expires_at ON seat_holds (expires_at);
+
+CREATE TABLE
+    tickets_old (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        booked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (train_id, seat_number)
+    );
+
+-- Only the legacy departures map back onto a timeless train without seat clashes
+INSERT INTO
+    tickets_old (id, train_id, user_id, seat_number, booked_at)
+SELECT
+    tk.id,
+    d.train_id,
+    tk.user_id,
+    tk.seat_number,
+    tk.booked_at
+FROM
+    tickets tk
+    JOIN departures d ON tk.departure_id = d.id
+WHERE
+    d.id = d.train_id;
+
+DROP TABLE tickets;
+
+ALTER TABLE tickets_old
+RENAME TO tickets;
+
+DROP TABLE departures;
@@ -0,0 +1,167 @@
+-- +goose Up
+CREATE TABLE
+    stations (
+        id TEXT PRIMARY KEY,
+        code TEXT UNIQUE NOT NULL,
+        name TEXT NOT NULL
+    );
+
+-- Offsets are minutes after the departure's departs_at
+CREATE TABLE
+    route_stops (
+        train_id TEXT NOT NULL,
+        stop_sequence INTEGER NOT NULL,
+        station_id TEXT NOT NULL,
+        arrival_offset_minutes INTEGER NOT NULL,
+        departure_offset_minutes INTEGER NOT NULL,
+        distance_km INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (train_id, stop_sequence),
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (station_id) REFERENCES stations (id),
+        UNIQUE (train_id, station_id)
+    );
+
+INSERT INTO
+    stations (id, code, name)
+VALUES
+    ('7c9e6679-7425-40de-944b-e07fc1f90ae1', 'CEN', 'Central'),
+    ('7c9e6679-7425-40de-944b-e07fc1f90ae2', 'RIV', 'Riverside'),
+    ('7c9e6679-7425-40de-944b-e07fc1f90ae3', 'HIL', 'Hillview'),
+    ('7c9e6679-7425-40de-944b-e07fc1f90ae4', 'HAR', 'Harbour');
+
+INSERT INTO
+    route_stops (train_id, stop_sequence, station_id, arrival_offset_minutes, departure_offset_minutes, distance_km)
+SELECT
+    r.train_id,
+    r.stop_sequence,
+    r.station_id,
+    r.arrival_offset_minutes,
+    r.departure_offset_minutes,
+    r.distance_km
+FROM
+    (
+        SELECT '550e8400-e29b-41d4-a716-446655440000' AS train_id, 1 AS stop_sequence, '7c9e6679-7425-40de-944b-e07fc1f90ae1' AS station_id, 0 AS arrival_offset_minutes, 0 AS departure_offset_minutes, 0 AS distance_km
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440000', 2, '7c9e6679-7425-40de-944b-e07fc1f90ae2', 115, 120, 150
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440000', 3, '7c9e6679-7425-40de-944b-e07fc1f90ae3', 235, 240, 320
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440000', 4, '7c9e6679-7425-40de-944b-e07fc1f90ae4', 360, 360, 480
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440001', 1, '7c9e6679-7425-40de-944b-e07fc1f90ae4', 0, 0, 0
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440001', 2, '7c9e6679-7425-40de-944b-e07fc1f90ae3', 170, 180, 160
+        UNION ALL
+        SELECT '550e8400-e29b-41d4-a716-446655440001', 3, '7c9e6679-7425-40de-944b-e07fc1f90ae1', 480, 480, 480
+    ) r
+WHERE
+    r.train_id IN (SELECT id FROM trains);
+
+-- Tickets, holds and waitlist entries cover the stops [origin_stop, destination_stop).
+-- Existing rows are for whole trains, so they span the full route.
+CREATE TABLE
+    tickets_new (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        origin_stop INTEGER NOT NULL,
+        destination_stop INTEGER NOT NULL,
+        booked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (departure_id) REFERENCES departures (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        CHECK (origin_stop < destination_stop)
+    );
+
+INSERT INTO
+    tickets_new (id, departure_id, user_id, seat_number, origin_stop, destination_stop, booked_at)
+SELECT
+    tk.id,
+    tk.departure_id,
+    tk.user_id,
+    tk.seat_number,
+    COALESCE((SELECT MIN(rs.stop_sequence) FROM route_stops rs WHERE rs.train_id = d.train_id), 1),
+    COALESCE((SELECT MAX(rs.stop_sequence) FROM route_stops rs WHERE rs.train_id = d.train_id), 2),
+    tk.booked_at
+FROM
+    tickets tk
+    JOIN departures d ON tk.departure_id = d.id;
+
+DROP TABLE tickets;
+
+ALTER TABLE tickets_new
+RENAME TO tickets;
+
+CREATE INDEX idx_tickets_departure_seat ON tickets (departure_id, seat_number);
+
+CREATE INDEX idx_tickets_user ON tickets (user_id);
+
+ALTER TABLE seat_holds
+ADD COLUMN origin_stop INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE seat_holds
+ADD COLUMN destination_stop INTEGER NOT NULL DEFAULT 2;
+
+UPDATE seat_holds
+SET
+    origin_stop = COALESCE((SELECT MIN(rs.stop_sequence) FROM route_stops rs JOIN departures d ON rs.train_id = d.train_id WHERE d.id = seat_holds.departure_id), 1),
+    destination_stop = COALESCE((SELECT MAX(rs.stop_sequence) FROM route_stops rs JOIN departures d ON rs.train_id = d.train_id WHERE d.id = seat_holds.departure_id), 2);
+
+ALTER TABLE waitlist_entries
+ADD COLUMN origin_stop INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE waitlist_entries
+ADD COLUMN destination_stop INTEGER NOT NULL DEFAULT 2;
+
+UPDATE waitlist_entries
+SET
+    origin_stop = COALESCE((SELECT MIN(rs.stop_sequence) FROM route_stops rs JOIN departures d ON rs.train_id = d.train_id WHERE d.id = waitlist_entries.departure_id), 1),
+    destination_stop = COALESCE((SELECT MAX(rs.stop_sequence) FROM route_stops rs JOIN departures d ON rs.train_id = d.train_id WHERE d.id = waitlist_entries.departure_id), 2);
+
+-- +goose Down
+ALTER TABLE waitlist_entries
+DROP COLUMN destination_stop;
+
+ALTER TABLE waitlist_entries
+DROP COLUMN origin_stop;
+
+ALTER TABLE seat_holds
+DROP COLUMN destination_stop;
+
+ALTER TABLE seat_holds
+DROP COLUMN origin_stop;
+
+CREATE TABLE
+    tickets_old (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL,
+        user_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        booked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (departure_id) REFERENCES departures (id),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        UNIQUE (departure_id, seat_number)
+    );
+
+-- Where a seat was resold along the route, only its earliest booking survives
+INSERT OR IGNORE INTO
+    tickets_old (id, departure_id, user_id, seat_number, booked_at)
+SELECT
+    id,
+    departure_id,
+    user_id,
+    seat_number,
+    booked_at
+FROM
+    tickets
+ORDER BY
+    booked_at;
+
+DROP TABLE tickets;
+
+ALTER TABLE tickets_old
+RENAME TO tickets;
+
+DROP TABLE route_stops;
+
+DROP TABLE stations;
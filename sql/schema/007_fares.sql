@@ -0,0 +1,27 @@
+-- +goose Up
+-- Fares are quoted when a seat is held and locked into the ticket on confirmation.
+-- Amounts are in the currency's minor unit.
+ALTER TABLE seat_holds
+ADD COLUMN fare_amount INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE seat_holds
+ADD COLUMN fare_currency TEXT NOT NULL DEFAULT 'INR';
+
+ALTER TABLE tickets
+ADD COLUMN fare_amount INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE tickets
+ADD COLUMN fare_currency TEXT NOT NULL DEFAULT 'INR';
+
+-- +goose Down
+ALTER TABLE tickets
+DROP COLUMN fare_currency;
+
+ALTER TABLE tickets
+DROP COLUMN fare_amount;
+
+ALTER TABLE seat_holds
+DROP COLUMN fare_currency;
+
+ALTER TABLE seat_holds
+DROP COLUMN fare_amount;
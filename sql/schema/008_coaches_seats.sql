@@ -0,0 +1,132 @@
+-- +goose Up
+CREATE TABLE
+    coaches (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL,
+        code TEXT NOT NULL,
+        class TEXT NOT NULL,
+        position INTEGER NOT NULL,
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        UNIQUE (train_id, code),
+        UNIQUE (train_id, position)
+    );
+
+-- seat_number stays unique across the whole train so tickets can keep referring to it
+CREATE TABLE
+    seats (
+        train_id TEXT NOT NULL,
+        seat_number INTEGER NOT NULL,
+        coach_id TEXT NOT NULL,
+        label TEXT NOT NULL,
+        is_window BOOLEAN NOT NULL DEFAULT 0,
+        is_aisle BOOLEAN NOT NULL DEFAULT 0,
+        berth_type TEXT,
+        wheelchair_space BOOLEAN NOT NULL DEFAULT 0,
+        PRIMARY KEY (train_id, seat_number),
+        FOREIGN KEY (train_id) REFERENCES trains (id),
+        FOREIGN KEY (coach_id) REFERENCES coaches (id)
+    );
+
+CREATE INDEX idx_seats_coach ON seats (coach_id);
+
+-- The default trains get a mixed composition; any other train becomes a single
+-- second class coach covering its existing 1..total_seats range.
+CREATE TABLE
+    coach_layout (
+        id TEXT,
+        train_id TEXT,
+        code TEXT,
+        class TEXT,
+        position INTEGER,
+        first_seat INTEGER,
+        last_seat INTEGER
+    );
+
+INSERT INTO
+    coach_layout (train_id, code, class, position, first_seat, last_seat)
+VALUES
+    ('550e8400-e29b-41d4-a716-446655440000', 'CC1', 'ac_chair', 1, 1, 20),
+    ('550e8400-e29b-41d4-a716-446655440000', 'D1', 'second', 2, 21, 50),
+    ('550e8400-e29b-41d4-a716-446655440001', 'S1', 'sleeper', 1, 1, 32),
+    ('550e8400-e29b-41d4-a716-446655440001', 'D1', 'second', 2, 33, 60);
+
+DELETE FROM coach_layout
+WHERE
+    last_seat > (SELECT total_seats FROM trains WHERE trains.id = coach_layout.train_id)
+    OR train_id NOT IN (SELECT id FROM trains);
+
+INSERT INTO
+    coach_layout (train_id, code, class, position, first_seat, last_seat)
+SELECT
+    id,
+    'D1',
+    'second',
+    1,
+    1,
+    total_seats
+FROM
+    trains
+WHERE
+    id NOT IN (SELECT train_id FROM coach_layout);
+
+UPDATE coach_layout
+SET
+    id = lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)));
+
+INSERT INTO
+    coaches (id, train_id, code, class, position)
+SELECT
+    id,
+    train_id,
+    code,
+    class,
+    position
+FROM
+    coach_layout;
+
+-- Chair coaches are laid out 2+2 (window, aisle, aisle, window); sleeper bays hold
+-- lower, middle and upper berths on each side plus a side lower and side upper.
+WITH RECURSIVE
+    n (i) AS (
+        SELECT 1
+        UNION ALL
+        SELECT i + 1 FROM n WHERE i < (SELECT MAX(last_seat) FROM coach_layout)
+    )
+INSERT INTO
+    seats (train_id, seat_number, coach_id, label, is_window, is_aisle, berth_type, wheelchair_space)
+SELECT
+    cl.train_id,
+    n.i,
+    cl.id,
+    cl.code || '-' || (n.i - cl.first_seat + 1),
+    CASE
+        WHEN cl.class = 'sleeper' THEN (n.i - cl.first_seat) % 8 = 6
+        ELSE (n.i - cl.first_seat) % 4 IN (0, 3)
+    END,
+    CASE
+        WHEN cl.class = 'sleeper' THEN (n.i - cl.first_seat) % 8 IN (6, 7)
+        ELSE (n.i - cl.first_seat) % 4 IN (1, 2)
+    END,
+    CASE
+        WHEN cl.class = 'sleeper' THEN CASE (n.i - cl.first_seat) % 8
+            WHEN 0 THEN 'lower'
+            WHEN 1 THEN 'middle'
+            WHEN 2 THEN 'upper'
+            WHEN 3 THEN 'lower'
+            WHEN 4 THEN 'middle'
+            WHEN 5 THEN 'upper'
+            WHEN 6 THEN 'side_lower'
+            ELSE 'side_upper'
+        END
+    END,
+    cl.class = 'second' AND n.i = cl.first_seat
+FROM
+    coach_layout cl
+    JOIN n ON n.i BETWEEN cl.first_seat AND cl.last_seat;
+
+DROP TABLE coach_layout;
+
+-- +goose Down
+DROP TABLE seats;
+
+DROP TABLE coaches;
@@ -0,0 +1,91 @@
+-- +goose Up
+-- A ticket is issued to a passenger, who need not be the account that booked it.
+-- Passenger details are copied onto holds, waitlist entries and tickets so later
+-- edits to a saved passenger don't rewrite issued tickets.
+CREATE TABLE
+    saved_passengers (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        age INTEGER NOT NULL CHECK (age >= 0),
+        gender TEXT NOT NULL,
+        id_document TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_saved_passengers_user ON saved_passengers (user_id);
+
+ALTER TABLE seat_holds
+ADD COLUMN passenger_name TEXT;
+
+ALTER TABLE seat_holds
+ADD COLUMN passenger_age INTEGER;
+
+ALTER TABLE seat_holds
+ADD COLUMN passenger_gender TEXT;
+
+ALTER TABLE seat_holds
+ADD COLUMN passenger_id_document TEXT;
+
+ALTER TABLE tickets
+ADD COLUMN passenger_name TEXT;
+
+ALTER TABLE tickets
+ADD COLUMN passenger_age INTEGER;
+
+ALTER TABLE tickets
+ADD COLUMN passenger_gender TEXT;
+
+ALTER TABLE tickets
+ADD COLUMN passenger_id_document TEXT;
+
+ALTER TABLE waitlist_entries
+ADD COLUMN passenger_name TEXT;
+
+ALTER TABLE waitlist_entries
+ADD COLUMN passenger_age INTEGER;
+
+ALTER TABLE waitlist_entries
+ADD COLUMN passenger_gender TEXT;
+
+ALTER TABLE waitlist_entries
+ADD COLUMN passenger_id_document TEXT;
+
+-- +goose Down
+ALTER TABLE waitlist_entries
+DROP COLUMN passenger_id_document;
+
+ALTER TABLE waitlist_entries
+DROP COLUMN passenger_gender;
+
+ALTER TABLE waitlist_entries
+DROP COLUMN passenger_age;
+
+ALTER TABLE waitlist_entries
+DROP COLUMN passenger_name;
+
+ALTER TABLE tickets
+DROP COLUMN passenger_id_document;
+
+ALTER TABLE tickets
+DROP COLUMN passenger_gender;
+
+ALTER TABLE tickets
+DROP COLUMN passenger_age;
+
+ALTER TABLE tickets
+DROP COLUMN passenger_name;
+
+ALTER TABLE seat_holds
+DROP COLUMN passenger_id_document;
+
+ALTER TABLE seat_holds
+DROP COLUMN passenger_gender;
+
+ALTER TABLE seat_holds
+DROP COLUMN passenger_age;
+
+ALTER TABLE seat_holds
+DROP COLUMN passenger_name;
+
+DROP TABLE saved_passengers;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- A booking groups the tickets bought together under a short reference (PNR)
+-- that can be read out over the phone or typed in on the manage booking page.
+CREATE TABLE
+    bookings (
+        id TEXT PRIMARY KEY,
+        pnr TEXT NOT NULL UNIQUE,
+        user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_bookings_user ON bookings (user_id);
+
+ALTER TABLE tickets
+ADD COLUMN booking_id TEXT REFERENCES bookings (id);
+
+CREATE INDEX idx_tickets_booking ON tickets (booking_id);
+
+-- Existing tickets each become a booking of their own. The PNR is drawn from
+-- the same 32 characters as new bookings (no 0/O or 1/I).
+INSERT INTO bookings (id, pnr, user_id, created_at)
+SELECT
+    id,
+    substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1)
+    || substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1)
+    || substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1)
+    || substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1)
+    || substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1)
+    || substr ('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', 1 + (random() & 31), 1),
+    user_id,
+    booked_at
+FROM tickets;
+
+UPDATE tickets
+SET booking_id = id;
+
+-- +goose Down
+DROP INDEX idx_tickets_booking;
+
+ALTER TABLE tickets
+DROP COLUMN booking_id;
+
+DROP TABLE bookings;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Cancelling keeps the ticket with a cancelled status so its history survives,
+-- and records the refund that was given for it.
+ALTER TABLE tickets
+ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+
+CREATE TABLE
+    cancellations (
+        id TEXT PRIMARY KEY,
+        ticket_id TEXT NOT NULL UNIQUE REFERENCES tickets (id),
+        -- Where the cancellation was made: 'account' or 'manage'
+        channel TEXT NOT NULL,
+        hours_before_departure INTEGER NOT NULL,
+        fare_amount INTEGER NOT NULL,
+        refund_percent INTEGER NOT NULL,
+        fee_amount INTEGER NOT NULL,
+        refund_amount INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        cancelled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+-- +goose Down
+DROP TABLE cancellations;
+
+ALTER TABLE tickets
+DROP COLUMN status;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Changing a ticket issues a replacement and cancels the original in the same
+-- transaction. Each change is recorded with the fare difference to collect
+-- (positive) or refund (negative).
+CREATE TABLE
+    ticket_modifications (
+        id TEXT PRIMARY KEY,
+        old_ticket_id TEXT NOT NULL UNIQUE REFERENCES tickets (id),
+        new_ticket_id TEXT NOT NULL UNIQUE REFERENCES tickets (id),
+        old_fare_amount INTEGER NOT NULL,
+        new_fare_amount INTEGER NOT NULL,
+        fare_difference INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        modified_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+-- +goose Down
+DROP TABLE ticket_modifications;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Every change of tickets.status is recorded here. The allowed moves between
+-- states are enforced by the application (internal/ticketstate).
+CREATE TABLE
+    ticket_state_transitions (
+        id TEXT PRIMARY KEY,
+        ticket_id TEXT NOT NULL REFERENCES tickets (id),
+        -- NULL for the state a ticket was issued in
+        from_state TEXT,
+        to_state TEXT NOT NULL,
+        actor TEXT NOT NULL,
+        reason TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_ticket_state_transitions_ticket ON ticket_state_transitions (ticket_id, created_at);
+
+CREATE INDEX idx_tickets_user_status ON tickets (user_id, status);
+
+-- Existing tickets start their history in their current state
+INSERT INTO ticket_state_transitions (id, ticket_id, from_state, to_state, actor, reason, created_at)
+SELECT LOWER(HEX(RANDOMBLOB(16))), id, NULL, status, 'system', 'existing ticket', COALESCE(booked_at, CURRENT_TIMESTAMP)
+FROM tickets;
+
+-- +goose Down
+DROP INDEX idx_tickets_user_status;
+
+DROP TABLE ticket_state_transitions;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- One row per checked-in ticket. Staff devices may scan offline and sync later,
+-- so scanned_at is when the scan happened and recorded_at when it reached us.
+CREATE TABLE
+    checkins (
+        id TEXT PRIMARY KEY,
+        ticket_id TEXT NOT NULL UNIQUE REFERENCES tickets (id),
+        departure_id TEXT NOT NULL REFERENCES departures (id),
+        -- 'eticket' or 'pnr'
+        method TEXT NOT NULL,
+        staff TEXT NOT NULL,
+        device_id TEXT NOT NULL DEFAULT '',
+        scanned_at TIMESTAMP NOT NULL,
+        recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_checkins_departure ON checkins (departure_id);
+
+-- +goose Down
+DROP TABLE checkins;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- A payment is taken against a seat hold. The hold only becomes a ticket once the
+-- payment is captured; ticket_id is set at that point.
+CREATE TABLE
+    payments (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL REFERENCES users (id),
+        -- Holds are deleted once confirmed or released, so this is not a foreign key
+        hold_id TEXT NOT NULL,
+        ticket_id TEXT REFERENCES tickets (id),
+        provider TEXT NOT NULL,
+        -- The provider's reference, known once the payment is authorized
+        provider_ref TEXT,
+        amount INTEGER NOT NULL,
+        currency TEXT NOT NULL,
+        -- pending, authorized, captured, failed or refunded
+        status TEXT NOT NULL DEFAULT 'pending',
+        failure_reason TEXT NOT NULL DEFAULT '',
+        refunded_amount INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_payments_hold ON payments (hold_id);
+
+CREATE INDEX idx_payments_ticket ON payments (ticket_id);
+
+-- +goose Down
+DROP TABLE payments;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Responses to POSTs carrying an Idempotency-Key, replayed when the same request is
+-- retried. Kept in the shared database so a retry landing on another node still
+-- finds the first result.
+CREATE TABLE
+    idempotency_keys (
+        -- The user ID, or "pnr:<PNR>" for requests from the public manage booking page
+        owner TEXT NOT NULL,
+        scope TEXT NOT NULL,
+        idempotency_key TEXT NOT NULL,
+        -- Fingerprint of the form, so a key can't be reused for a different request
+        request_hash TEXT NOT NULL,
+        -- NULL while the first request is still running
+        response_status INTEGER,
+        response_location TEXT NOT NULL DEFAULT '',
+        response_content_type TEXT NOT NULL DEFAULT '',
+        response_body TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL,
+        PRIMARY KEY (owner, scope, idempotency_key)
+    );
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
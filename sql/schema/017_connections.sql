@@ -0,0 +1,47 @@
+-- +goose Up
+-- A connection changes trains at a station, which needs time to walk between
+-- platforms. Seat holds for the legs of one itinerary share an itinerary_id so they
+-- are paid for and confirmed together, and a payment covers every ticket of its
+-- booking rather than a single ticket.
+ALTER TABLE stations
+ADD COLUMN min_transfer_minutes INTEGER NOT NULL DEFAULT 10;
+
+ALTER TABLE seat_holds
+ADD COLUMN itinerary_id TEXT;
+
+ALTER TABLE seat_holds
+ADD COLUMN leg INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE seat_holds
+ADD COLUMN legs INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_seat_holds_itinerary ON seat_holds (itinerary_id);
+
+ALTER TABLE payments
+ADD COLUMN booking_id TEXT REFERENCES bookings (id);
+
+UPDATE payments
+SET booking_id = (SELECT tk.booking_id FROM tickets tk WHERE tk.id = payments.ticket_id)
+WHERE ticket_id IS NOT NULL;
+
+CREATE INDEX idx_payments_booking ON payments (booking_id);
+
+-- +goose Down
+DROP INDEX idx_payments_booking;
+
+ALTER TABLE payments
+DROP COLUMN booking_id;
+
+DROP INDEX idx_seat_holds_itinerary;
+
+ALTER TABLE seat_holds
+DROP COLUMN legs;
+
+ALTER TABLE seat_holds
+DROP COLUMN leg;
+
+ALTER TABLE seat_holds
+DROP COLUMN itinerary_id;
+
+ALTER TABLE stations
+DROP COLUMN min_transfer_minutes;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- A timetable rule says when a train runs; the generator job turns rules into
+-- departures some days ahead. Dates and times are on the operator's clock
+-- (OPERATOR_TIMEZONE); the generator stores departures.departs_at in UTC.
+CREATE TABLE
+    timetable_rules (
+        id TEXT PRIMARY KEY,
+        train_id TEXT NOT NULL REFERENCES trains (id),
+        -- daily, weekdays or days
+        frequency TEXT NOT NULL,
+        -- For 'days', the weekdays it runs as a comma-separated list, 0 being Sunday
+        days_of_week TEXT NOT NULL DEFAULT '',
+        -- HH:MM the train leaves its first stop
+        departure_time TEXT NOT NULL,
+        valid_from TEXT NOT NULL,
+        valid_until TEXT,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX idx_timetable_rules_train ON timetable_rules (train_id);
+
+-- Dates a rule doesn't run, such as public holidays
+CREATE TABLE
+    timetable_exceptions (
+        rule_id TEXT NOT NULL REFERENCES timetable_rules (id) ON DELETE CASCADE,
+        service_date TEXT NOT NULL,
+        PRIMARY KEY (rule_id, service_date)
+    );
+
+ALTER TABLE departures
+ADD COLUMN rule_id TEXT REFERENCES timetable_rules (id);
+
+-- A rule makes at most one departure a day, so re-running the generator adds nothing
+CREATE UNIQUE INDEX idx_departures_rule_date ON departures (rule_id, service_date)
+WHERE rule_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_departures_rule_date;
+
+ALTER TABLE departures
+DROP COLUMN rule_id;
+
+DROP TABLE timetable_exceptions;
+
+DROP TABLE timetable_rules;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Tickets for a train go on sale opens_days_before its service date, at opens_time
+-- (HH:MM, the operator's local time) on that day. A window for a class overrides the
+-- train's window for every other class, which has an empty class. Trains without one
+-- are always on sale.
+CREATE TABLE
+    sale_windows (
+        train_id TEXT NOT NULL REFERENCES trains (id),
+        class TEXT NOT NULL DEFAULT '',
+        opens_days_before INTEGER NOT NULL CHECK (opens_days_before >= 0),
+        opens_time TEXT NOT NULL DEFAULT '00:00',
+        PRIMARY KEY (train_id, class)
+    );
+
+-- +goose Down
+DROP TABLE sale_windows;
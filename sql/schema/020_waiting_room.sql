@@ -0,0 +1,23 @@
+-- +goose Up
+-- The virtual waiting room in front of booking. Everyone who joins takes the next
+-- position in one queue in the shared database, and every node admits from its head,
+-- so the order users get in doesn't depend on which node they reach.
+CREATE TABLE
+    queue_entries (
+        id TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL REFERENCES users (id),
+        position INTEGER NOT NULL UNIQUE,
+        -- waiting, admitted, or abandoned when the user stopped checking their place
+        status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'admitted', 'abandoned')),
+        joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        admitted_at TIMESTAMP,
+        -- When an admission lapses and the user has to queue again
+        expires_at TIMESTAMP
+    );
+
+CREATE INDEX idx_queue_entries_status ON queue_entries (status, position);
+CREATE INDEX idx_queue_entries_admitted_at ON queue_entries (admitted_at);
+
+-- +goose Down
+DROP TABLE queue_entries;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- Blocks of seats on a departure reserved for a category of passenger. Each seat is
+-- in at most one quota, and seats in no quota are general. A quota's unsold seats
+-- return to general sale release_hours_before the departure leaves.
+CREATE TABLE
+    seat_quotas (
+        id TEXT PRIMARY KEY,
+        departure_id TEXT NOT NULL REFERENCES departures (id),
+        category TEXT NOT NULL CHECK (category IN ('senior', 'women', 'disabled', 'emergency')),
+        class TEXT NOT NULL,
+        release_hours_before INTEGER NOT NULL DEFAULT 24 CHECK (release_hours_before >= 0),
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (departure_id, category, class)
+    );
+
+CREATE TABLE
+    quota_seats (
+        quota_id TEXT NOT NULL REFERENCES seat_quotas (id) ON DELETE CASCADE,
+        departure_id TEXT NOT NULL REFERENCES departures (id),
+        seat_number INTEGER NOT NULL,
+        PRIMARY KEY (departure_id, seat_number)
+    );
+
+CREATE INDEX idx_quota_seats_quota ON quota_seats (quota_id);
+
+-- The seats still held back for a quota, before its release cutoff
+CREATE VIEW active_quota_seats AS
+SELECT qs.departure_id, qs.seat_number, sq.id AS quota_id, sq.category
+FROM quota_seats qs
+JOIN seat_quotas sq ON qs.quota_id = sq.id
+JOIN departures d ON sq.departure_id = d.id
+WHERE datetime(d.departs_at, '-' || sq.release_hours_before || ' hours') > CURRENT_TIMESTAMP;
+
+-- The quota a seat was sold under, and the certificate or authorisation reference
+-- for categories that need one
+ALTER TABLE seat_holds
+ADD COLUMN quota TEXT NOT NULL DEFAULT 'general';
+
+ALTER TABLE seat_holds
+ADD COLUMN concession_ref TEXT;
+
+ALTER TABLE tickets
+ADD COLUMN quota TEXT NOT NULL DEFAULT 'general';
+
+ALTER TABLE tickets
+ADD COLUMN concession_ref TEXT;
+
+-- +goose Down
+ALTER TABLE tickets
+DROP COLUMN concession_ref;
+
+ALTER TABLE tickets
+DROP COLUMN quota;
+
+ALTER TABLE seat_holds
+DROP COLUMN concession_ref;
+
+ALTER TABLE seat_holds
+DROP COLUMN quota;
+
+DROP VIEW active_quota_seats;
+
+DROP TABLE quota_seats;
+
+DROP TABLE seat_quotas;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- What an account may do: passengers book for themselves, agents book for customers,
+-- staff work trains and admins run the service. Admins are created with the
+-- bootstrap command.
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'passenger' CHECK (role IN ('passenger', 'staff', 'admin', 'agent'));
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN role;
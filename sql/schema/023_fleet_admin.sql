@@ -0,0 +1,17 @@
+-- +goose Up
+-- Retired trains keep their history but run no more departures
+ALTER TABLE trains
+ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'retired'));
+
+-- A seat taken out of service stays for the tickets already issued on it but is no
+-- longer sold. Seat numbers are never reused, so old tickets keep pointing at the
+-- seat they were for.
+ALTER TABLE seats
+ADD COLUMN retired BOOLEAN NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE seats
+DROP COLUMN retired;
+
+ALTER TABLE trains
+DROP COLUMN status;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Where a booking was made, for reporting: 'web' when passengers book for themselves,
+-- 'agent' when an agent books for a customer and 'waitlist' when a waitlisted
+-- passenger is given a seat. Earlier bookings were all made on the web.
+ALTER TABLE bookings
+ADD COLUMN channel TEXT NOT NULL DEFAULT 'web';
+
+CREATE INDEX idx_bookings_created_at ON bookings (created_at);
+
+CREATE INDEX idx_cancellations_cancelled_at ON cancellations (cancelled_at);
+
+-- +goose Down
+DROP INDEX idx_cancellations_cancelled_at;
+
+DROP INDEX idx_bookings_created_at;
+
+ALTER TABLE bookings
+DROP COLUMN channel;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Who did what, from where and with what result. Events are numbered in the order
+-- they were written and each one's hash covers the hash of the event before it, so
+-- changing, removing or reordering past events breaks the chain from that point on.
+-- lamport_clock is the writing node's logical clock, for ordering events across nodes.
+CREATE TABLE
+    audit_events (
+        seq INTEGER PRIMARY KEY,
+        occurred_at TIMESTAMP NOT NULL,
+        actor TEXT NOT NULL,
+        action TEXT NOT NULL,
+        target TEXT NOT NULL DEFAULT '',
+        node_id TEXT NOT NULL,
+        lamport_clock INTEGER NOT NULL,
+        ip TEXT NOT NULL DEFAULT '',
+        result TEXT NOT NULL CHECK (result IN ('success', 'failure')),
+        detail TEXT NOT NULL DEFAULT '',
+        prev_hash TEXT NOT NULL,
+        hash TEXT NOT NULL
+    );
+
+CREATE INDEX idx_audit_events_actor ON audit_events (actor);
+
+CREATE INDEX idx_audit_events_action ON audit_events (action, occurred_at);
+
+CREATE INDEX idx_audit_events_occurred_at ON audit_events (occurred_at);
+
+-- The log is append-only
+-- +goose StatementBegin
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit events cannot be changed');
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit events cannot be deleted');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE audit_events;
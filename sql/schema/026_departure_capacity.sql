@@ -0,0 +1,15 @@
+-- +goose Up
+-- The seats a departure is sold with. Fleet changes update departures still to run,
+-- so a departure that has left keeps the capacity it ran with.
+ALTER TABLE departures
+ADD COLUMN total_seats INTEGER NOT NULL DEFAULT 0;
+
+UPDATE departures
+SET
+    total_seats = (
+        SELECT COUNT(*) FROM seats s WHERE s.train_id = departures.train_id AND s.retired = 0
+    );
+
+-- +goose Down
+ALTER TABLE departures
+DROP COLUMN total_seats;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Several passengers can be booked together: their seat holds share an itinerary_id
+-- so one payment covers them and they are confirmed under a single PNR. Each hold
+-- records which passenger of the party it is for and how many there are, so a party
+-- missing a hold is not paid for.
+ALTER TABLE seat_holds
+ADD COLUMN passenger INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE seat_holds
+ADD COLUMN passengers INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE seat_holds
+DROP COLUMN passengers;
+
+ALTER TABLE seat_holds
+DROP COLUMN passenger;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- A seat freed for the waitlist is offered rather than issued: it is held for the
+-- first waiting passenger with a pending payment, and only becomes a ticket once the
+-- payment is captured. The hold keeps the channel it was sold through, and the
+-- waitlist entry the hold it was offered.
+ALTER TABLE seat_holds
+ADD COLUMN channel TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE waitlist_entries
+ADD COLUMN hold_id TEXT;
+
+CREATE INDEX idx_waitlist_entries_hold ON waitlist_entries (hold_id);
+
+-- A refund the provider could not make is kept on its payment, with the provider's
+-- error, until it is retried successfully
+ALTER TABLE payments
+ADD COLUMN refund_due INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE payments
+ADD COLUMN refund_error TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_payments_refund_due ON payments (refund_due);
+
+-- +goose Down
+DROP INDEX idx_payments_refund_due;
+
+ALTER TABLE payments
+DROP COLUMN refund_error;
+
+ALTER TABLE payments
+DROP COLUMN refund_due;
+
+DROP INDEX idx_waitlist_entries_hold;
+
+ALTER TABLE waitlist_entries
+DROP COLUMN hold_id;
+
+ALTER TABLE seat_holds
+DROP COLUMN channel;
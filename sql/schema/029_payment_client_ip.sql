@@ -0,0 +1,9 @@
+-- +goose Up
+-- The address the customer paid from, so a booking settled later by the provider's
+-- webhook is audited against the customer rather than the provider
+ALTER TABLE payments
+ADD COLUMN client_ip TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE payments
+DROP COLUMN client_ip;
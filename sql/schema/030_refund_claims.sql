@@ -0,0 +1,16 @@
+-- +goose Up
+-- A queued refund is claimed before the provider is asked for it, so only one node
+-- makes it. refund_claimed is the amount in flight; a claim whose node died is taken
+-- over once refund_claimed_at is old enough.
+ALTER TABLE payments
+ADD COLUMN refund_claimed INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE payments
+ADD COLUMN refund_claimed_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE payments
+DROP COLUMN refund_claimed_at;
+
+ALTER TABLE payments
+DROP COLUMN refund_claimed;
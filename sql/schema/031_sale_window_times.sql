@@ -0,0 +1,9 @@
+-- +goose Up
+-- Sale windows saved with a one-digit hour ("9:05") never opened in SQL's date
+-- arithmetic, so their trains were on sale all along. Pad them to HH:MM.
+UPDATE sale_windows
+SET opens_time = '0' || opens_time
+WHERE opens_time GLOB '[0-9]:[0-9][0-9]';
+
+-- +goose Down
+-- The padded times are left as they are